@@ -15,24 +15,30 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/api/v1/users": {
-            "get": {
-                "description": "Get users information with emails",
+        "/api/v1/admin/users/:id": {
+            "delete": {
+                "description": "process DELETE request for removing a deleted user for good together with its emails and friendships,\nwithout waiting for the retention. Only deleted users can be purged.\nThe personal data goes with it: its history is removed, its audit events keep the actor, action and\nids but before and after are redacted",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "people"
+                    "admin"
+                ],
+                "summary": "process DELETE request for removing a deleted user for good",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "summary": "Get all users details",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/types.UserInfo"
-                            }
+                            "$ref": "#/definitions/types.SuccessResponse"
                         }
                     },
                     "400": {
@@ -47,6 +53,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/types.ErrorResponse"
                         }
                     },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -54,36 +66,85 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "post": {
-                "description": "process POST req for add user",
-                "consumes": [
-                    "application/json"
-                ],
+            }
+        },
+        "/api/v1/audit": {
+            "get": {
+                "description": "Get a page of the changes of users, emails and friendships, the newest first.\nThe actor is the X-Actor header of the request that made the change as the caller sent it, it is not\nauthenticated: actor_source is header for such actors, anonymous without the header, service for the\nenrichment workers and the retention, system for changes outside of the service and unknown for events\nrecorded before the source was.\nPass next_cursor of a page as cursor to get the next one",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "people"
+                    "audit"
                 ],
-                "summary": "process POST req for add user",
+                "summary": "Get audit events",
                 "parameters": [
                     {
-                        "description": "first name and second name",
-                        "name": "req",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/types.Name"
-                        }
+                        "type": "integer",
+                        "default": 50,
+                        "description": "page size, 1 to 500",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "next_cursor of the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "actor",
+                        "name": "actor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "action, e.g. user.update, user.delete, email.create, friendship.delete",
+                        "name": "action",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "user",
+                            "email",
+                            "friendship"
+                        ],
+                        "type": "string",
+                        "description": "entity",
+                        "name": "entity",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "id of the entity, first-second user id for friendships",
+                        "name": "entity_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "X-Request-ID of the request that made the changes",
+                        "name": "request_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "RFC 3339 time, events at or after it",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "RFC 3339 time, events before it",
+                        "name": "to",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "integer",
-                            "format": "int64"
+                            "$ref": "#/definitions/types.AuditPage"
                         }
                     },
                     "400": {
@@ -92,12 +153,6 @@ const docTemplate = `{
                             "$ref": "#/definitions/types.ErrorResponse"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
-                        "schema": {
-                            "$ref": "#/definitions/types.ErrorResponse"
-                        }
-                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -107,37 +162,39 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/users/:id": {
-            "get": {
-                "description": "Get user information with emails by second name",
+        "/api/v1/enrichment/refresh": {
+            "post": {
+                "description": "Queue enrichment jobs for users enriched longer ago than older_than and/or with missing attributes,\nusers with a job in progress are skipped",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "people"
+                    "enrichment"
                 ],
-                "summary": "Get user details",
+                "summary": "Bulk re-enrichment",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "User ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "users filter",
+                        "name": "req",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/types.RefreshRequest"
+                        }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "202": {
+                        "description": "Accepted",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/types.UserInfo"
-                            }
+                            "$ref": "#/definitions/types.RefreshResponse"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "400": {
+                        "description": "Bad Request",
                         "schema": {
                             "$ref": "#/definitions/types.ErrorResponse"
                         }
@@ -147,44 +204,141 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/types.ErrorResponse"
                         }
+                    },
+                    "503": {
+                        "description": "queue.workers is 0, nothing would process the jobs",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
                     }
                 }
-            },
-            "put": {
-                "description": "process PUT request for edite user` + "`" + `s info",
-                "consumes": [
+            }
+        },
+        "/api/v1/enrichment/status": {
+            "get": {
+                "description": "Get the rate limit quota of every enrichment upstream as reported by its last response,\nunknown values are null, and the state of its circuit breaker",
+                "produces": [
                     "application/json"
                 ],
+                "tags": [
+                    "enrichment"
+                ],
+                "summary": "Get enrichment providers status",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/types.ProvidersStatus"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users": {
+            "get": {
+                "description": "Get a page of users with emails, filtered and sorted. Pass next_cursor of a page as cursor to get the next one",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "people"
                 ],
-                "summary": "process PUT request for edite user` + "`" + `s info",
+                "summary": "Get all users details",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "User ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "default": 50,
+                        "description": "page size, 1 to 500",
+                        "name": "limit",
+                        "in": "query"
                     },
                     {
-                        "description": "user` + "`" + `s info",
-                        "name": "req",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/types.User"
-                        }
+                        "type": "string",
+                        "description": "next_cursor of the previous page, issued for the same sort",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "id",
+                            "-id",
+                            "age",
+                            "-age",
+                            "first_name",
+                            "-first_name",
+                            "last_name",
+                            "-last_name"
+                        ],
+                        "type": "string",
+                        "default": "id",
+                        "description": "sort order, a leading - sorts descending",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "male",
+                            "female"
+                        ],
+                        "type": "string",
+                        "description": "gender",
+                        "name": "gender",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "comma separated ISO 3166-1 codes or country names, e.g. DE,FR",
+                        "name": "nationality",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "region name or code: Africa (AF), Asia (AS), Europe (EU), North America (NA), South America (SA), Oceania (OC), Antarctica (AN)",
+                        "name": "region",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "minimal age",
+                        "name": "age_min",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "maximal age",
+                        "name": "age_max",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "case-insensitive first name prefix",
+                        "name": "first_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "case-insensitive last name prefix",
+                        "name": "last_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "whole last name, case-sensitive, the successor of the deprecated GET /api/v1/users/{last_name}",
+                        "name": "last_name_exact",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "only users with (true) or without (false) emails",
+                        "name": "has_email",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/types.SuccessResponse"
+                            "$ref": "#/definitions/types.UsersPage"
                         }
                     },
                     "400": {
@@ -193,6 +347,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/types.ErrorResponse"
                         }
                     },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -201,8 +361,8 @@ const docTemplate = `{
                     }
                 }
             },
-            "delete": {
-                "description": "process DELETE request for deleting user` + "`" + `s info",
+            "post": {
+                "description": "process POST req for add user, in the async enrichment mode the user is stored right away\nand 202 points to the enrichment status in the Location header.\nThe emails and the friends are stored with the user or, when one of them is rejected, nothing is stored\nand 409 reports every email and friend like the other item batches do",
                 "consumes": [
                     "application/json"
                 ],
@@ -212,21 +372,29 @@ const docTemplate = `{
                 "tags": [
                     "people"
                 ],
-                "summary": "process DELETE request for deleting user` + "`" + `s info",
+                "summary": "process POST req for add user",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "User ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "first name and second name, optionally emails and friends",
+                        "name": "req",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/types.CreateUserRequest"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/types.SuccessResponse"
+                            "$ref": "#/definitions/types.CreateUserResponse"
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/types.CreateUserResponse"
                         }
                     },
                     "400": {
@@ -235,25 +403,49 @@ const docTemplate = `{
                             "$ref": "#/definitions/types.ErrorResponse"
                         }
                     },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
                             "$ref": "#/definitions/types.ErrorResponse"
                         }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
                     }
                 }
             }
         },
-        "/api/v1/users/:id/emails": {
+        "/api/v1/users/:id": {
             "get": {
-                "description": "Get all user` + "`" + `s emails",
+                "description": "Get user information with emails and the number of friends by id.\nA non-numeric id is still looked up as a last name for older clients, that path is deprecated\nin favour of GET /api/v1/users?last_name_exact=, which pages the same users. The ETag header carries the version of the user,\na matching If-None-Match is answered with 304.\nWith as_of the user, its emails and its friends are returned as they were at that time as types.UserSnapshot\nwithout the friends that were deleted at that time, like the friends of now leave out the deleted ones",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "people"
                 ],
-                "summary": "Get all user` + "`" + `s emails",
+                "summary": "Get user details",
                 "parameters": [
                     {
                         "type": "integer",
@@ -261,23 +453,35 @@ const docTemplate = `{
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "RFC 3339 time, e.g. 2025-01-02T15:04:05Z",
+                        "name": "as_of",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of a cached version",
+                        "name": "If-None-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/types.Email"
+                            "$ref": "#/definitions/types.UserInfo"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "version of the user"
                             }
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
-                        "schema": {
-                            "$ref": "#/definitions/types.ErrorResponse"
-                        }
+                    "304": {
+                        "description": "Not Modified"
                     },
                     "404": {
                         "description": "Not Found",
@@ -293,8 +497,8 @@ const docTemplate = `{
                     }
                 }
             },
-            "post": {
-                "description": "process POST req for add user` + "`" + `s emails",
+            "put": {
+                "description": "process PUT request for edite user` + "`" + `s info, nationality is an ISO 3166-1 code or an English country name\nand is stored as alpha-2. Without country_hint the stored hint is kept, PATCH clears it.\nWith If-Match the user is only replaced at that version",
                 "consumes": [
                     "application/json"
                 ],
@@ -304,7 +508,7 @@ const docTemplate = `{
                 "tags": [
                     "people"
                 ],
-                "summary": "process POST req for add user` + "`" + `s emails",
+                "summary": "process PUT request for edite user` + "`" + `s info",
                 "parameters": [
                     {
                         "type": "integer",
@@ -314,12 +518,18 @@ const docTemplate = `{
                         "required": true
                     },
                     {
-                        "description": "list of user` + "`" + `s emails",
+                        "type": "string",
+                        "description": "ETag the user must still have",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "description": "user` + "`" + `s info",
                         "name": "req",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/types.EmailRequest"
+                            "$ref": "#/definitions/types.User"
                         }
                     }
                 ],
@@ -328,6 +538,12 @@ const docTemplate = `{
                         "description": "OK",
                         "schema": {
                             "$ref": "#/definitions/types.SuccessResponse"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "new version of the user"
+                            }
                         }
                     },
                     "400": {
@@ -336,6 +552,18 @@ const docTemplate = `{
                             "$ref": "#/definitions/types.ErrorResponse"
                         }
                     },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -343,11 +571,9 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/api/v1/users/:id/friends": {
-            "get": {
-                "description": "Get all user` + "`" + `s friends",
+            },
+            "delete": {
+                "description": "process DELETE request for deleting user` + "`" + `s info, with If-Match only at that version.\nThe user is hidden and can be restored until the retention purges it",
                 "consumes": [
                     "application/json"
                 ],
@@ -357,7 +583,7 @@ const docTemplate = `{
                 "tags": [
                     "people"
                 ],
-                "summary": "Get all user` + "`" + `s friends",
+                "summary": "process DELETE request for deleting user` + "`" + `s info",
                 "parameters": [
                     {
                         "type": "integer",
@@ -365,16 +591,19 @@ const docTemplate = `{
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag the user must still have",
+                        "name": "If-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/types.Friend"
-                            }
+                            "$ref": "#/definitions/types.SuccessResponse"
                         }
                     },
                     "400": {
@@ -389,6 +618,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/types.ErrorResponse"
                         }
                     },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -397,10 +632,12 @@ const docTemplate = `{
                     }
                 }
             },
-            "post": {
-                "description": "process POST req for add user` + "`" + `s friends",
+            "patch": {
+                "description": "process PATCH request for partial update of user` + "`" + `s info, the body is a JSON Merge Patch (RFC 7396)\nwith Content-Type application/merge-patch+json or application/json, or a JSON Patch (RFC 6902)\nwith Content-Type application/json-patch+json. Only the changed columns are written,\nwith If-Match only at that version",
                 "consumes": [
-                    "application/json"
+                    "application/json",
+                    "application/merge-patch+json",
+                    "application/json-patch+json"
                 ],
                 "produces": [
                     "application/json"
@@ -408,7 +645,7 @@ const docTemplate = `{
                 "tags": [
                     "people"
                 ],
-                "summary": "process POST req for add user` + "`" + `s friends",
+                "summary": "process PATCH request for partial update of user` + "`" + `s info",
                 "parameters": [
                     {
                         "type": "integer",
@@ -418,12 +655,18 @@ const docTemplate = `{
                         "required": true
                     },
                     {
-                        "description": "list of user` + "`" + `s friends",
+                        "type": "string",
+                        "description": "ETag the user must still have",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "description": "merge patch or JSON patch of types.User",
                         "name": "req",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/types.Friends"
+                            "type": "object"
                         }
                     }
                 ],
@@ -431,7 +674,13 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/types.SuccessResponse"
+                            "$ref": "#/definitions/types.UserInfo"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "new version of the user"
+                            }
                         }
                     },
                     "400": {
@@ -440,6 +689,86 @@ const docTemplate = `{
                             "$ref": "#/definitions/types.ErrorResponse"
                         }
                     },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/:id/emails": {
+            "get": {
+                "description": "Get all user` + "`" + `s emails",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "people"
+                ],
+                "summary": "Get all user` + "`" + `s emails",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/types.Email"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -448,8 +777,8 @@ const docTemplate = `{
                     }
                 }
             },
-            "delete": {
-                "description": "process DELETE request to delete friendships (one or more)",
+            "post": {
+                "description": "process POST req for add user` + "`" + `s emails,\nall of them or none: when one item is not stored 409 reports every item and nothing is changed\nan email of a deleted user is a conflict until that user is purged",
                 "consumes": [
                     "application/json"
                 ],
@@ -459,7 +788,7 @@ const docTemplate = `{
                 "tags": [
                     "people"
                 ],
-                "summary": "process DELETE request to delete friendships (one or more)",
+                "summary": "process POST req for add user` + "`" + `s emails",
                 "parameters": [
                     {
                         "type": "integer",
@@ -469,12 +798,12 @@ const docTemplate = `{
                         "required": true
                     },
                     {
-                        "description": "list of friendships",
+                        "description": "list of user` + "`" + `s emails",
                         "name": "req",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/types.Friendships"
+                            "$ref": "#/definitions/types.EmailRequest"
                         }
                     }
                 ],
@@ -482,7 +811,7 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/types.SuccessResponse"
+                            "$ref": "#/definitions/types.BatchItemsResponse"
                         }
                     },
                     "400": {
@@ -491,6 +820,18 @@ const docTemplate = `{
                             "$ref": "#/definitions/types.ErrorResponse"
                         }
                     },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -500,9 +841,115 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/users/emails": {
-            "delete": {
-                "description": "process DELETE request to delete emails (one or more)",
+        "/api/v1/users/:id/enrich": {
+            "post": {
+                "description": "Re-run age, gender and nationality lookups for the user and store what changed,\nattributes that could not be resolved keep their values",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "people"
+                ],
+                "summary": "Re-enrich user",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/types.EnrichResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/:id/enrichment": {
+            "get": {
+                "description": "Get the status of every enriched attribute and the latest enrichment job of the user",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "people"
+                ],
+                "summary": "Get user enrichment status",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/types.EnrichmentState"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/:id/friends": {
+            "get": {
+                "description": "Get all user` + "`" + `s friends",
                 "consumes": [
                     "application/json"
                 ],
@@ -512,15 +959,73 @@ const docTemplate = `{
                 "tags": [
                     "people"
                 ],
-                "summary": "process DELETE request to delete emails (one or more)",
+                "summary": "Get all user` + "`" + `s friends",
                 "parameters": [
                     {
-                        "description": "list email` + "`" + `s ids",
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/types.Friend"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "process POST req for add user` + "`" + `s friends,\nall of them or none: when one item is not stored 409 reports every item and nothing is changed",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "people"
+                ],
+                "summary": "process POST req for add user` + "`" + `s friends",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "list of user` + "`" + `s friends",
                         "name": "req",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/types.EmailIDs"
+                            "$ref": "#/definitions/types.Friends"
                         }
                     }
                 ],
@@ -528,26 +1033,703 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/types.SuccessResponse"
+                            "$ref": "#/definitions/types.BatchItemsResponse"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
-                        "schema": {
-                            "$ref": "#/definitions/types.ErrorResponse"
-                        }
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "process DELETE request to delete friendships (one or more),\nall of them or none: when one item is not stored 409 reports every item and nothing is changed\nthe friendships of deleted users are not_found, they come back with a restore",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "people"
+                ],
+                "summary": "process DELETE request to delete friendships (one or more)",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "list of friendships",
+                        "name": "req",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/types.Friendships"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/types.BatchItemsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/:id/history": {
+            "get": {
+                "description": "Get a page of the changes of the user, its emails and its friendships, the newest first.\nThe events outlive the user, a purge keeps them with before and after redacted and redacted_at set",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "audit"
+                ],
+                "summary": "Get the history of a user",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "default": 50,
+                        "description": "page size, 1 to 500",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "next_cursor of the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "actor",
+                        "name": "actor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "action, e.g. user.update",
+                        "name": "action",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "user",
+                            "email",
+                            "friendship"
+                        ],
+                        "type": "string",
+                        "description": "entity",
+                        "name": "entity",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "RFC 3339 time, events at or after it",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "RFC 3339 time, events before it",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/types.AuditPage"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/:id/restore": {
+            "post": {
+                "description": "process POST request for restoring a deleted user, its emails and friendships come back with it",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "people"
+                ],
+                "summary": "process POST request for restoring a deleted user",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/types.UserInfo"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "new version of the user"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/batch": {
+            "post": {
+                "description": "enriches the first names with upstream batch requests and reports the result of every row,\n207 is returned when some of the rows failed. When no row was stored because an enrichment\nprovider is rate limited or unavailable 503 is returned instead, both carry Retry-After then",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "people"
+                ],
+                "summary": "process POST req for add many users",
+                "parameters": [
+                    {
+                        "description": "list of first and second names",
+                        "name": "req",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/types.BatchCreateUsersRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/types.BatchCreateUsersResponse"
+                        }
+                    },
+                    "207": {
+                        "description": "Multi-Status",
+                        "schema": {
+                            "$ref": "#/definitions/types.BatchCreateUsersResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/types.BatchCreateUsersResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/emails": {
+            "delete": {
+                "description": "process DELETE request to delete emails (one or more),\nall of them or none: when one item is not stored 409 reports every item and nothing is changed\nthe emails of deleted users are not_found, they come back with a restore",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "people"
+                ],
+                "summary": "process DELETE request to delete emails (one or more)",
+                "parameters": [
+                    {
+                        "description": "list email` + "`" + `s ids",
+                        "name": "req",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/types.EmailIDs"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/types.BatchItemsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/search": {
+            "get": {
+                "description": "Fuzzy search over first and last names, tolerant of case, diacritics and Cyrillic or Latin spelling.\nThe best matches come first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "people"
+                ],
+                "summary": "Search users",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "a name or a part of it, e.g. ivanov or Иванов",
+                        "name": "q",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "maximal number of results, 1 to 100",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/types.UserMatch"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/health": {
+            "get": {
+                "description": "Reports the database and the circuit breakers of the enrichment providers,\ndegraded while some provider is short-circuited, 503 when the database is unavailable",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Health check",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/types.HealthResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/types.HealthResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/metrics": {
+            "get": {
+                "description": "Circuit breaker states and counters and the last known quotas of the enrichment providers",
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Prometheus metrics",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "types.AuditEvent": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string",
+                    "example": "user.update"
+                },
+                "actor": {
+                    "type": "string",
+                    "example": "alice"
+                },
+                "actor_source": {
+                    "description": "ActorSource tells where Actor comes from, an actor from the header is whatever the caller claimed",
+                    "type": "string",
+                    "enum": [
+                        "header",
+                        "anonymous",
+                        "service",
+                        "system",
+                        "unknown"
+                    ],
+                    "example": "header"
+                },
+                "after": {
+                    "type": "object"
+                },
+                "before": {
+                    "type": "object"
+                },
+                "entity": {
+                    "type": "string",
+                    "example": "user"
+                },
+                "entity_id": {
+                    "type": "string",
+                    "example": "42"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "occurred_at": {
+                    "type": "string"
+                },
+                "redacted_at": {
+                    "description": "RedactedAt is when a purge removed before and after of an event of the purged user",
+                    "type": "string"
+                },
+                "request_id": {
+                    "type": "string"
+                },
+                "user_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "types.AuditPage": {
+            "type": "object",
+            "properties": {
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/types.AuditEvent"
+                    }
+                },
+                "next_cursor": {
+                    "type": "string"
+                }
+            }
+        },
+        "types.BatchCreateUsersRequest": {
+            "type": "object",
+            "required": [
+                "users"
+            ],
+            "properties": {
+                "users": {
+                    "type": "array",
+                    "maxItems": 100,
+                    "minItems": 1,
+                    "items": {
+                        "$ref": "#/definitions/types.Name"
+                    }
+                }
+            }
+        },
+        "types.BatchCreateUsersResponse": {
+            "type": "object",
+            "properties": {
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/types.BatchUserResult"
+                    }
+                }
+            }
+        },
+        "types.BatchItemsResponse": {
+            "type": "object",
+            "properties": {
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/types.ItemResult"
+                    }
+                }
+            }
+        },
+        "types.BatchUserResult": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "index": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "pending": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "nationality"
+                    ]
+                },
+                "user_id": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "types.BreakerState": {
+            "type": "object",
+            "properties": {
+                "consecutive_failures": {
+                    "type": "integer"
+                },
+                "failures": {
+                    "type": "integer"
+                },
+                "opened_at": {
+                    "type": "string"
+                },
+                "provider": {
+                    "type": "string",
+                    "example": "gender"
+                },
+                "rejected": {
+                    "type": "integer"
+                },
+                "requests": {
+                    "type": "integer"
+                },
+                "state": {
+                    "type": "string",
+                    "example": "closed"
+                }
+            }
+        },
+        "types.CountryData": {
+            "type": "object",
+            "properties": {
+                "country_id": {
+                    "type": "string"
+                },
+                "probability": {
+                    "type": "number"
+                }
+            }
+        },
+        "types.CountryInfo": {
+            "type": "object",
+            "properties": {
+                "alpha2": {
+                    "type": "string",
+                    "example": "DE"
+                },
+                "alpha3": {
+                    "type": "string",
+                    "example": "DEU"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Germany"
+                },
+                "region": {
+                    "type": "string",
+                    "example": "Europe"
+                }
+            }
+        },
+        "types.CreateUserRequest": {
+            "type": "object",
+            "required": [
+                "emails",
+                "first_name",
+                "friends_ids"
+            ],
+            "properties": {
+                "country_hint": {
+                    "description": "CountryHint is the ISO 3166-1 alpha-2 country the name comes from, it narrows the age and gender lookups",
+                    "type": "string",
+                    "example": "US"
+                },
+                "emails": {
+                    "type": "array",
+                    "maxItems": 100,
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "ann@example.com"
+                    ]
+                },
+                "first_name": {
+                    "type": "string"
+                },
+                "friends_ids": {
+                    "type": "array",
+                    "maxItems": 100,
+                    "items": {
+                        "type": "integer"
+                    },
+                    "example": [
+                        2
+                    ]
+                },
+                "last_name": {
+                    "type": "string"
+                }
+            }
+        },
+        "types.CreateUserResponse": {
+            "type": "object",
+            "properties": {
+                "job_id": {
+                    "description": "JobID is the queued enrichment job in the async mode",
+                    "type": "integer"
+                },
+                "pending": {
+                    "description": "Pending lists the attributes that could not be enriched and are stored as null",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
                     },
-                    "500": {
-                        "description": "Internal Server Error",
-                        "schema": {
-                            "$ref": "#/definitions/types.ErrorResponse"
-                        }
-                    }
+                    "example": [
+                        "nationality"
+                    ]
+                },
+                "user_id": {
+                    "type": "integer",
+                    "example": 1
                 }
             }
-        }
-    },
-    "definitions": {
+        },
         "types.Email": {
             "type": "object",
             "properties": {
@@ -580,10 +1762,142 @@ const docTemplate = `{
             ],
             "properties": {
                 "emails": {
+                    "type": "array",
+                    "maxItems": 100,
+                    "minItems": 1,
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "types.EnrichResponse": {
+            "type": "object",
+            "properties": {
+                "changes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/types.EnrichmentChange"
+                    }
+                },
+                "failed": {
+                    "description": "Failed lists the attributes that could not be refreshed, their stored values are kept",
                     "type": "array",
                     "items": {
                         "type": "string"
+                    },
+                    "example": [
+                        "nationality"
+                    ]
+                },
+                "user_id": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "types.EnrichmentChange": {
+            "type": "object",
+            "properties": {
+                "attribute": {
+                    "type": "string",
+                    "example": "age"
+                },
+                "new_value": {
+                    "type": "string",
+                    "example": "42"
+                },
+                "old_value": {
+                    "type": "string",
+                    "example": "41"
+                }
+            }
+        },
+        "types.EnrichmentDetails": {
+            "type": "object",
+            "properties": {
+                "age_count": {
+                    "type": "integer",
+                    "example": 2231
+                },
+                "countries": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/types.CountryData"
                     }
+                },
+                "gender_count": {
+                    "type": "integer",
+                    "example": 25000
+                },
+                "gender_probability": {
+                    "type": "number",
+                    "example": 0.99
+                },
+                "nationality_count": {
+                    "type": "integer",
+                    "example": 18000
+                }
+            }
+        },
+        "types.EnrichmentJob": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "run_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "queued"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "types.EnrichmentState": {
+            "type": "object",
+            "properties": {
+                "enrichment_status": {
+                    "$ref": "#/definitions/types.EnrichmentStatus"
+                },
+                "job": {
+                    "$ref": "#/definitions/types.EnrichmentJob"
+                },
+                "user_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "types.EnrichmentStatus": {
+            "type": "object",
+            "properties": {
+                "age": {
+                    "type": "string",
+                    "example": "ok"
+                },
+                "gender": {
+                    "type": "string",
+                    "example": "ok"
+                },
+                "nationality": {
+                    "type": "string",
+                    "example": "pending"
                 }
             }
         },
@@ -593,6 +1907,13 @@ const docTemplate = `{
                 "error": {
                     "type": "string"
                 },
+                "items": {
+                    "description": "Items reports every item of a batch that was rejected as a whole",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/types.ItemResult"
+                    }
+                },
                 "message": {
                     "type": "string"
                 }
@@ -604,6 +1925,11 @@ const docTemplate = `{
                 "first_name"
             ],
             "properties": {
+                "country_hint": {
+                    "description": "CountryHint is the ISO 3166-1 alpha-2 country the name comes from, it narrows the age and gender lookups",
+                    "type": "string",
+                    "example": "US"
+                },
                 "first_name": {
                     "type": "string"
                 },
@@ -623,6 +1949,8 @@ const docTemplate = `{
             "properties": {
                 "friends_ids": {
                     "type": "array",
+                    "maxItems": 100,
+                    "minItems": 1,
                     "items": {
                         "type": "integer"
                     }
@@ -655,12 +1983,71 @@ const docTemplate = `{
                 }
             }
         },
+        "types.HealthResponse": {
+            "type": "object",
+            "properties": {
+                "breakers": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/types.BreakerState"
+                    }
+                },
+                "database": {
+                    "type": "string",
+                    "example": "ok"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "ok"
+                }
+            }
+        },
+        "types.ItemResult": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "description": "ID is the id of a created email",
+                    "type": "integer",
+                    "example": 7
+                },
+                "index": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "item": {
+                    "type": "string",
+                    "example": "ann@example.com"
+                },
+                "message": {
+                    "type": "string",
+                    "example": "owned by another user"
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "created",
+                        "deleted",
+                        "duplicate",
+                        "conflict",
+                        "not_found",
+                        "invalid",
+                        "rolled_back"
+                    ],
+                    "example": "created"
+                }
+            }
+        },
         "types.Name": {
             "type": "object",
             "required": [
                 "first_name"
             ],
             "properties": {
+                "country_hint": {
+                    "description": "CountryHint is the ISO 3166-1 alpha-2 country the name comes from, it narrows the age and gender lookups",
+                    "type": "string",
+                    "example": "US"
+                },
                 "first_name": {
                     "type": "string"
                 },
@@ -669,6 +2056,74 @@ const docTemplate = `{
                 }
             }
         },
+        "types.ProvidersStatus": {
+            "type": "object",
+            "properties": {
+                "breakers": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/types.BreakerState"
+                    }
+                },
+                "quotas": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/types.QuotaState"
+                    }
+                }
+            }
+        },
+        "types.QuotaState": {
+            "type": "object",
+            "properties": {
+                "blocked_until": {
+                    "type": "string"
+                },
+                "limit": {
+                    "type": "integer",
+                    "example": 1000
+                },
+                "provider": {
+                    "type": "string",
+                    "example": "age"
+                },
+                "remaining": {
+                    "type": "integer",
+                    "example": 998
+                },
+                "reset_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "types.RefreshRequest": {
+            "type": "object",
+            "properties": {
+                "limit": {
+                    "type": "integer",
+                    "maximum": 10000,
+                    "minimum": 1,
+                    "example": 100
+                },
+                "missing_only": {
+                    "type": "boolean"
+                },
+                "older_than": {
+                    "description": "OlderThan selects users enriched longer ago than this duration, e.g. \"720h\"",
+                    "type": "string",
+                    "example": "720h"
+                }
+            }
+        },
+        "types.RefreshResponse": {
+            "type": "object",
+            "properties": {
+                "queued": {
+                    "type": "integer",
+                    "example": 42
+                }
+            }
+        },
         "types.SuccessResponse": {
             "type": "object",
             "properties": {
@@ -685,13 +2140,23 @@ const docTemplate = `{
             ],
             "properties": {
                 "age": {
-                    "type": "integer"
+                    "type": "integer",
+                    "maximum": 150
+                },
+                "country_hint": {
+                    "description": "CountryHint is the ISO 3166-1 alpha-2 country the name comes from, it narrows the age and gender lookups",
+                    "type": "string",
+                    "example": "US"
                 },
                 "first_name": {
                     "type": "string"
                 },
                 "gender": {
-                    "type": "string"
+                    "type": "string",
+                    "enum": [
+                        "male",
+                        "female"
+                    ]
                 },
                 "last_name": {
                     "type": "string"
@@ -708,7 +2173,21 @@ const docTemplate = `{
             ],
             "properties": {
                 "age": {
-                    "type": "integer"
+                    "type": "integer",
+                    "maximum": 150
+                },
+                "country": {
+                    "description": "Country describes the nationality, it is derived on read",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/types.CountryInfo"
+                        }
+                    ]
+                },
+                "country_hint": {
+                    "description": "CountryHint is the ISO 3166-1 alpha-2 country the name comes from, it narrows the age and gender lookups",
+                    "type": "string",
+                    "example": "US"
                 },
                 "emails": {
                     "type": "array",
@@ -716,12 +2195,93 @@ const docTemplate = `{
                         "type": "string"
                     }
                 },
+                "enrichment": {
+                    "$ref": "#/definitions/types.EnrichmentDetails"
+                },
+                "enrichment_status": {
+                    "$ref": "#/definitions/types.EnrichmentStatus"
+                },
                 "first_name": {
                     "type": "string"
                 },
+                "friend_count": {
+                    "description": "FriendCount is only filled when a single user is fetched by id",
+                    "type": "integer"
+                },
                 "gender": {
+                    "type": "string",
+                    "enum": [
+                        "male",
+                        "female"
+                    ]
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "last_name": {
+                    "type": "string"
+                },
+                "nationality": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "version": {
+                    "description": "Version grows with every change of the user, its emails, friends and enrichment, it is the ETag",
+                    "type": "integer"
+                }
+            }
+        },
+        "types.UserMatch": {
+            "type": "object",
+            "required": [
+                "first_name"
+            ],
+            "properties": {
+                "age": {
+                    "type": "integer",
+                    "maximum": 150
+                },
+                "country": {
+                    "description": "Country describes the nationality, it is derived on read",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/types.CountryInfo"
+                        }
+                    ]
+                },
+                "country_hint": {
+                    "description": "CountryHint is the ISO 3166-1 alpha-2 country the name comes from, it narrows the age and gender lookups",
+                    "type": "string",
+                    "example": "US"
+                },
+                "emails": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "enrichment": {
+                    "$ref": "#/definitions/types.EnrichmentDetails"
+                },
+                "enrichment_status": {
+                    "$ref": "#/definitions/types.EnrichmentStatus"
+                },
+                "first_name": {
                     "type": "string"
                 },
+                "friend_count": {
+                    "description": "FriendCount is only filled when a single user is fetched by id",
+                    "type": "integer"
+                },
+                "gender": {
+                    "type": "string",
+                    "enum": [
+                        "male",
+                        "female"
+                    ]
+                },
                 "id": {
                     "type": "integer"
                 },
@@ -730,6 +2290,31 @@ const docTemplate = `{
                 },
                 "nationality": {
                     "type": "string"
+                },
+                "score": {
+                    "type": "number",
+                    "example": 0.83
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "version": {
+                    "description": "Version grows with every change of the user, its emails, friends and enrichment, it is the ETag",
+                    "type": "integer"
+                }
+            }
+        },
+        "types.UsersPage": {
+            "type": "object",
+            "properties": {
+                "next_cursor": {
+                    "type": "string"
+                },
+                "users": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/types.UserInfo"
+                    }
                 }
             }
         }
@@ -16,19 +16,10 @@ import (
 )
 
 func Init() {
-	logger := logrus.New()
+	logger := newLogger()
 
 	logger.Info("Starting people")
 
-	logger.SetOutput(os.Stdout)
-	logger.SetFormatter(&logrus.TextFormatter{
-		FullTimestamp:          true,
-		TimestampFormat:        "2006-01-02 15:04:05",
-		ForceColors:            true,
-		DisableLevelTruncation: true,
-	})
-	logger.SetLevel(logrus.DebugLevel)
-
 	cfg, err := LoadConfig(".", logger)
 	if err != nil {
 		log.Fatalf("Error loading config: %v", err)
@@ -36,19 +27,9 @@ func Init() {
 
 	serverHost := cfg.Server.Host + ":" + cfg.Server.Port
 
-	db := cfg.Database.DB
-	user := cfg.Database.User
-	password := cfg.Database.Password
-	dbHost := cfg.Database.Host + ":" + cfg.Database.Port
-	dbName := cfg.Database.DBName
-
-	dbUrl := fmt.Sprintf("%s://%s:%s@%s/%s", db, user, password, dbHost, dbName)
+	dbUrl := databaseUrl(cfg.Database)
 	log.Printf("Connecting to %s", dbUrl)
 
-	ageUrl := cfg.Enrichment.AgeUrl
-	genderUrl := cfg.Enrichment.GenderUrl
-	nationalityUrl := cfg.Enrichment.NationalityUrl
-
 	ctx := context.Background()
 
 	store, err := storage.New(ctx, dbUrl, logger)
@@ -56,12 +37,29 @@ func Init() {
 		logger.Fatalf("Failed start storage. Error: %v", err)
 	}
 
-	enrichments, err := enrichment.New(ageUrl, genderUrl, nationalityUrl, logger)
+	enrichments, err := enrichment.NewProvider(cfg.Enrichment, logger)
 	if err != nil {
 		logger.Fatalf("Failed start enrichment. Error: %v", err)
 	}
 
-	useCase := usecase.New(store, enrichments, logger)
+	if cfg.Enrichment.CacheTTL > 0 {
+		enrichments = enrichment.NewCached(enrichments, store, cfg.Enrichment, logger)
+	}
+
+	useCase, err := usecase.New(usecase.Postgres(store), enrichments, cfg.Enrichment, logger)
+	if err != nil {
+		logger.Fatalf("Failed start usecase. Error: %v", err)
+	}
+
+	if cfg.Queue.Workers > 0 {
+		go useCase.RunEnrichmentWorkers(ctx, cfg.Queue)
+	} else {
+		logger.Warn("No enrichment workers, refresh is rejected and queued jobs wait for other instances")
+	}
+
+	if cfg.Retention.DeletedUsers > 0 {
+		go useCase.RunRetention(ctx, cfg.Retention)
+	}
 
 	server := handlers.New(useCase, logger)
 
@@ -72,11 +70,42 @@ func Init() {
 	}
 }
 
+func newLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(os.Stdout)
+	logger.SetFormatter(&logrus.TextFormatter{
+		FullTimestamp:          true,
+		TimestampFormat:        "2006-01-02 15:04:05",
+		ForceColors:            true,
+		DisableLevelTruncation: true,
+	})
+	logger.SetLevel(logrus.DebugLevel)
+	return logger
+}
+
+func databaseUrl(cfg types.DatabaseConfig) string {
+	dbHost := cfg.Host + ":" + cfg.Port
+	return fmt.Sprintf("%s://%s:%s@%s/%s", cfg.DB, cfg.User, cfg.Password, dbHost, cfg.DBName)
+}
+
 func LoadConfig(path string, log *logrus.Logger) (types.Config, error) {
 	viper.SetConfigName("config")
 	viper.SetConfigType("yml")
 	viper.AddConfigPath(path)
 
+	// secrets are kept out of config.yml
+	for key, env := range map[string]string{
+		"enrichment.ageApiKey":         "AGIFY_API_KEY",
+		"enrichment.genderApiKey":      "GENDERIZE_API_KEY",
+		"enrichment.nationalityApiKey": "NATIONALIZE_API_KEY",
+		"enrichment.http.proxy":        "ENRICHMENT_PROXY",
+	} {
+		if err := viper.BindEnv(key, env); err != nil {
+			log.WithError(err).Error("Failed binding config to environment")
+			return types.Config{}, fmt.Errorf("failed to bind %s: %w", env, err)
+		}
+	}
+
 	if err := viper.ReadInConfig(); err != nil {
 		log.WithError(err).Error("Failed loading config")
 		return types.Config{}, fmt.Errorf("failed to read config: %w", err)
@@ -0,0 +1,128 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"people/internal/repository/storage"
+)
+
+// Run parses the command line, without a command the server is started
+func Run(args []string) error {
+	serve := func(*cli.Context) error {
+		Init()
+		return nil
+	}
+
+	people := &cli.App{
+		Name:   "people",
+		Usage:  "people service",
+		Action: serve,
+		Commands: []*cli.Command{
+			{
+				Name:   "serve",
+				Usage:  "migrate the database to the latest version and start the HTTP server",
+				Action: serve,
+			},
+			{
+				Name:  "migrate",
+				Usage: "manage the database schema, without a subcommand it migrates to the latest version",
+				Action: func(c *cli.Context) error {
+					return migrate(c, func(ctx context.Context, store *storage.Storage) error {
+						return store.MigrateUp(ctx, 0)
+					})
+				},
+				Subcommands: []*cli.Command{
+					{
+						Name:  "up",
+						Usage: "apply the pending migrations",
+						Flags: []cli.Flag{
+							&cli.Int64Flag{Name: "to", Usage: "stop after this version, 0 applies all of them"},
+						},
+						Action: func(c *cli.Context) error {
+							return migrate(c, func(ctx context.Context, store *storage.Storage) error {
+								return store.MigrateUp(ctx, c.Int64("to"))
+							})
+						},
+					},
+					{
+						Name:  "down",
+						Usage: "roll back the last applied migrations",
+						Flags: []cli.Flag{
+							&cli.IntFlag{Name: "steps", Value: 1, Usage: "number of migrations to roll back"},
+						},
+						Action: func(c *cli.Context) error {
+							if c.Int("steps") < 1 {
+								return fmt.Errorf("steps must be positive, got %d", c.Int("steps"))
+							}
+							return migrate(c, func(ctx context.Context, store *storage.Storage) error {
+								return store.MigrateDown(ctx, c.Int("steps"))
+							})
+						},
+					},
+					{
+						Name:  "status",
+						Usage: "list the migrations and whether they are applied",
+						Action: func(c *cli.Context) error {
+							return migrate(c, printMigrationStatus)
+						},
+					},
+				},
+			},
+		},
+	}
+
+	return people.Run(args)
+}
+
+// migrate runs fn against the configured database without migrating it first
+func migrate(c *cli.Context, fn func(ctx context.Context, store *storage.Storage) error) error {
+	logger := newLogger()
+
+	cfg, err := LoadConfig(".", logger)
+	if err != nil {
+		return err
+	}
+
+	store, err := storage.Connect(c.Context, databaseUrl(cfg.Database), logger)
+	if err != nil {
+		logger.WithError(err).Errorln("Failed connecting to the database")
+		return err
+	}
+
+	defer store.Close()
+
+	return fn(c.Context, store)
+}
+
+func printMigrationStatus(ctx context.Context, store *storage.Storage) error {
+	statuses, err := store.MigrationStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT\tNOTE")
+	for _, status := range statuses {
+		appliedAt := "pending"
+		if status.AppliedAt != nil {
+			appliedAt = status.AppliedAt.Local().Format(time.DateTime)
+		}
+
+		note := ""
+		switch {
+		case status.Missing:
+			note = "not part of this build"
+		case status.Modified:
+			note = "changed after it was applied"
+		}
+
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", status.Version, status.Name, appliedAt, note)
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,174 @@
+package router
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"people/internal/types"
+)
+
+// maxAuditHeader bounds the X-Actor and X-Request-ID values stored with the audit events
+const maxAuditHeader = 200
+
+// auditInfo takes the actor of a request from X-Actor and its id from X-Request-ID, a missing id is generated.
+// The id is sent back, the audit events of the request can be found by it. The service does not authenticate
+// callers, the actor is recorded with the header source so that readers of the audit do not take it for proven
+func auditInfo() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := truncate(c.GetHeader("X-Request-ID"), maxAuditHeader)
+		if requestID == "" {
+			requestID = newRequestID()
+		}
+		actor, actorSource := truncate(c.GetHeader("X-Actor"), maxAuditHeader), types.ActorSourceHeader
+		if actor == "" {
+			actor, actorSource = "anonymous", types.ActorSourceAnonymous
+		}
+
+		c.Header("X-Request-ID", requestID)
+		c.Request = c.Request.WithContext(types.WithAuditInfo(c.Request.Context(), types.AuditInfo{
+			Actor:       actor,
+			ActorSource: actorSource,
+			RequestID:   requestID,
+		}))
+		c.Next()
+	}
+}
+
+func newRequestID() string {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// truncate keeps at most size bytes of valid UTF-8, Postgres rejects the audit info of the whole request otherwise
+func truncate(value string, size int) string {
+	value = strings.ToValidUTF8(value, "\uFFFD")
+	if len(value) <= size {
+		return value
+	}
+
+	// cut before the rune that does not fit
+	for size > 0 && !utf8.RuneStart(value[size]) {
+		size--
+	}
+	return value[:size]
+}
+
+// GetAuditEvents handler of GET request for the audit log
+// @Summary Get audit events
+// @Description Get a page of the changes of users, emails and friendships, the newest first.
+// @Description The actor is the X-Actor header of the request that made the change as the caller sent it, it is not
+// @Description authenticated: actor_source is header for such actors, anonymous without the header, service for the
+// @Description enrichment workers and the retention, system for changes outside of the service and unknown for events
+// @Description recorded before the source was.
+// @Description Pass next_cursor of a page as cursor to get the next one
+// @Tags audit
+//
+// @Produce json
+// @Param limit query int false "page size, 1 to 500" default(50)
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param actor query string false "actor"
+// @Param action query string false "action, e.g. user.update, user.delete, email.create, friendship.delete"
+// @Param entity query string false "entity" Enums(user, email, friendship)
+// @Param entity_id query string false "id of the entity, first-second user id for friendships"
+// @Param request_id query string false "X-Request-ID of the request that made the changes"
+// @Param from query string false "RFC 3339 time, events at or after it"
+// @Param to query string false "RFC 3339 time, events before it"
+//
+// @Success 200 {object} types.AuditPage
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 500 {object} types.ErrorResponse
+// @Router /api/v1/audit [get]
+func (s *Server) GetAuditEvents(c *gin.Context) {
+	s.getAuditEvents(c, nil)
+}
+
+// GetUserHistory handler of GET request for the audit events of a user
+// @Summary Get the history of a user
+// @Description Get a page of the changes of the user, its emails and its friendships, the newest first.
+// @Description The events outlive the user, a purge keeps them with before and after redacted and redacted_at set
+// @Tags audit
+//
+// @Produce json
+// @Param id path int true "User ID"
+// @Param limit query int false "page size, 1 to 500" default(50)
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param actor query string false "actor"
+// @Param action query string false "action, e.g. user.update"
+// @Param entity query string false "entity" Enums(user, email, friendship)
+// @Param from query string false "RFC 3339 time, events at or after it"
+// @Param to query string false "RFC 3339 time, events before it"
+//
+// @Success 200 {object} types.AuditPage
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 500 {object} types.ErrorResponse
+// @Router /api/v1/users/:id/history [get]
+func (s *Server) GetUserHistory(c *gin.Context) {
+	id := c.Param("id")
+
+	idUint, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		s.log.WithError(err).Errorln("Error getting user id")
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "Bad Request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	s.getAuditEvents(c, &idUint)
+}
+
+func (s *Server) getAuditEvents(c *gin.Context, userID *uint64) {
+	var req types.ListAuditRequest
+	err := c.ShouldBindQuery(&req)
+	if err != nil {
+		s.log.WithError(err).Errorln("Invalid audit query")
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "Bad Request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	validate := validator.New()
+	err = validate.Struct(req)
+	if err != nil {
+		s.log.Error("Invalid audit query", err)
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "Bad Request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx := context.Background()
+	page, err := s.usecase.GetAuditEvents(ctx, req, userID)
+	if err != nil {
+		if errors.Is(err, types.ErrInvalidCursor) || errors.Is(err, types.ErrInvalidFilter) {
+			s.log.WithError(err).Errorln("Invalid audit query")
+			c.JSON(http.StatusBadRequest, types.ErrorResponse{
+				Error:   "Bad Request",
+				Message: err.Error(),
+			})
+			return
+		}
+		s.log.WithError(err).Errorln("Error getting audit events")
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error:   "Server Error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+	return
+}
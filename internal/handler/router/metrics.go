@@ -0,0 +1,86 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"people/internal/types"
+)
+
+// breakerStates maps the circuit states to the values of the enrichment_circuit_state gauge
+var breakerStates = map[string]int{
+	types.BreakerClosed:   0,
+	types.BreakerHalfOpen: 1,
+	types.BreakerOpen:     2,
+}
+
+// Metrics handler of GET request for scraping the enrichment metrics in the Prometheus text format
+// @Summary Prometheus metrics
+// @Description Circuit breaker states and counters and the last known quotas of the enrichment providers
+// @Tags health
+//
+// @Produce plain
+//
+// @Success 200 {string} string
+// @Router /metrics [get]
+func (s *Server) Metrics(c *gin.Context) {
+	status := s.usecase.ProvidersStatus()
+
+	var b strings.Builder
+
+	writeMetric(&b, "enrichment_circuit_state", "gauge",
+		"Circuit breaker state of the enrichment provider: 0 closed, 1 half-open, 2 open")
+	for _, breaker := range status.Breakers {
+		fmt.Fprintf(&b, "enrichment_circuit_state{provider=%q} %d\n", breaker.Provider, breakerStates[breaker.State])
+	}
+
+	writeMetric(&b, "enrichment_circuit_consecutive_failures", "gauge",
+		"Consecutive failures of the enrichment provider")
+	for _, breaker := range status.Breakers {
+		fmt.Fprintf(&b, "enrichment_circuit_consecutive_failures{provider=%q} %d\n", breaker.Provider, breaker.ConsecutiveFailures)
+	}
+
+	writeMetric(&b, "enrichment_requests_total", "counter",
+		"Lookups let through to the enrichment provider")
+	for _, breaker := range status.Breakers {
+		fmt.Fprintf(&b, "enrichment_requests_total{provider=%q} %d\n", breaker.Provider, breaker.Requests)
+	}
+
+	writeMetric(&b, "enrichment_failures_total", "counter",
+		"Lookups that failed because of the enrichment provider")
+	for _, breaker := range status.Breakers {
+		fmt.Fprintf(&b, "enrichment_failures_total{provider=%q} %d\n", breaker.Provider, breaker.Failures)
+	}
+
+	writeMetric(&b, "enrichment_rejected_total", "counter",
+		"Lookups rejected by the open circuit of the enrichment provider")
+	for _, breaker := range status.Breakers {
+		fmt.Fprintf(&b, "enrichment_rejected_total{provider=%q} %d\n", breaker.Provider, breaker.Rejected)
+	}
+
+	// quotas are unknown until the upstream reported them
+	writeMetric(&b, "enrichment_quota_remaining", "gauge",
+		"Requests left in the current rate limit window of the enrichment provider")
+	for _, quota := range status.Quotas {
+		if quota.Remaining != nil {
+			fmt.Fprintf(&b, "enrichment_quota_remaining{provider=%q} %d\n", quota.Provider, *quota.Remaining)
+		}
+	}
+
+	writeMetric(&b, "enrichment_quota_limit", "gauge",
+		"Requests allowed per rate limit window of the enrichment provider")
+	for _, quota := range status.Quotas {
+		if quota.Limit != nil {
+			fmt.Fprintf(&b, "enrichment_quota_limit{provider=%q} %d\n", quota.Provider, *quota.Limit)
+		}
+	}
+
+	c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", []byte(b.String()))
+	return
+}
+
+func writeMetric(b *strings.Builder, name, kind, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
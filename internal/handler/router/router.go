@@ -12,24 +12,38 @@ import (
 
 func Router(server *Server) *gin.Engine {
 	router := gin.Default()
+	router.Use(auditInfo())
 	handler := New(server.usecase, server.log)
+	router.GET("/health", handler.Health)
+	router.GET("/metrics", handler.Metrics)
 	api := router.Group("/api/v1")
 	{
 		api.GET("/swagger", func(c *gin.Context) {
 			c.Redirect(http.StatusMovedPermanently, "/people/swagger/index.html")
 		})
 		api.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
-		api.GET("/users/:id", handler.GetUserInfoBySecondName)
+		api.GET("/users/search", handler.SearchUsers)
+		api.GET("/users/:id", handler.GetUserInfo)
 		api.GET("/users", handler.GetAllUsersInfo)
 		api.GET("/users/:id/emails", handler.GetUserEmails)
 		api.GET("/users/:id/friends", handler.GetUserFriends)
+		api.GET("/users/:id/enrichment", handler.GetEnrichmentState)
+		api.GET("/users/:id/history", handler.GetUserHistory)
+		api.GET("/audit", handler.GetAuditEvents)
+		api.GET("/enrichment/status", handler.GetProvidersStatus)
 		api.POST("/users", handler.CreateUser)
+		api.POST("/users/batch", handler.CreateUsers)
+		api.POST("/users/:id/enrich", handler.EnrichUser)
+		api.POST("/users/:id/restore", handler.RestoreUser)
+		api.POST("/enrichment/refresh", handler.RefreshEnrichment)
 		api.POST("/users/:id/emails", handler.AddUserEmails)
 		api.POST("/users/:id/friends", handler.AddUserFriends)
 		api.PUT("/users/:id", handler.UpdateUser)
+		api.PATCH("/users/:id", handler.PatchUser)
 		api.DELETE("/users/:id", handler.DeleteUser)
 		api.DELETE("/users/emails", handler.DeleteEmails)
 		api.DELETE("/users/:id/friends", handler.DeleteUserFriends)
+		api.DELETE("/admin/users/:id", handler.PurgeUser)
 	}
 	return router
 }
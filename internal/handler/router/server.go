@@ -3,43 +3,167 @@ package router
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"github.com/sirupsen/logrus"
 	"people/internal/types"
-	"people/internal/usecase"
 )
 
+// UseCase is what the handlers call, usecase.UseCase implements it
+type UseCase interface {
+	GetUserByID(ctx context.Context, id uint64) (types.UserInfo, error)
+	GetUserAsOf(ctx context.Context, id uint64, asOf time.Time) (types.UserSnapshot, error)
+	SearchUsers(ctx context.Context, req types.SearchUsersRequest) ([]types.UserMatch, error)
+	GetUserInfoBySecondName(ctx context.Context, name string) ([]types.UserInfo, error)
+	GetAllUsersInfo(ctx context.Context, req types.ListUsersRequest) (types.UsersPage, error)
+	GetUserEmails(ctx context.Context, id uint64) ([]types.Email, error)
+	GetUserFriends(ctx context.Context, id uint64) ([]types.Friend, error)
+	GetAuditEvents(ctx context.Context, req types.ListAuditRequest, userID *uint64) (types.AuditPage, error)
+
+	CreateUser(ctx context.Context, req types.CreateUserRequest) (types.CreateUserResponse, error)
+	CreateUsers(ctx context.Context, names []types.Name) []types.BatchUserResult
+	UpdateUser(ctx context.Context, user types.User, id uint64, ifMatch types.IfMatch) (uint64, error)
+	PatchUser(ctx context.Context, id uint64, patch types.Patch, ifMatch types.IfMatch) (types.UserInfo, error)
+	DeleteUser(ctx context.Context, id uint64, ifMatch types.IfMatch) error
+	RestoreUser(ctx context.Context, id uint64) (types.UserInfo, error)
+	PurgeUser(ctx context.Context, id uint64) error
+	AddUserEmails(ctx context.Context, emails types.EmailRequest, id uint64) ([]types.ItemResult, error)
+	AddUserFriends(ctx context.Context, friends types.Friends, userID uint64) ([]types.ItemResult, error)
+	DeleteEmails(ctx context.Context, emails []uint64) ([]types.ItemResult, error)
+	DeleteUserFriends(ctx context.Context, friendsPairs types.Friendships) ([]types.ItemResult, error)
+
+	GetEnrichmentState(ctx context.Context, id uint64) (types.EnrichmentState, error)
+	EnrichUser(ctx context.Context, id uint64) (types.EnrichResponse, error)
+	RefreshEnrichment(ctx context.Context, filter types.RefreshFilter) (int64, error)
+	ProvidersStatus() types.ProvidersStatus
+	Health(ctx context.Context) types.HealthResponse
+}
+
 type Server struct {
-	usecase *usecase.UseCase
+	usecase UseCase
 	log     *logrus.Logger
 }
 
-func New(uc *usecase.UseCase, log *logrus.Logger) *Server {
+func New(uc UseCase, log *logrus.Logger) *Server {
 	return &Server{
 		usecase: uc,
 		log:     log,
 	}
 }
 
-// GetUserInfoBySecondName handler of GET request for retrieving UserInfo by user`s second name
+// GetUserInfo handler of GET request for retrieving UserInfo by user`s id
 // @Summary Get user details
-// @Description Get user information with emails by second name
+// @Description Get user information with emails and the number of friends by id.
+// @Description A non-numeric id is still looked up as a last name for older clients, that path is deprecated
+// @Description in favour of GET /api/v1/users?last_name_exact=, which pages the same users. The ETag header carries the version of the user,
+// @Description a matching If-None-Match is answered with 304.
+// @Description With as_of the user, its emails and its friends are returned as they were at that time as types.UserSnapshot
+// @Description without the friends that were deleted at that time, like the friends of now leave out the deleted ones
 // @Tags people
 //
 // @Produce json
 // @Param id path int true "User ID"
+// @Param as_of query string false "RFC 3339 time, e.g. 2025-01-02T15:04:05Z"
+// @Param If-None-Match header string false "ETag of a cached version"
 //
-// @Success 200 {object} []types.UserInfo
+// @Success 200 {object} types.UserInfo
+// @Header 200 {string} ETag "version of the user"
+// @Success 304
 // @Failure 404 {object} types.ErrorResponse
 // @Failure 500 {object} types.ErrorResponse
 // @Router /api/v1/users/:id [get]
+func (s *Server) GetUserInfo(c *gin.Context) {
+	id := c.Param("id")
+
+	idUint, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		s.GetUserInfoBySecondName(c)
+		return
+	}
+
+	if value, ok := c.GetQuery("as_of"); ok {
+		asOf, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			s.log.WithError(err).Errorln("Invalid as_of")
+			c.JSON(http.StatusBadRequest, types.ErrorResponse{
+				Error:   "Bad Request",
+				Message: err.Error(),
+			})
+			return
+		}
+		s.getUserAsOf(c, idUint, asOf)
+		return
+	}
+
+	ctx := context.Background()
+	user, err := s.usecase.GetUserByID(ctx, idUint)
+	if err != nil {
+		if errors.Is(err, types.ErrNotFound) {
+			s.log.WithError(err).Errorln("user not found")
+			c.JSON(http.StatusNotFound, types.ErrorResponse{
+				Error:   "Not found Error",
+				Message: err.Error(),
+			})
+			return
+		}
+		s.log.WithError(err).Errorln("Error getting user Info")
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error:   "Server Error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.Header("ETag", types.ETag(user.Version))
+	if types.NoneMatch(c.GetHeader("If-None-Match"), user.Version) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": user})
+	return
+}
+
+// getUserAsOf answers GET /users/:id?as_of= with the user reconstructed from the history
+func (s *Server) getUserAsOf(c *gin.Context, id uint64, asOf time.Time) {
+	ctx := context.Background()
+	snapshot, err := s.usecase.GetUserAsOf(ctx, id, asOf)
+	if err != nil {
+		if errors.Is(err, types.ErrNotFound) {
+			s.log.WithError(err).Errorln("user not found")
+			c.JSON(http.StatusNotFound, types.ErrorResponse{
+				Error:   "Not found Error",
+				Message: err.Error(),
+			})
+			return
+		}
+		s.log.WithError(err).Errorln("Error getting user history")
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error:   "Server Error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": snapshot})
+	return
+}
+
+// GetUserInfoBySecondName answers GET /users/:id with a last name instead of an id the way it did before
+// lookups by id, the users are listed under "user". It is deprecated, the successor is GET /users?last_name_exact=.
+// last_name would not do, it matches a case-insensitive prefix
 func (s *Server) GetUserInfoBySecondName(c *gin.Context) {
 	name := c.Param("id")
 
+	c.Header("Deprecation", "true")
+	c.Header("Link", fmt.Sprintf(`</api/v1/users?%s>; rel="successor-version"`, url.Values{"last_name_exact": {name}}.Encode()))
+
 	ctx := context.Background()
 
 	users, err := s.usecase.GetUserInfoBySecondName(ctx, name)
@@ -64,22 +188,116 @@ func (s *Server) GetUserInfoBySecondName(c *gin.Context) {
 	return
 }
 
+// SearchUsers handler of GET request for searching users by name
+// @Summary Search users
+// @Description Fuzzy search over first and last names, tolerant of case, diacritics and Cyrillic or Latin spelling.
+// @Description The best matches come first
+// @Tags people
+//
+// @Produce json
+// @Param q query string true "a name or a part of it, e.g. ivanov or Иванов"
+// @Param limit query int false "maximal number of results, 1 to 100" default(20)
+//
+// @Success 200 {object} []types.UserMatch
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 500 {object} types.ErrorResponse
+// @Router /api/v1/users/search [get]
+func (s *Server) SearchUsers(c *gin.Context) {
+	var req types.SearchUsersRequest
+	err := c.ShouldBindQuery(&req)
+	if err != nil {
+		s.log.WithError(err).Errorln("Invalid search query")
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "Bad Request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	validate := validator.New()
+	err = validate.Struct(req)
+	if err != nil {
+		s.log.Error("Invalid search query", err)
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "Bad Request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx := context.Background()
+	matches, err := s.usecase.SearchUsers(ctx, req)
+	if err != nil {
+		s.log.WithError(err).Errorln("Error searching users")
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error:   "Server Error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"users": matches})
+	return
+}
+
 // GetAllUsersInfo handler of GET request for retrieving info about all users
 // @Summary Get all users details
-// @Description Get users information with emails
+// @Description Get a page of users with emails, filtered and sorted. Pass next_cursor of a page as cursor to get the next one
 // @Tags people
 //
 // @Produce json
+// @Param limit query int false "page size, 1 to 500" default(50)
+// @Param cursor query string false "next_cursor of the previous page, issued for the same sort"
+// @Param sort query string false "sort order, a leading - sorts descending" Enums(id, -id, age, -age, first_name, -first_name, last_name, -last_name) default(id)
+// @Param gender query string false "gender" Enums(male, female)
+// @Param nationality query string false "comma separated ISO 3166-1 codes or country names, e.g. DE,FR"
+// @Param region query string false "region name or code: Africa (AF), Asia (AS), Europe (EU), North America (NA), South America (SA), Oceania (OC), Antarctica (AN)"
+// @Param age_min query int false "minimal age"
+// @Param age_max query int false "maximal age"
+// @Param first_name query string false "case-insensitive first name prefix"
+// @Param last_name query string false "case-insensitive last name prefix"
+// @Param last_name_exact query string false "whole last name, case-sensitive, the successor of the deprecated GET /api/v1/users/{last_name}"
+// @Param has_email query bool false "only users with (true) or without (false) emails"
 //
-// @Success 200 {object} []types.UserInfo
+// @Success 200 {object} types.UsersPage
 // @Failure 400 {object} types.ErrorResponse
 // @Failure 404 {object} types.ErrorResponse
 // @Failure 500 {object} types.ErrorResponse
 // @Router /api/v1/users [get]
 func (s *Server) GetAllUsersInfo(c *gin.Context) {
+	var req types.ListUsersRequest
+	err := c.ShouldBindQuery(&req)
+	if err != nil {
+		s.log.WithError(err).Errorln("Invalid users query")
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "Bad Request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	validate := validator.New()
+	err = validate.Struct(req)
+	if err != nil {
+		s.log.Error("Invalid users query", err)
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "Bad Request",
+			Message: err.Error(),
+		})
+		return
+	}
+
 	ctx := context.Background()
-	users, err := s.usecase.GetAllUsersInfo(ctx)
+	page, err := s.usecase.GetAllUsersInfo(ctx, req)
 	if err != nil {
+		if errors.Is(err, types.ErrInvalidCountry) || errors.Is(err, types.ErrInvalidCursor) || errors.Is(err, types.ErrInvalidFilter) {
+			s.log.WithError(err).Errorln("Invalid users query")
+			c.JSON(http.StatusBadRequest, types.ErrorResponse{
+				Error:   "Bad Request",
+				Message: err.Error(),
+			})
+			return
+		}
 		if errors.Is(err, types.ErrNotFound) {
 			s.log.WithError(err).Errorln("Users not found")
 			c.JSON(http.StatusNotFound, types.ErrorResponse{
@@ -95,7 +313,7 @@ func (s *Server) GetAllUsersInfo(c *gin.Context) {
 		})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"users": users})
+	c.JSON(http.StatusOK, page)
 	return
 }
 
@@ -199,23 +417,266 @@ func (s *Server) GetUserFriends(c *gin.Context) {
 	return
 }
 
+// GetEnrichmentState handler of GET request for retrieving the enrichment progress of the user
+// @Summary Get user enrichment status
+// @Description Get the status of every enriched attribute and the latest enrichment job of the user
+// @Tags people
+//
+// @Produce json
+// @Param id path int true "User ID"
+//
+// @Success 200 {object} types.EnrichmentState
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 500 {object} types.ErrorResponse
+// @Router /api/v1/users/:id/enrichment [get]
+func (s *Server) GetEnrichmentState(c *gin.Context) {
+	id := c.Param("id")
+
+	idUint, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		s.log.WithError(err).Errorln("Error getting user id")
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "Bad Request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	state, err := s.usecase.GetEnrichmentState(ctx, idUint)
+	if err != nil {
+		if errors.Is(err, types.ErrNotFound) {
+			s.log.WithError(err).Errorln("User not found")
+			c.JSON(http.StatusNotFound, types.ErrorResponse{
+				Error:   "Not found Error",
+				Message: err.Error(),
+			})
+			return
+		}
+		s.log.WithError(err).Errorln("Error getting enrichment state")
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error:   "Server Error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, state)
+	return
+}
+
+// EnrichUser handler of POST request for running the enrichment of an existing user again
+// @Summary Re-enrich user
+// @Description Re-run age, gender and nationality lookups for the user and store what changed,
+// @Description attributes that could not be resolved keep their values
+// @Tags people
+//
+// @Produce json
+// @Param id path int true "User ID"
+//
+// @Success 200 {object} types.EnrichResponse
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 500 {object} types.ErrorResponse
+// @Failure 503 {object} types.ErrorResponse
+// @Failure 504 {object} types.ErrorResponse
+// @Router /api/v1/users/:id/enrich [post]
+func (s *Server) EnrichUser(c *gin.Context) {
+	id := c.Param("id")
+
+	idUint, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		s.log.WithError(err).Errorln("Error getting user id")
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "Bad Request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	response, err := s.usecase.EnrichUser(ctx, idUint)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			s.log.WithError(err).Errorln("Enrichment timed out")
+			c.JSON(http.StatusGatewayTimeout, types.ErrorResponse{
+				Error:   "Gateway Timeout",
+				Message: err.Error(),
+			})
+			return
+		}
+		if delay, ok := unavailableFor(err); ok {
+			s.log.WithError(err).Errorln("Enrichment provider is unavailable")
+			c.Header("Retry-After", retryAfter(delay))
+			c.JSON(http.StatusServiceUnavailable, types.ErrorResponse{
+				Error:   "Service Unavailable",
+				Message: err.Error(),
+			})
+			return
+		}
+		if errors.Is(err, types.ErrNotFound) {
+			s.log.WithError(err).Errorln("User not found")
+			c.JSON(http.StatusNotFound, types.ErrorResponse{
+				Error:   "Not found Error",
+				Message: err.Error(),
+			})
+			return
+		}
+		s.log.WithError(err).Errorln("Error enriching user")
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error:   "Server Error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+	return
+}
+
+// Health handler of GET request for checking the service
+// @Summary Health check
+// @Description Reports the database and the circuit breakers of the enrichment providers,
+// @Description degraded while some provider is short-circuited, 503 when the database is unavailable
+// @Tags health
+//
+// @Produce json
+//
+// @Success 200 {object} types.HealthResponse
+// @Failure 503 {object} types.HealthResponse
+// @Router /health [get]
+func (s *Server) Health(c *gin.Context) {
+	health := s.usecase.Health(c.Request.Context())
+
+	if health.Status == types.HealthUnavailable {
+		c.JSON(http.StatusServiceUnavailable, health)
+		return
+	}
+
+	c.JSON(http.StatusOK, health)
+	return
+}
+
+// GetProvidersStatus handler of GET request for retrieving the quotas and circuit breakers of the enrichment upstreams
+// @Summary Get enrichment providers status
+// @Description Get the rate limit quota of every enrichment upstream as reported by its last response,
+// @Description unknown values are null, and the state of its circuit breaker
+// @Tags enrichment
+//
+// @Produce json
+//
+// @Success 200 {object} types.ProvidersStatus
+// @Router /api/v1/enrichment/status [get]
+func (s *Server) GetProvidersStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, s.usecase.ProvidersStatus())
+	return
+}
+
+// RefreshEnrichment handler of POST request for re-enriching many users
+// @Summary Bulk re-enrichment
+// @Description Queue enrichment jobs for users enriched longer ago than older_than and/or with missing attributes,
+// @Description users with a job in progress are skipped
+// @Tags enrichment
+//
+// @Accept json
+// @Produce json
+// @Param req body types.RefreshRequest true "users filter"
+//
+// @Success 202 {object} types.RefreshResponse
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 503 {object} types.ErrorResponse "queue.workers is 0, nothing would process the jobs"
+// @Failure 500 {object} types.ErrorResponse
+// @Router /api/v1/enrichment/refresh [post]
+func (s *Server) RefreshEnrichment(c *gin.Context) {
+	var req types.RefreshRequest
+	err := c.Bind(&req)
+	if err != nil {
+		s.log.WithError(err).Errorln("Invalid refresh filter")
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "Bad Request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	validate := validator.New()
+	err = validate.Struct(req)
+	if err != nil {
+		s.log.Error("Invalid refresh filter", err)
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "Bad Request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	filter := types.RefreshFilter{
+		MissingOnly: req.MissingOnly,
+		Limit:       req.Limit,
+	}
+
+	if req.OlderThan != "" {
+		olderThan, err := time.ParseDuration(req.OlderThan)
+		if err != nil || olderThan <= 0 {
+			s.log.WithError(err).Errorln("Invalid older_than")
+			c.JSON(http.StatusBadRequest, types.ErrorResponse{
+				Error:   "Bad Request",
+				Message: fmt.Sprintf("older_than must be a positive duration, got %q", req.OlderThan),
+			})
+			return
+		}
+		filter.EnrichedBefore = time.Now().Add(-olderThan)
+	}
+
+	ctx := c.Request.Context()
+	queued, err := s.usecase.RefreshEnrichment(ctx, filter)
+	if errors.Is(err, types.ErrNoWorkers) {
+		c.JSON(http.StatusServiceUnavailable, types.ErrorResponse{
+			Error:   "Service Unavailable",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		s.log.WithError(err).Errorln("Error queueing enrichment refresh")
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error:   "Server Error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, types.RefreshResponse{
+		Queued: queued,
+	})
+	return
+}
+
 // CreateUser handler of POST request for add user
 // @Summary process POST req for add user
-// @Description process POST req for add user
+// @Description process POST req for add user, in the async enrichment mode the user is stored right away
+// @Description and 202 points to the enrichment status in the Location header.
+// @Description The emails and the friends are stored with the user or, when one of them is rejected, nothing is stored
+// @Description and 409 reports every email and friend like the other item batches do
 // @Tags people
 //
 // @Accept json
 // @Produce json
-// @Param req body types.Name true "first name and second name"
+// @Param req body types.CreateUserRequest true "first name and second name, optionally emails and friends"
 //
-// @Success 200 {object} uint64
+// @Success 200 {object} types.CreateUserResponse
+// @Success 202 {object} types.CreateUserResponse
 // @Failure 400 {object} types.ErrorResponse
 // @Failure 404 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
 // @Failure 500 {object} types.ErrorResponse
+// @Failure 503 {object} types.ErrorResponse
+// @Failure 504 {object} types.ErrorResponse
 // @Router /api/v1/users [post]
 func (s *Server) CreateUser(c *gin.Context) {
-	var name types.Name
-	err := c.Bind(&name)
+	var req types.CreateUserRequest
+	err := c.Bind(&req)
 	if err != nil {
 		s.log.WithError(err).Errorln("Invalid name")
 		c.JSON(http.StatusBadRequest, types.ErrorResponse{
@@ -226,7 +687,7 @@ func (s *Server) CreateUser(c *gin.Context) {
 	}
 
 	validate := validator.New()
-	err = validate.Struct(name)
+	err = validate.Struct(req)
 	if err != nil {
 		s.log.Error("Invalid first name", err)
 		c.JSON(http.StatusBadRequest, types.ErrorResponse{
@@ -236,9 +697,30 @@ func (s *Server) CreateUser(c *gin.Context) {
 		return
 	}
 
-	ctx := context.Background()
-	id, err := s.usecase.CreateUser(ctx, name)
+	// the request context cancels the enrichment lookups when the client disconnects
+	ctx := c.Request.Context()
+	response, err := s.usecase.CreateUser(ctx, req)
 	if err != nil {
+		if s.rejectedItems(c, err) {
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			s.log.WithError(err).Errorln("Enrichment timed out")
+			c.JSON(http.StatusGatewayTimeout, types.ErrorResponse{
+				Error:   "Gateway Timeout",
+				Message: err.Error(),
+			})
+			return
+		}
+		if delay, ok := unavailableFor(err); ok {
+			s.log.WithError(err).Errorln("Enrichment provider is unavailable")
+			c.Header("Retry-After", retryAfter(delay))
+			c.JSON(http.StatusServiceUnavailable, types.ErrorResponse{
+				Error:   "Service Unavailable",
+				Message: err.Error(),
+			})
+			return
+		}
 		if errors.Is(err, types.ErrNotFound) {
 			s.log.WithError(err).Errorln("Nationality not found")
 			c.JSON(http.StatusNotFound, types.ErrorResponse{
@@ -255,13 +737,95 @@ func (s *Server) CreateUser(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"user_id": id})
+	// in the async mode the enrichment is still running, the client polls its status
+	if response.JobID != 0 {
+		c.Header("Location", fmt.Sprintf("/api/v1/users/%d/enrichment", response.UserID))
+		c.JSON(http.StatusAccepted, response)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+	return
+}
+
+// CreateUsers handler of POST request for add many users at once
+// @Summary process POST req for add many users
+// @Description enriches the first names with upstream batch requests and reports the result of every row,
+// @Description 207 is returned when some of the rows failed. When no row was stored because an enrichment
+// @Description provider is rate limited or unavailable 503 is returned instead, both carry Retry-After then
+// @Tags people
+//
+// @Accept json
+// @Produce json
+// @Param req body types.BatchCreateUsersRequest true "list of first and second names"
+//
+// @Success 200 {object} types.BatchCreateUsersResponse
+// @Success 207 {object} types.BatchCreateUsersResponse
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 503 {object} types.BatchCreateUsersResponse
+// @Router /api/v1/users/batch [post]
+func (s *Server) CreateUsers(c *gin.Context) {
+	var req types.BatchCreateUsersRequest
+	err := c.Bind(&req)
+	if err != nil {
+		s.log.WithError(err).Errorln("Invalid users")
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "Bad Request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	validate := validator.New()
+	err = validate.Struct(req)
+	if err != nil {
+		s.log.Error("Invalid users list", err)
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "Bad Request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	results := s.usecase.CreateUsers(ctx, req.Users)
+
+	status := http.StatusOK
+	stored := false
+	var delay time.Duration
+	unavailable := false
+	for _, result := range results {
+		if result.Error == "" {
+			stored = true
+			continue
+		}
+		status = http.StatusMultiStatus
+		if rowDelay, ok := unavailableFor(result.Err); ok {
+			delay = max(delay, rowDelay)
+			unavailable = true
+		}
+	}
+
+	// the rows may be sent again once the upstream accepts requests
+	if unavailable {
+		s.log.Errorln("Enrichment provider is unavailable for the batch")
+		c.Header("Retry-After", retryAfter(delay))
+		if !stored {
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	c.JSON(status, types.BatchCreateUsersResponse{
+		Results: results,
+	})
 	return
 }
 
 // AddUserEmails handler of POST request for add user`s emails
 // @Summary process POST req for add user`s emails
-// @Description process POST req for add user`s emails
+// @Description process POST req for add user`s emails,
+// @Description all of them or none: when one item is not stored 409 reports every item and nothing is changed
+// @Description an email of a deleted user is a conflict until that user is purged
 // @Tags people
 //
 // @Accept json
@@ -269,8 +833,10 @@ func (s *Server) CreateUser(c *gin.Context) {
 // @Param id path int true "User ID"
 // @Param req body types.EmailRequest true "list of user`s emails"
 //
-// @Success 200 {object} types.SuccessResponse
+// @Success 200 {object} types.BatchItemsResponse
 // @Failure 400 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
 // @Failure 500 {object} types.ErrorResponse
 // @Router /api/v1/users/:id/emails [post]
 func (s *Server) AddUserEmails(c *gin.Context) {
@@ -308,10 +874,21 @@ func (s *Server) AddUserEmails(c *gin.Context) {
 		return
 	}
 
-	ctx := context.Background()
+	ctx := context.WithoutCancel(c.Request.Context())
 
-	err = s.usecase.AddUserEmails(ctx, emails, idUint)
+	results, err := s.usecase.AddUserEmails(ctx, emails, idUint)
 	if err != nil {
+		if errors.Is(err, types.ErrNotFound) {
+			s.log.WithError(err).Errorln("User not found")
+			c.JSON(http.StatusNotFound, types.ErrorResponse{
+				Error:   "Not found Error",
+				Message: err.Error(),
+			})
+			return
+		}
+		if s.rejectedItems(c, err) {
+			return
+		}
 		s.log.WithError(err).Errorln("Error adding user`s emails")
 		c.JSON(http.StatusInternalServerError, types.ErrorResponse{
 			Error:   "Server Error",
@@ -320,15 +897,16 @@ func (s *Server) AddUserEmails(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, types.SuccessResponse{
-		Message: "Emails added successfully",
+	c.JSON(http.StatusOK, types.BatchItemsResponse{
+		Results: results,
 	})
 	return
 }
 
 // AddUserFriends handler of POST request for add user`s friends
 // @Summary process POST req for add user`s friends
-// @Description process POST req for add user`s friends
+// @Description process POST req for add user`s friends,
+// @Description all of them or none: when one item is not stored 409 reports every item and nothing is changed
 // @Tags people
 //
 // @Accept json
@@ -336,8 +914,10 @@ func (s *Server) AddUserEmails(c *gin.Context) {
 // @Param id path int true "User ID"
 // @Param req body types.Friends true "list of user`s friends"
 //
-// @Success 200 {object} types.SuccessResponse
+// @Success 200 {object} types.BatchItemsResponse
 // @Failure 400 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
 // @Failure 500 {object} types.ErrorResponse
 // @Router /api/v1/users/:id/friends [post]
 func (s *Server) AddUserFriends(c *gin.Context) {
@@ -374,9 +954,20 @@ func (s *Server) AddUserFriends(c *gin.Context) {
 		return
 	}
 
-	ctx := context.Background()
-	err = s.usecase.AddUserFriends(ctx, friends, idUint)
+	ctx := context.WithoutCancel(c.Request.Context())
+	results, err := s.usecase.AddUserFriends(ctx, friends, idUint)
 	if err != nil {
+		if errors.Is(err, types.ErrNotFound) {
+			s.log.WithError(err).Errorln("User not found")
+			c.JSON(http.StatusNotFound, types.ErrorResponse{
+				Error:   "Not found Error",
+				Message: err.Error(),
+			})
+			return
+		}
+		if s.rejectedItems(c, err) {
+			return
+		}
 		s.log.WithError(err).Errorln("Error adding user`s friends")
 		c.JSON(http.StatusInternalServerError, types.ErrorResponse{
 			Error:   "Server Error",
@@ -385,24 +976,30 @@ func (s *Server) AddUserFriends(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, types.SuccessResponse{
-		Message: "Friends added successfully",
+	c.JSON(http.StatusOK, types.BatchItemsResponse{
+		Results: results,
 	})
 	return
 }
 
 // UpdateUser handler of PUT request for edite user`s info
 // @Summary process PUT request for edite user`s info
-// @Description process PUT request for edite user`s info
+// @Description process PUT request for edite user`s info, nationality is an ISO 3166-1 code or an English country name
+// @Description and is stored as alpha-2. Without country_hint the stored hint is kept, PATCH clears it.
+// @Description With If-Match the user is only replaced at that version
 // @Tags people
 //
 // @Accept json
 // @Produce json
 // @Param id path int true "User ID"
+// @Param If-Match header string false "ETag the user must still have"
 // @Param req body types.User true "user`s info"
 //
 // @Success 200 {object} types.SuccessResponse
+// @Header 200 {string} ETag "new version of the user"
 // @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 412 {object} types.ErrorResponse
 // @Failure 500 {object} types.ErrorResponse
 // @Router /api/v1/users/:id [put]
 func (s *Server) UpdateUser(c *gin.Context) {
@@ -440,9 +1037,20 @@ func (s *Server) UpdateUser(c *gin.Context) {
 		return
 	}
 
-	ctx := context.Background()
-	err = s.usecase.UpdateUser(ctx, user, idUint)
+	ctx := context.WithoutCancel(c.Request.Context())
+	version, err := s.usecase.UpdateUser(ctx, user, idUint, ifMatch(c))
 	if err != nil {
+		if errors.Is(err, types.ErrInvalidCountry) {
+			s.log.WithError(err).Errorln("Invalid nationality")
+			c.JSON(http.StatusBadRequest, types.ErrorResponse{
+				Error:   "Bad Request",
+				Message: err.Error(),
+			})
+			return
+		}
+		if s.writeConflict(c, err) {
+			return
+		}
 		s.log.WithError(err).Errorln("Error updating user")
 		c.JSON(http.StatusInternalServerError, types.ErrorResponse{
 			Error:   "Server Error",
@@ -451,23 +1059,126 @@ func (s *Server) UpdateUser(c *gin.Context) {
 		return
 	}
 
+	c.Header("ETag", types.ETag(version))
 	c.JSON(http.StatusOK, types.SuccessResponse{
 		Message: "User update successfully",
 	})
 	return
 }
 
+// PatchUser handler of PATCH request for partial update of user`s info
+// @Summary process PATCH request for partial update of user`s info
+// @Description process PATCH request for partial update of user`s info, the body is a JSON Merge Patch (RFC 7396)
+// @Description with Content-Type application/merge-patch+json or application/json, or a JSON Patch (RFC 6902)
+// @Description with Content-Type application/json-patch+json. Only the changed columns are written,
+// @Description with If-Match only at that version
+// @Tags people
+//
+// @Accept json
+// @Accept application/merge-patch+json
+// @Accept application/json-patch+json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param If-Match header string false "ETag the user must still have"
+// @Param req body object true "merge patch or JSON patch of types.User"
+//
+// @Success 200 {object} types.UserInfo
+// @Header 200 {string} ETag "new version of the user"
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
+// @Failure 412 {object} types.ErrorResponse
+// @Failure 415 {object} types.ErrorResponse
+// @Failure 422 {object} types.ErrorResponse
+// @Failure 500 {object} types.ErrorResponse
+// @Router /api/v1/users/:id [patch]
+func (s *Server) PatchUser(c *gin.Context) {
+	id := c.Param("id")
+
+	idUint, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		s.log.WithError(err).Errorln("Error getting user id")
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "Bad Request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	body, err := c.GetRawData()
+	if err != nil {
+		s.log.WithError(err).Errorln("Error reading patch")
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "Bad Request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	mediaType := c.ContentType()
+	if mediaType == gin.MIMEJSON {
+		mediaType = types.MergePatchMediaType
+	}
+
+	ctx := context.WithoutCancel(c.Request.Context())
+	user, err := s.usecase.PatchUser(ctx, idUint, types.Patch{MediaType: mediaType, Body: body}, ifMatch(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, types.ErrUnsupportedPatch):
+			s.log.WithError(err).Errorln("Unsupported patch")
+			c.JSON(http.StatusUnsupportedMediaType, types.ErrorResponse{
+				Error:   "Unsupported Media Type",
+				Message: err.Error(),
+			})
+		case errors.Is(err, types.ErrInvalidPatch):
+			s.log.WithError(err).Errorln("Invalid patch")
+			c.JSON(http.StatusBadRequest, types.ErrorResponse{
+				Error:   "Bad Request",
+				Message: err.Error(),
+			})
+		case errors.Is(err, types.ErrPatchConflict):
+			s.log.WithError(err).Errorln("Patch does not apply")
+			c.JSON(http.StatusConflict, types.ErrorResponse{
+				Error:   "Conflict",
+				Message: err.Error(),
+			})
+		case errors.Is(err, types.ErrInvalidUser):
+			s.log.WithError(err).Errorln("Invalid patched user")
+			c.JSON(http.StatusUnprocessableEntity, types.ErrorResponse{
+				Error:   "Unprocessable Entity",
+				Message: err.Error(),
+			})
+		case s.writeConflict(c, err):
+		default:
+			s.log.WithError(err).Errorln("Error patching user")
+			c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+				Error:   "Server Error",
+				Message: err.Error(),
+			})
+		}
+		return
+	}
+
+	c.Header("ETag", types.ETag(user.Version))
+	c.JSON(http.StatusOK, gin.H{"user": user})
+	return
+}
+
 // DeleteUser handler of DELETE req for deleting user`s info
 // @Summary process DELETE request for deleting user`s info
-// @Description process DELETE request for deleting user`s info
+// @Description process DELETE request for deleting user`s info, with If-Match only at that version.
+// @Description The user is hidden and can be restored until the retention purges it
 // @Tags people
 //
 // @Accept json
 // @Produce json
 // @Param id path int true "User ID"
+// @Param If-Match header string false "ETag the user must still have"
 //
 // @Success 200 {object} types.SuccessResponse
 // @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 412 {object} types.ErrorResponse
 // @Failure 500 {object} types.ErrorResponse
 // @Router /api/v1/users/:id [delete]
 func (s *Server) DeleteUser(c *gin.Context) {
@@ -483,9 +1194,12 @@ func (s *Server) DeleteUser(c *gin.Context) {
 		return
 	}
 
-	ctx := context.Background()
-	err = s.usecase.DeleteUser(ctx, idUint)
+	ctx := context.WithoutCancel(c.Request.Context())
+	err = s.usecase.DeleteUser(ctx, idUint, ifMatch(c))
 	if err != nil {
+		if s.writeConflict(c, err) {
+			return
+		}
 		s.log.WithError(err).Errorln("Error deleting user")
 		c.JSON(http.StatusInternalServerError, types.ErrorResponse{
 			Error:   "Server Error",
@@ -500,17 +1214,117 @@ func (s *Server) DeleteUser(c *gin.Context) {
 	return
 }
 
+// RestoreUser handler of POST request for restoring a deleted user
+// @Summary process POST request for restoring a deleted user
+// @Description process POST request for restoring a deleted user, its emails and friendships come back with it
+// @Tags people
+//
+// @Produce json
+// @Param id path int true "User ID"
+//
+// @Success 200 {object} types.UserInfo
+// @Header 200 {string} ETag "new version of the user"
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
+// @Failure 500 {object} types.ErrorResponse
+// @Router /api/v1/users/:id/restore [post]
+func (s *Server) RestoreUser(c *gin.Context) {
+	id := c.Param("id")
+
+	idUint, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		s.log.WithError(err).Errorln("Error getting user id")
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "Bad Request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx := context.WithoutCancel(c.Request.Context())
+	user, err := s.usecase.RestoreUser(ctx, idUint)
+	if err != nil {
+		if s.notDeleted(c, err) {
+			return
+		}
+		s.log.WithError(err).Errorln("Error restoring user")
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error:   "Server Error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.Header("ETag", types.ETag(user.Version))
+	c.JSON(http.StatusOK, gin.H{"user": user})
+	return
+}
+
+// PurgeUser handler of DELETE request for removing a deleted user for good
+// @Summary process DELETE request for removing a deleted user for good
+// @Description process DELETE request for removing a deleted user for good together with its emails and friendships,
+// @Description without waiting for the retention. Only deleted users can be purged.
+// @Description The personal data goes with it: its history is removed, its audit events keep the actor, action and
+// @Description ids but before and after are redacted
+// @Tags admin
+//
+// @Produce json
+// @Param id path int true "User ID"
+//
+// @Success 200 {object} types.SuccessResponse
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
+// @Failure 500 {object} types.ErrorResponse
+// @Router /api/v1/admin/users/:id [delete]
+func (s *Server) PurgeUser(c *gin.Context) {
+	id := c.Param("id")
+
+	idUint, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		s.log.WithError(err).Errorln("Error getting user id")
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:   "Bad Request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx := context.WithoutCancel(c.Request.Context())
+	err = s.usecase.PurgeUser(ctx, idUint)
+	if err != nil {
+		if s.notDeleted(c, err) {
+			return
+		}
+		s.log.WithError(err).Errorln("Error purging user")
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error:   "Server Error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, types.SuccessResponse{
+		Message: "Purged user successfully",
+	})
+	return
+}
+
 // DeleteEmails handler of DELETE req to delete emails (one or more)
 // @Summary process DELETE request to delete emails (one or more)
-// @Description process DELETE request to delete emails (one or more)
+// @Description process DELETE request to delete emails (one or more),
+// @Description all of them or none: when one item is not stored 409 reports every item and nothing is changed
+// @Description the emails of deleted users are not_found, they come back with a restore
 // @Tags people
 //
 // @Accept json
 // @Produce json
 // @Param req body types.EmailIDs true "list email`s ids"
 //
-// @Success 200 {object} types.SuccessResponse
+// @Success 200 {object} types.BatchItemsResponse
 // @Failure 400 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
 // @Failure 500 {object} types.ErrorResponse
 // @Router /api/v1/users/emails [delete]
 func (s *Server) DeleteEmails(c *gin.Context) {
@@ -525,10 +1339,13 @@ func (s *Server) DeleteEmails(c *gin.Context) {
 		return
 	}
 
-	ctx := context.Background()
+	ctx := context.WithoutCancel(c.Request.Context())
 
-	err = s.usecase.DeleteEmails(ctx, emailIDs.IDs)
+	results, err := s.usecase.DeleteEmails(ctx, emailIDs.IDs)
 	if err != nil {
+		if s.rejectedItems(c, err) {
+			return
+		}
 		s.log.WithError(err).Errorln("Error deleting emails")
 		c.JSON(http.StatusInternalServerError, types.ErrorResponse{
 			Error:   "Server Error",
@@ -537,15 +1354,17 @@ func (s *Server) DeleteEmails(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, types.SuccessResponse{
-		Message: "Emails deleted successfully",
+	c.JSON(http.StatusOK, types.BatchItemsResponse{
+		Results: results,
 	})
 	return
 }
 
 // DeleteUserFriends handler of DELETE req to delete user`s friendships (one or more)
 // @Summary process DELETE request to delete friendships (one or more)
-// @Description process DELETE request to delete friendships (one or more)
+// @Description process DELETE request to delete friendships (one or more),
+// @Description all of them or none: when one item is not stored 409 reports every item and nothing is changed
+// @Description the friendships of deleted users are not_found, they come back with a restore
 // @Tags people
 //
 // @Accept json
@@ -553,8 +1372,9 @@ func (s *Server) DeleteEmails(c *gin.Context) {
 // @Param id path int true "User ID"
 // @Param req body types.Friendships true "list of friendships"
 //
-// @Success 200 {object} types.SuccessResponse
+// @Success 200 {object} types.BatchItemsResponse
 // @Failure 400 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
 // @Failure 500 {object} types.ErrorResponse
 // @Router /api/v1/users/:id/friends [delete]
 func (s *Server) DeleteUserFriends(c *gin.Context) {
@@ -569,9 +1389,12 @@ func (s *Server) DeleteUserFriends(c *gin.Context) {
 		return
 	}
 
-	ctx := context.Background()
-	err = s.usecase.DeleteUserFriends(ctx, friendPairs)
+	ctx := context.WithoutCancel(c.Request.Context())
+	results, err := s.usecase.DeleteUserFriends(ctx, friendPairs)
 	if err != nil {
+		if s.rejectedItems(c, err) {
+			return
+		}
 		s.log.WithError(err).Errorln("Error deleting user`s friends")
 		c.JSON(http.StatusInternalServerError, types.ErrorResponse{
 			Error:   "Server Error",
@@ -580,8 +1403,98 @@ func (s *Server) DeleteUserFriends(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, types.SuccessResponse{
-		Message: "Friendships deleted successfully",
+	c.JSON(http.StatusOK, types.BatchItemsResponse{
+		Results: results,
 	})
 	return
 }
+
+// unavailableFor returns how long an enrichment provider is known to reject lookups,
+// because of its quota or an open circuit
+func unavailableFor(err error) (time.Duration, bool) {
+	var rateLimitErr *types.RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		return rateLimitErr.RetryAfter, true
+	}
+	var circuitErr *types.CircuitOpenError
+	if errors.As(err, &circuitErr) {
+		return circuitErr.RetryAfter, true
+	}
+	return 0, false
+}
+
+// ifMatch reads the If-Match header, without it a write has no precondition
+func ifMatch(c *gin.Context) types.IfMatch {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		return nil
+	}
+	return types.IfMatch(types.ParseETags(header))
+}
+
+// writeConflict answers a write on a missing user with 404 and on a stale If-Match with 412,
+// it reports whether err was one of them
+func (s *Server) writeConflict(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, types.ErrNotFound):
+		s.log.WithError(err).Errorln("User not found")
+		c.JSON(http.StatusNotFound, types.ErrorResponse{
+			Error:   "Not found Error",
+			Message: err.Error(),
+		})
+	case errors.Is(err, types.ErrPreconditionFailed):
+		s.log.WithError(err).Errorln("User was changed in the meantime")
+		c.JSON(http.StatusPreconditionFailed, types.ErrorResponse{
+			Error:   "Precondition Failed",
+			Message: err.Error(),
+		})
+	default:
+		return false
+	}
+	return true
+}
+
+// notDeleted answers a restore or a purge of a missing user with 404 and of an active user with 409,
+// it reports whether err was one of them
+func (s *Server) notDeleted(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, types.ErrNotFound):
+		s.log.WithError(err).Errorln("User not found")
+		c.JSON(http.StatusNotFound, types.ErrorResponse{
+			Error:   "Not found Error",
+			Message: err.Error(),
+		})
+	case errors.Is(err, types.ErrNotDeleted):
+		s.log.WithError(err).Errorln("User is not deleted")
+		c.JSON(http.StatusConflict, types.ErrorResponse{
+			Error:   "Conflict",
+			Message: err.Error(),
+		})
+	default:
+		return false
+	}
+	return true
+}
+
+// rejectedItems answers a batch that was rejected because of some of its items with 409 reporting
+// every item, it reports whether err was one
+func (s *Server) rejectedItems(c *gin.Context, err error) bool {
+	var rejected *types.ItemsError
+	if !errors.As(err, &rejected) {
+		return false
+	}
+
+	s.log.WithError(err).Errorln("Batch rejected")
+	c.JSON(http.StatusConflict, types.ErrorResponse{
+		Error:   "Conflict",
+		Message: err.Error(),
+		Items:   rejected.Items,
+	})
+	return true
+}
+
+// retryAfter formats the delay for the Retry-After header in whole seconds, rounded up
+func retryAfter(delay time.Duration) string {
+	seconds := int64((delay + time.Second - 1) / time.Second)
+	return strconv.FormatInt(max(seconds, 1), 10)
+}
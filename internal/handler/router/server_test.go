@@ -0,0 +1,296 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"people/internal/types"
+)
+
+// fakeUseCase answers with canned results, the methods the tests do not reach panic
+type fakeUseCase struct {
+	UseCase
+
+	createUser  func(req types.CreateUserRequest) (types.CreateUserResponse, error)
+	createUsers func(names []types.Name) []types.BatchUserResult
+	addEmails   func(emails types.EmailRequest, id uint64) ([]types.ItemResult, error)
+	refresh     func(filter types.RefreshFilter) (int64, error)
+	patchUser   func(patch types.Patch) (types.UserInfo, error)
+	byLastName  func(name string) ([]types.UserInfo, error)
+	listUsers   func(req types.ListUsersRequest) (types.UsersPage, error)
+	audit       types.AuditInfo
+}
+
+func (f *fakeUseCase) CreateUser(ctx context.Context, req types.CreateUserRequest) (types.CreateUserResponse, error) {
+	f.audit = types.AuditInfoFrom(ctx)
+	return f.createUser(req)
+}
+
+func (f *fakeUseCase) CreateUsers(_ context.Context, names []types.Name) []types.BatchUserResult {
+	return f.createUsers(names)
+}
+
+func (f *fakeUseCase) AddUserEmails(_ context.Context, emails types.EmailRequest, id uint64) ([]types.ItemResult, error) {
+	return f.addEmails(emails, id)
+}
+
+func (f *fakeUseCase) RefreshEnrichment(_ context.Context, filter types.RefreshFilter) (int64, error) {
+	return f.refresh(filter)
+}
+
+func (f *fakeUseCase) PatchUser(_ context.Context, _ uint64, patch types.Patch, _ types.IfMatch) (types.UserInfo, error) {
+	return f.patchUser(patch)
+}
+
+func (f *fakeUseCase) GetUserInfoBySecondName(_ context.Context, name string) ([]types.UserInfo, error) {
+	return f.byLastName(name)
+}
+
+func (f *fakeUseCase) GetAllUsersInfo(_ context.Context, req types.ListUsersRequest) (types.UsersPage, error) {
+	return f.listUsers(req)
+}
+
+func serve(t *testing.T, useCase UseCase, method, target, body string, header http.Header) *httptest.ResponseRecorder {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	recorder := httptest.NewRecorder()
+	Router(New(useCase, logger)).ServeHTTP(recorder, req)
+	return recorder
+}
+
+func rateLimited(index int) types.BatchUserResult {
+	err := &types.RateLimitError{Provider: types.AttributeAge, RetryAfter: 30 * time.Second}
+	return types.BatchUserResult{Index: index, Error: err.Error(), Err: err}
+}
+
+func TestCreateUsersStatus(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		results    []types.BatchUserResult
+		status     int
+		retryAfter string
+	}{
+		{
+			name:    "every row stored",
+			results: []types.BatchUserResult{{Index: 0, UserID: 1}, {Index: 1, UserID: 2}},
+			status:  http.StatusOK,
+		},
+		{
+			name:    "a row failed",
+			results: []types.BatchUserResult{{Index: 0, UserID: 1}, {Index: 1, Error: "Not found"}},
+			status:  http.StatusMultiStatus,
+		},
+		{
+			name:       "a row rate limited",
+			results:    []types.BatchUserResult{{Index: 0, UserID: 1}, rateLimited(1)},
+			status:     http.StatusMultiStatus,
+			retryAfter: "30",
+		},
+		{
+			name:       "every row rate limited",
+			results:    []types.BatchUserResult{rateLimited(0), rateLimited(1)},
+			status:     http.StatusServiceUnavailable,
+			retryAfter: "30",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			useCase := &fakeUseCase{createUsers: func([]types.Name) []types.BatchUserResult { return test.results }}
+
+			response := serve(t, useCase, http.MethodPost, "/api/v1/users/batch",
+				`{"users":[{"first_name":"Ann"},{"first_name":"Bob"}]}`, nil)
+
+			if response.Code != test.status {
+				t.Fatalf("status = %d, want %d: %s", response.Code, test.status, response.Body)
+			}
+			if got := response.Header().Get("Retry-After"); got != test.retryAfter {
+				t.Fatalf("Retry-After = %q, want %q", got, test.retryAfter)
+			}
+
+			var body types.BatchCreateUsersResponse
+			if err := json.Unmarshal(response.Body.Bytes(), &body); err != nil || len(body.Results) != len(test.results) {
+				t.Fatalf("body = %s, want every row reported", response.Body)
+			}
+		})
+	}
+}
+
+func TestCreateUsersRejectsInvalidBody(t *testing.T) {
+	response := serve(t, &fakeUseCase{}, http.MethodPost, "/api/v1/users/batch", `{"users":[]}`, nil)
+	if response.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", response.Code)
+	}
+}
+
+func TestCreateUserRateLimited(t *testing.T) {
+	useCase := &fakeUseCase{createUser: func(types.CreateUserRequest) (types.CreateUserResponse, error) {
+		return types.CreateUserResponse{}, &types.CircuitOpenError{Provider: types.AttributeGender, RetryAfter: 1500 * time.Millisecond}
+	}}
+
+	response := serve(t, useCase, http.MethodPost, "/api/v1/users", `{"first_name":"Ann"}`,
+		http.Header{"X-Actor": {"alice"}, "X-Request-Id": {"req-1"}})
+
+	if response.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503: %s", response.Code, response.Body)
+	}
+	if got := response.Header().Get("Retry-After"); got != "2" {
+		t.Fatalf("Retry-After = %q, want the delay rounded up to 2", got)
+	}
+	if useCase.audit.Actor != "alice" || useCase.audit.ActorSource != types.ActorSourceHeader || useCase.audit.RequestID != "req-1" {
+		t.Fatalf("audit info = %+v, want alice from the header and req-1", useCase.audit)
+	}
+}
+
+func TestAuditHeadersAreCutToValidUTF8(t *testing.T) {
+	useCase := &fakeUseCase{createUser: func(types.CreateUserRequest) (types.CreateUserResponse, error) {
+		return types.CreateUserResponse{UserID: 1}, nil
+	}}
+
+	response := serve(t, useCase, http.MethodPost, "/api/v1/users", `{"first_name":"Ann"}`,
+		http.Header{"X-Actor": {"a" + strings.Repeat("ж", maxAuditHeader)}, "X-Request-Id": {"req-\xff-1"}})
+
+	if response.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", response.Code, response.Body)
+	}
+	if actor := useCase.audit.Actor; actor != "a"+strings.Repeat("ж", maxAuditHeader/2-1) {
+		t.Fatalf("actor = %q, want the whole characters that fit in %d bytes", actor, maxAuditHeader)
+	}
+	if requestID := useCase.audit.RequestID; requestID != "req-\uFFFD-1" {
+		t.Fatalf("request id = %q, want the invalid byte replaced", requestID)
+	}
+}
+
+func TestRefreshEnrichmentWithoutWorkers(t *testing.T) {
+	useCase := &fakeUseCase{refresh: func(types.RefreshFilter) (int64, error) { return 0, types.ErrNoWorkers }}
+
+	response := serve(t, useCase, http.MethodPost, "/api/v1/enrichment/refresh", `{"missing_only":true}`, nil)
+	if response.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503: %s", response.Code, response.Body)
+	}
+}
+
+func TestAddUserEmailsRejectsTheWholeBatch(t *testing.T) {
+	useCase := &fakeUseCase{addEmails: func(emails types.EmailRequest, _ uint64) ([]types.ItemResult, error) {
+		return nil, types.RejectItems([]types.ItemResult{
+			{Index: 0, Item: emails.Emails[0], Status: types.ItemCreated, ID: 7},
+			{Index: 1, Item: emails.Emails[1], Status: types.ItemConflict, Message: "owned by another user"},
+		})
+	}}
+
+	response := serve(t, useCase, http.MethodPost, "/api/v1/users/1/emails",
+		`{"emails":["ann@example.com","bob@example.com"]}`, nil)
+	if response.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want 409: %s", response.Code, response.Body)
+	}
+
+	var body types.ErrorResponse
+	if err := json.Unmarshal(response.Body.Bytes(), &body); err != nil {
+		t.Fatalf("body = %s: %v", response.Body, err)
+	}
+	if len(body.Items) != 2 || body.Items[0].Status != types.ItemRolledBack || body.Items[0].ID != 0 ||
+		body.Items[1].Status != types.ItemConflict {
+		t.Fatalf("items = %+v, want the stored email rolled back and the conflict", body.Items)
+	}
+}
+
+func TestPatchUserStatus(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"patched", nil, http.StatusOK},
+		{"unsupported media type", types.ErrUnsupportedPatch, http.StatusUnsupportedMediaType},
+		{"invalid patch", fmt.Errorf("operation 0 (add /age): %w", types.ErrInvalidPatch), http.StatusBadRequest},
+		{"failed test", fmt.Errorf("operation 0 (test /age): %w", types.ErrPatchConflict), http.StatusConflict},
+		{"invalid user", types.ErrInvalidUser, http.StatusUnprocessableEntity},
+		{"stale If-Match", types.ErrPreconditionFailed, http.StatusPreconditionFailed},
+		{"missing user", types.ErrNotFound, http.StatusNotFound},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var mediaType string
+			useCase := &fakeUseCase{patchUser: func(patch types.Patch) (types.UserInfo, error) {
+				mediaType = patch.MediaType
+				return types.UserInfo{ID: 1, Version: 2}, test.err
+			}}
+
+			response := serve(t, useCase, http.MethodPatch, "/api/v1/users/1", `{"age":31}`, nil)
+			if response.Code != test.status {
+				t.Fatalf("status = %d, want %d: %s", response.Code, test.status, response.Body)
+			}
+			if mediaType != types.MergePatchMediaType {
+				t.Fatalf("media type = %q, want application/json taken for a merge patch", mediaType)
+			}
+		})
+	}
+}
+
+func TestCreateUserRejectsContactsWith409(t *testing.T) {
+	useCase := &fakeUseCase{createUser: func(req types.CreateUserRequest) (types.CreateUserResponse, error) {
+		return types.CreateUserResponse{}, types.RejectItems([]types.ItemResult{
+			{Index: 0, Item: req.Emails[0], Status: types.ItemConflict, Message: "owned by another user"},
+		})
+	}}
+
+	response := serve(t, useCase, http.MethodPost, "/api/v1/users", `{"first_name":"Ann","emails":["ann@example.com"]}`, nil)
+	if response.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want 409 like the other item batches: %s", response.Code, response.Body)
+	}
+
+	var body types.ErrorResponse
+	if err := json.Unmarshal(response.Body.Bytes(), &body); err != nil || len(body.Items) != 1 {
+		t.Fatalf("body = %s, want the rejected email reported", response.Body)
+	}
+}
+
+func TestLastNameLookupLinksAnExactSuccessor(t *testing.T) {
+	var list types.ListUsersRequest
+	useCase := &fakeUseCase{
+		byLastName: func(string) ([]types.UserInfo, error) { return []types.UserInfo{}, nil },
+		listUsers: func(req types.ListUsersRequest) (types.UsersPage, error) {
+			list = req
+			return types.UsersPage{Users: []types.UserInfo{}}, nil
+		},
+	}
+
+	response := serve(t, useCase, http.MethodGet, "/api/v1/users/M%C3%BCller", "", nil)
+	if response.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", response.Code, response.Body)
+	}
+	if got := response.Header().Get("Deprecation"); got != "true" {
+		t.Errorf("Deprecation = %q, want true", got)
+	}
+
+	link := response.Header().Get("Link")
+	target, ok := strings.CutSuffix(strings.TrimPrefix(link, "<"), `>; rel="successor-version"`)
+	if !ok {
+		t.Fatalf("Link = %q, want a successor-version", link)
+	}
+
+	// the prefix filter would also list Müller-Lüdenscheidt and müller
+	response = serve(t, useCase, http.MethodGet, target, "", nil)
+	if response.Code != http.StatusOK {
+		t.Fatalf("successor status = %d, want 200: %s", response.Code, response.Body)
+	}
+	if list.LastNameExact != "Müller" || list.LastName != "" {
+		t.Errorf("successor query = %+v, want the exact last name Müller", list)
+	}
+}
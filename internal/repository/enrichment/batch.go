@@ -0,0 +1,75 @@
+package enrichment
+
+import (
+	"context"
+	"errors"
+
+	"people/internal/types"
+)
+
+// MaxBatchSize is the number of names agify, genderize and nationalize accept in one request
+const MaxBatchSize = 10
+
+// BatchProvider resolves many names at once. Results are keyed by the name as it was passed,
+// names that could not be resolved are absent from the result
+type BatchProvider interface {
+	AgeBatch(ctx context.Context, names []string, country string) (map[string]types.AgeData, error)
+	GenderBatch(ctx context.Context, names []string, country string) (map[string]types.GenderData, error)
+	NationalityBatch(ctx context.Context, names []string) (map[string]types.NationalityData, error)
+}
+
+// AgeBatch uses the batch API of the provider when it has one and single lookups otherwise
+func AgeBatch(ctx context.Context, provider EnrichmentProvider, names []string, country string) (map[string]types.AgeData, error) {
+	if batch, ok := provider.(BatchProvider); ok {
+		return batch.AgeBatch(ctx, names, country)
+	}
+	return lookupEach(ctx, names, func(ctx context.Context, name string) (types.AgeData, error) {
+		return provider.Age(ctx, name, country)
+	})
+}
+
+// GenderBatch uses the batch API of the provider when it has one and single lookups otherwise
+func GenderBatch(ctx context.Context, provider EnrichmentProvider, names []string, country string) (map[string]types.GenderData, error) {
+	if batch, ok := provider.(BatchProvider); ok {
+		return batch.GenderBatch(ctx, names, country)
+	}
+	return lookupEach(ctx, names, func(ctx context.Context, name string) (types.GenderData, error) {
+		return provider.Gender(ctx, name, country)
+	})
+}
+
+// NationalityBatch uses the batch API of the provider when it has one and single lookups otherwise
+func NationalityBatch(ctx context.Context, provider EnrichmentProvider, names []string) (map[string]types.NationalityData, error) {
+	if batch, ok := provider.(BatchProvider); ok {
+		return batch.NationalityBatch(ctx, names)
+	}
+	return lookupEach(ctx, names, provider.Nationality)
+}
+
+func lookupEach[V any](ctx context.Context, names []string, lookup func(ctx context.Context, name string) (V, error)) (map[string]V, error) {
+	result := make(map[string]V, len(names))
+	for _, name := range names {
+		value, err := lookup(ctx, name)
+		if err != nil {
+			if errors.Is(err, types.ErrNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		result[name] = value
+	}
+	return result, nil
+}
+
+// chunks splits names into upstream sized batches
+func chunks(names []string) [][]string {
+	var batches [][]string
+	for len(names) > MaxBatchSize {
+		batches = append(batches, names[:MaxBatchSize])
+		names = names[MaxBatchSize:]
+	}
+	if len(names) > 0 {
+		batches = append(batches, names)
+	}
+	return batches
+}
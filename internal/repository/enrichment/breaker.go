@@ -0,0 +1,246 @@
+package enrichment
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"people/internal/types"
+)
+
+const (
+	DefaultFailureThreshold = 5
+	DefaultOpenTimeout      = 30 * time.Second
+	DefaultHalfOpenRequests = 1
+)
+
+// BreakerReporter is implemented by providers that short-circuit failing upstreams
+type BreakerReporter interface {
+	Breakers() []types.BreakerState
+}
+
+// Breakers returns the circuit breaker state of the provider, looking through the wrapping providers
+func Breakers(provider EnrichmentProvider) []types.BreakerState {
+	for provider != nil {
+		if reporter, ok := provider.(BreakerReporter); ok {
+			return reporter.Breakers()
+		}
+		wrapper, ok := provider.(interface{ Unwrap() EnrichmentProvider })
+		if !ok {
+			break
+		}
+		provider = wrapper.Unwrap()
+	}
+	return nil
+}
+
+// breakerProvider short-circuits the lookups of an attribute while its upstream keeps failing
+type breakerProvider struct {
+	next        EnrichmentProvider
+	age         *breaker
+	gender      *breaker
+	nationality *breaker
+}
+
+// WithCircuitBreakers wraps every attribute of the provider with its own circuit breaker
+func WithCircuitBreakers(next EnrichmentProvider, cfg types.BreakerConfig) EnrichmentProvider {
+	return &breakerProvider{
+		next:        next,
+		age:         newBreaker(types.AttributeAge, cfg),
+		gender:      newBreaker(types.AttributeGender, cfg),
+		nationality: newBreaker(types.AttributeNationality, cfg),
+	}
+}
+
+func (b *breakerProvider) Age(ctx context.Context, name, country string) (types.AgeData, error) {
+	return guard(b.age, func() (types.AgeData, error) {
+		return b.next.Age(ctx, name, country)
+	})
+}
+
+func (b *breakerProvider) Gender(ctx context.Context, name, country string) (types.GenderData, error) {
+	return guard(b.gender, func() (types.GenderData, error) {
+		return b.next.Gender(ctx, name, country)
+	})
+}
+
+func (b *breakerProvider) Nationality(ctx context.Context, name string) (types.NationalityData, error) {
+	return guard(b.nationality, func() (types.NationalityData, error) {
+		return b.next.Nationality(ctx, name)
+	})
+}
+
+func (b *breakerProvider) AgeBatch(ctx context.Context, names []string, country string) (map[string]types.AgeData, error) {
+	return guard(b.age, func() (map[string]types.AgeData, error) {
+		return AgeBatch(ctx, b.next, names, country)
+	})
+}
+
+func (b *breakerProvider) GenderBatch(ctx context.Context, names []string, country string) (map[string]types.GenderData, error) {
+	return guard(b.gender, func() (map[string]types.GenderData, error) {
+		return GenderBatch(ctx, b.next, names, country)
+	})
+}
+
+func (b *breakerProvider) NationalityBatch(ctx context.Context, names []string) (map[string]types.NationalityData, error) {
+	return guard(b.nationality, func() (map[string]types.NationalityData, error) {
+		return NationalityBatch(ctx, b.next, names)
+	})
+}
+
+func (b *breakerProvider) Breakers() []types.BreakerState {
+	return []types.BreakerState{
+		b.age.state(),
+		b.gender.state(),
+		b.nationality.state(),
+	}
+}
+
+// Unwrap returns the wrapped provider
+func (b *breakerProvider) Unwrap() EnrichmentProvider {
+	return b.next
+}
+
+func guard[T any](b *breaker, call func() (T, error)) (T, error) {
+	var zero T
+
+	err := b.allow(time.Now())
+	if err != nil {
+		return zero, err
+	}
+
+	data, err := call()
+	b.done(err, time.Now())
+	return data, err
+}
+
+// breaker is closed while the upstream answers, opens after FailureThreshold consecutive failures
+// and lets HalfOpenRequests probes through once OpenTimeout has passed
+type breaker struct {
+	mu               sync.Mutex
+	provider         string
+	failureThreshold int
+	openTimeout      time.Duration
+	halfOpenRequests int
+
+	status    string
+	failures  int
+	openedAt  time.Time
+	probes    int
+	successes int
+
+	requestsTotal uint64
+	failuresTotal uint64
+	rejectedTotal uint64
+}
+
+func newBreaker(provider string, cfg types.BreakerConfig) *breaker {
+	b := &breaker{
+		provider:         provider,
+		failureThreshold: cfg.FailureThreshold,
+		openTimeout:      cfg.OpenTimeout,
+		halfOpenRequests: cfg.HalfOpenRequests,
+		status:           types.BreakerClosed,
+	}
+	if b.failureThreshold <= 0 {
+		b.failureThreshold = DefaultFailureThreshold
+	}
+	if b.openTimeout <= 0 {
+		b.openTimeout = DefaultOpenTimeout
+	}
+	if b.halfOpenRequests <= 0 {
+		b.halfOpenRequests = DefaultHalfOpenRequests
+	}
+	return b
+}
+
+func (b *breaker) allow(now time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.status == types.BreakerOpen && now.Sub(b.openedAt) >= b.openTimeout {
+		b.status = types.BreakerHalfOpen
+		b.probes = 0
+		b.successes = 0
+	}
+
+	switch b.status {
+	case types.BreakerOpen:
+		b.rejectedTotal++
+		return &types.CircuitOpenError{
+			Provider:   b.provider,
+			RetryAfter: b.openedAt.Add(b.openTimeout).Sub(now),
+		}
+	case types.BreakerHalfOpen:
+		if b.probes >= b.halfOpenRequests {
+			b.rejectedTotal++
+			return &types.CircuitOpenError{
+				Provider:   b.provider,
+				RetryAfter: time.Second,
+			}
+		}
+		b.probes++
+	}
+
+	b.requestsTotal++
+	return nil
+}
+
+func (b *breaker) done(err error, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// a caller that went away says nothing about the upstream, the probe is given back
+	if errors.Is(err, context.Canceled) {
+		if b.status == types.BreakerHalfOpen && b.probes > 0 {
+			b.probes--
+		}
+		return
+	}
+
+	if isUpstreamFailure(err) {
+		b.failuresTotal++
+		b.failures++
+		if b.status == types.BreakerHalfOpen || b.failures >= b.failureThreshold {
+			b.status = types.BreakerOpen
+			b.openedAt = now
+		}
+		return
+	}
+
+	b.failures = 0
+	if b.status == types.BreakerHalfOpen {
+		b.successes++
+		if b.successes >= b.halfOpenRequests {
+			b.status = types.BreakerClosed
+		}
+	}
+}
+
+// isUpstreamFailure tells whether the error means the upstream is unhealthy,
+// unknown names and exhausted quotas are regular answers
+func isUpstreamFailure(err error) bool {
+	return err != nil &&
+		!errors.Is(err, types.ErrNotFound) &&
+		!errors.Is(err, types.ErrRateLimited)
+}
+
+func (b *breaker) state() types.BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state := types.BreakerState{
+		Provider:            b.provider,
+		State:               b.status,
+		ConsecutiveFailures: b.failures,
+		Requests:            b.requestsTotal,
+		Failures:            b.failuresTotal,
+		Rejected:            b.rejectedTotal,
+	}
+	if b.status != types.BreakerClosed {
+		openedAt := b.openedAt
+		state.OpenedAt = &openedAt
+	}
+	return state
+}
@@ -0,0 +1,166 @@
+package enrichment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"people/internal/types"
+)
+
+var errUpstream = errors.New("upstream answered 502")
+
+func newTestBreaker() *breaker {
+	return newBreaker(types.AttributeAge, types.BreakerConfig{
+		FailureThreshold: 3,
+		OpenTimeout:      time.Minute,
+		HalfOpenRequests: 2,
+	})
+}
+
+// fail lets n failed calls through the closed breaker
+func fail(t *testing.T, b *breaker, n int, now time.Time) {
+	t.Helper()
+
+	for i := 0; i < n; i++ {
+		if err := b.allow(now); err != nil {
+			t.Fatalf("allow() of failure %d error = %v, want the breaker closed", i+1, err)
+		}
+		b.done(errUpstream, now)
+	}
+}
+
+func TestBreakerOpensAtTheThreshold(t *testing.T) {
+	b := newTestBreaker()
+	now := time.Now()
+
+	fail(t, b, 2, now)
+	if b.state().State != types.BreakerClosed {
+		t.Fatalf("state after 2 failures = %s, want closed", b.state().State)
+	}
+
+	fail(t, b, 1, now)
+	if b.state().State != types.BreakerOpen {
+		t.Fatalf("state after 3 failures = %s, want open", b.state().State)
+	}
+
+	err := b.allow(now.Add(10 * time.Second))
+	var circuitErr *types.CircuitOpenError
+	if !errors.As(err, &circuitErr) || circuitErr.RetryAfter != 50*time.Second {
+		t.Fatalf("allow() of the open breaker error = %v, want a CircuitOpenError of 50s", err)
+	}
+	if state := b.state(); state.Rejected != 1 || state.Failures != 3 || state.Requests != 3 {
+		t.Fatalf("state = %+v, want 3 requests, 3 failures and 1 rejected", state)
+	}
+}
+
+func TestBreakerSuccessResetsTheFailures(t *testing.T) {
+	b := newTestBreaker()
+	now := time.Now()
+
+	fail(t, b, 2, now)
+	_ = b.allow(now)
+	b.done(nil, now)
+	fail(t, b, 2, now)
+
+	if b.state().State != types.BreakerClosed {
+		t.Fatalf("state = %s, want closed, the failures were not consecutive", b.state().State)
+	}
+}
+
+func TestBreakerIgnoresRegularAnswers(t *testing.T) {
+	b := newTestBreaker()
+	now := time.Now()
+
+	for _, err := range []error{
+		types.ErrNotFound,
+		&types.RateLimitError{Provider: types.AttributeAge, RetryAfter: time.Second},
+		fmt.Errorf("lookup: %w", types.ErrNotFound),
+	} {
+		for range 3 {
+			_ = b.allow(now)
+			b.done(err, now)
+		}
+	}
+
+	if state := b.state(); state.State != types.BreakerClosed || state.Failures != 0 {
+		t.Fatalf("state = %+v, want closed without failures", state)
+	}
+}
+
+func TestBreakerHalfOpensAfterTheTimeout(t *testing.T) {
+	b := newTestBreaker()
+	opened := time.Now()
+	fail(t, b, 3, opened)
+
+	if err := b.allow(opened.Add(time.Minute - time.Millisecond)); err == nil {
+		t.Fatal("allow() before the open timeout error = nil, want the breaker open")
+	}
+
+	halfOpen := opened.Add(time.Minute)
+	for i := range 2 {
+		if err := b.allow(halfOpen); err != nil {
+			t.Fatalf("allow() of probe %d error = %v, want it let through", i+1, err)
+		}
+	}
+	if b.state().State != types.BreakerHalfOpen {
+		t.Fatalf("state = %s, want half-open", b.state().State)
+	}
+
+	err := b.allow(halfOpen)
+	var circuitErr *types.CircuitOpenError
+	if !errors.As(err, &circuitErr) {
+		t.Fatalf("allow() beyond the probe limit error = %v, want a CircuitOpenError", err)
+	}
+
+	b.done(nil, halfOpen)
+	if b.state().State != types.BreakerHalfOpen {
+		t.Fatalf("state after 1 of 2 probes succeeded = %s, want half-open", b.state().State)
+	}
+	b.done(nil, halfOpen)
+	if b.state().State != types.BreakerClosed {
+		t.Fatalf("state after every probe succeeded = %s, want closed", b.state().State)
+	}
+}
+
+func TestBreakerReopensWhenAProbeFails(t *testing.T) {
+	b := newTestBreaker()
+	opened := time.Now()
+	fail(t, b, 3, opened)
+
+	probed := opened.Add(time.Minute)
+	if err := b.allow(probed); err != nil {
+		t.Fatalf("allow() of the probe error = %v", err)
+	}
+	b.done(errUpstream, probed)
+
+	if state := b.state(); state.State != types.BreakerOpen || !state.OpenedAt.Equal(probed) {
+		t.Fatalf("state = %+v, want open again since the probe", state)
+	}
+	if err := b.allow(probed.Add(time.Second)); err == nil {
+		t.Fatal("allow() after the failed probe error = nil, want the breaker open for another timeout")
+	}
+}
+
+func TestBreakerGivesBackTheProbeOfACanceledCall(t *testing.T) {
+	b := newTestBreaker()
+	opened := time.Now()
+	fail(t, b, 3, opened)
+
+	halfOpen := opened.Add(time.Minute)
+	for range 2 {
+		if err := b.allow(halfOpen); err != nil {
+			t.Fatalf("allow() of a probe error = %v", err)
+		}
+	}
+	b.done(fmt.Errorf("age lookup: %w", context.Canceled), halfOpen)
+
+	if b.state().State != types.BreakerHalfOpen {
+		t.Fatalf("state = %s, want half-open, a canceled call says nothing about the upstream", b.state().State)
+	}
+	if err := b.allow(halfOpen); err != nil {
+		t.Fatalf("allow() after the canceled probe error = %v, want its probe given back", err)
+	}
+}
@@ -0,0 +1,257 @@
+package enrichment
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"people/internal/types"
+)
+
+// DefaultCacheSize is the number of entries kept in memory when config.yml does not set one
+const DefaultCacheSize = 1024
+
+// CacheStore persists enrichment results, it is implemented by storage.Storage
+type CacheStore interface {
+	GetEnrichmentCache(ctx context.Context, name, attribute, country string) (types.EnrichmentCacheEntry, error)
+	SaveEnrichmentCache(ctx context.Context, entry types.EnrichmentCacheEntry) error
+}
+
+// Cached answers lookups from an in-process LRU and the enrichment_cache table
+// before falling back to the wrapped provider
+type Cached struct {
+	next   EnrichmentProvider
+	store  CacheStore
+	ttl    time.Duration
+	local  *lru[types.EnrichmentCacheEntry]
+	Logger *logrus.Logger
+}
+
+func NewCached(next EnrichmentProvider, store CacheStore, cfg types.EnrichmentUrlsConfig, logger *logrus.Logger) *Cached {
+	size := cfg.CacheSize
+	if size == 0 {
+		size = DefaultCacheSize
+	}
+
+	return &Cached{
+		next:   next,
+		store:  store,
+		ttl:    cfg.CacheTTL,
+		local:  newLRU[types.EnrichmentCacheEntry](size),
+		Logger: logger,
+	}
+}
+
+// results are cached per country hint, a localized answer differs from the global one
+func (c *Cached) Age(ctx context.Context, name, country string) (types.AgeData, error) {
+	return cachedLookup(ctx, c, name, country, types.AttributeAge, func(ctx context.Context, name string) (types.AgeData, error) {
+		return c.next.Age(ctx, name, country)
+	}, ageEntry)
+}
+
+func (c *Cached) Gender(ctx context.Context, name, country string) (types.GenderData, error) {
+	return cachedLookup(ctx, c, name, country, types.AttributeGender, func(ctx context.Context, name string) (types.GenderData, error) {
+		return c.next.Gender(ctx, name, country)
+	}, genderEntry)
+}
+
+func (c *Cached) Nationality(ctx context.Context, name string) (types.NationalityData, error) {
+	return cachedLookup(ctx, c, name, "", types.AttributeNationality, c.next.Nationality, nationalityEntry)
+}
+
+func (c *Cached) AgeBatch(ctx context.Context, names []string, country string) (map[string]types.AgeData, error) {
+	return cachedLookupBatch(ctx, c, names, country, types.AttributeAge, func(ctx context.Context, names []string) (map[string]types.AgeData, error) {
+		return AgeBatch(ctx, c.next, names, country)
+	}, ageEntry)
+}
+
+func (c *Cached) GenderBatch(ctx context.Context, names []string, country string) (map[string]types.GenderData, error) {
+	return cachedLookupBatch(ctx, c, names, country, types.AttributeGender, func(ctx context.Context, names []string) (map[string]types.GenderData, error) {
+		return GenderBatch(ctx, c.next, names, country)
+	}, genderEntry)
+}
+
+func (c *Cached) NationalityBatch(ctx context.Context, names []string) (map[string]types.NationalityData, error) {
+	return cachedLookupBatch(ctx, c, names, "", types.AttributeNationality, func(ctx context.Context, names []string) (map[string]types.NationalityData, error) {
+		return NationalityBatch(ctx, c.next, names)
+	}, nationalityEntry)
+}
+
+// ageEntry, genderEntry and nationalityEntry fill the queryable columns of a cache entry,
+// the whole upstream answer is kept in the payload
+func ageEntry(data types.AgeData) types.EnrichmentCacheEntry {
+	return types.EnrichmentCacheEntry{
+		Value: strconv.FormatUint(uint64(data.Age), 10),
+		Count: &data.Count,
+	}
+}
+
+func genderEntry(data types.GenderData) types.EnrichmentCacheEntry {
+	return types.EnrichmentCacheEntry{
+		Value:       data.Gender,
+		Probability: &data.Probability,
+		Count:       &data.Count,
+	}
+}
+
+func nationalityEntry(data types.NationalityData) types.EnrichmentCacheEntry {
+	entry := types.EnrichmentCacheEntry{
+		Count: &data.Count,
+	}
+	if len(data.Country) > 0 {
+		entry.Value = data.Country[0].ID
+		entry.Probability = &data.Country[0].Probability
+	}
+	return entry
+}
+
+func cachedLookup[T any](ctx context.Context, c *Cached, name, country, attribute string, fetch func(ctx context.Context, name string) (T, error), summary func(T) types.EnrichmentCacheEntry) (T, error) {
+	if data, ok, err := cached[T](ctx, c, name, country, attribute); ok {
+		return data, err
+	}
+
+	data, err := fetch(ctx, name)
+	if errors.Is(err, types.ErrNotFound) {
+		saveNotFound(ctx, c, name, country, attribute)
+		return data, err
+	}
+	if err != nil {
+		return data, err
+	}
+
+	save(ctx, c, name, country, attribute, data, summary)
+	return data, nil
+}
+
+// cachedLookupBatch answers what it can from the cache and fetches only the missing names in one batch
+func cachedLookupBatch[T any](ctx context.Context, c *Cached, names []string, country, attribute string, fetch func(ctx context.Context, names []string) (map[string]T, error), summary func(T) types.EnrichmentCacheEntry) (map[string]T, error) {
+	result := make(map[string]T, len(names))
+	var missing []string
+
+	for _, name := range names {
+		data, ok, err := cached[T](ctx, c, name, country, attribute)
+		if !ok {
+			missing = append(missing, name)
+			continue
+		}
+		// names without data stay absent from the result
+		if err == nil {
+			result[name] = data
+		}
+	}
+
+	if len(missing) == 0 {
+		return result, nil
+	}
+
+	fetched, err := fetch(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range missing {
+		data, ok := fetched[name]
+		if !ok {
+			saveNotFound(ctx, c, name, country, attribute)
+			continue
+		}
+		result[name] = data
+		save(ctx, c, name, country, attribute, data, summary)
+	}
+
+	return result, nil
+}
+
+// cached returns a fresh result from the LRU or the cache table, ok reports whether the cache had one.
+// A name the upstream has no data for is answered with types.ErrNotFound
+func cached[T any](ctx context.Context, c *Cached, name, country, attribute string) (T, bool, error) {
+	var data T
+
+	name = normalizeName(name)
+	key := cacheKey(name, country, attribute)
+
+	entry, ok := c.local.Get(key)
+	if ok && !c.fresh(entry) {
+		c.local.Remove(key)
+		ok = false
+	}
+
+	if !ok {
+		var err error
+		entry, err = c.store.GetEnrichmentCache(ctx, name, attribute, country)
+		if err != nil {
+			if !errors.Is(err, types.ErrNotFound) {
+				// a broken cache must not block the enrichment itself
+				c.Logger.WithError(err).Warnln("Error reading enrichment cache")
+			}
+			return data, false, nil
+		}
+
+		// entries written before the payload was stored are refreshed
+		if !c.fresh(entry) || (len(entry.Payload) == 0 && !entry.NotFound) {
+			return data, false, nil
+		}
+		c.local.Add(key, entry)
+	}
+
+	if entry.NotFound {
+		return data, true, types.ErrNotFound
+	}
+
+	err := json.Unmarshal(entry.Payload, &data)
+	if err != nil {
+		c.Logger.WithError(err).Warnln("Error unmarshal enrichment cache payload")
+		return data, false, nil
+	}
+
+	return data, true, nil
+}
+
+func save[T any](ctx context.Context, c *Cached, name, country, attribute string, data T, summary func(T) types.EnrichmentCacheEntry) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		c.Logger.WithError(err).Warnln("Error marshal enrichment cache payload")
+		return
+	}
+
+	entry := summary(data)
+	entry.Payload = payload
+	saveEntry(ctx, c, name, country, attribute, entry)
+}
+
+// saveNotFound keeps a name without data for the same TTL, so it does not cost an upstream request on every lookup
+func saveNotFound(ctx context.Context, c *Cached, name, country, attribute string) {
+	saveEntry(ctx, c, name, country, attribute, types.EnrichmentCacheEntry{NotFound: true})
+}
+
+func saveEntry(ctx context.Context, c *Cached, name, country, attribute string, entry types.EnrichmentCacheEntry) {
+	name = normalizeName(name)
+
+	entry.Name = name
+	entry.Attribute = attribute
+	entry.Country = country
+	entry.FetchedAt = time.Now()
+
+	c.local.Add(cacheKey(name, country, attribute), entry)
+
+	err := c.store.SaveEnrichmentCache(ctx, entry)
+	if err != nil {
+		c.Logger.WithError(err).Warnln("Error saving enrichment cache")
+	}
+}
+
+func cacheKey(name, country, attribute string) string {
+	return attribute + ":" + country + ":" + name
+}
+
+func (c *Cached) fresh(entry types.EnrichmentCacheEntry) bool {
+	return time.Since(entry.FetchedAt) < c.ttl
+}
+
+// Unwrap returns the wrapped provider
+func (c *Cached) Unwrap() EnrichmentProvider {
+	return c.next
+}
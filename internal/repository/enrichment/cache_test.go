@@ -0,0 +1,168 @@
+package enrichment
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"people/internal/types"
+)
+
+// memoryCache is a CacheStore kept in a map
+type memoryCache struct {
+	mu      sync.Mutex
+	entries map[string]types.EnrichmentCacheEntry
+}
+
+func (m *memoryCache) GetEnrichmentCache(_ context.Context, name, attribute, country string) (types.EnrichmentCacheEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[cacheKey(name, country, attribute)]
+	if !ok {
+		return types.EnrichmentCacheEntry{}, types.ErrNotFound
+	}
+	return entry, nil
+}
+
+func (m *memoryCache) SaveEnrichmentCache(_ context.Context, entry types.EnrichmentCacheEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.entries[cacheKey(entry.Name, entry.Country, entry.Attribute)] = entry
+	return nil
+}
+
+// countingProvider knows the nationality of the names in countries and counts the names it was asked for
+type countingProvider struct {
+	countries map[string]string
+	asked     []string
+}
+
+func (p *countingProvider) Age(context.Context, string, string) (types.AgeData, error) {
+	return types.AgeData{}, types.ErrNotFound
+}
+
+func (p *countingProvider) Gender(context.Context, string, string) (types.GenderData, error) {
+	return types.GenderData{}, types.ErrNotFound
+}
+
+func (p *countingProvider) Nationality(_ context.Context, name string) (types.NationalityData, error) {
+	p.asked = append(p.asked, name)
+
+	country, ok := p.countries[name]
+	if !ok {
+		return types.NationalityData{}, types.ErrNotFound
+	}
+	return types.NationalityData{Name: name, Count: 1, Country: []types.CountryData{{ID: country, Probability: 1}}}, nil
+}
+
+func newTestCached(provider EnrichmentProvider, ttl time.Duration) (*Cached, *memoryCache) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	store := &memoryCache{entries: make(map[string]types.EnrichmentCacheEntry)}
+	return NewCached(provider, store, types.EnrichmentUrlsConfig{CacheTTL: ttl}, logger), store
+}
+
+func TestCachedKeepsNotFound(t *testing.T) {
+	provider := &countingProvider{}
+	cache, store := newTestCached(provider, time.Hour)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		_, err := cache.Nationality(ctx, "Zyx")
+		if !errors.Is(err, types.ErrNotFound) {
+			t.Fatalf("Nationality() lookup %d error = %v, want %v", i+1, err, types.ErrNotFound)
+		}
+	}
+	if len(provider.asked) != 1 {
+		t.Errorf("provider was asked %d times, want once", len(provider.asked))
+	}
+
+	entry, err := store.GetEnrichmentCache(ctx, normalizeName("Zyx"), types.AttributeNationality, "")
+	if err != nil {
+		t.Fatalf("GetEnrichmentCache() error = %v", err)
+	}
+	if !entry.NotFound || len(entry.Payload) != 0 {
+		t.Errorf("stored entry = %+v, want a not found marker without payload", entry)
+	}
+
+	// another process answers from the table alone
+	other, _ := newTestCached(provider, time.Hour)
+	other.store = store
+	_, err = other.Nationality(ctx, "Zyx")
+	if !errors.Is(err, types.ErrNotFound) {
+		t.Errorf("Nationality() from the table error = %v, want %v", err, types.ErrNotFound)
+	}
+	if len(provider.asked) != 1 {
+		t.Errorf("provider was asked %d times, want the table to answer", len(provider.asked))
+	}
+}
+
+func TestCachedNotFoundExpires(t *testing.T) {
+	provider := &countingProvider{}
+	cache, store := newTestCached(provider, time.Hour)
+	ctx := context.Background()
+
+	_, err := cache.Nationality(ctx, "Zyx")
+	if !errors.Is(err, types.ErrNotFound) {
+		t.Fatalf("Nationality() error = %v, want %v", err, types.ErrNotFound)
+	}
+
+	// the upstream learned the name after the TTL
+	key := cacheKey(normalizeName("Zyx"), "", types.AttributeNationality)
+	entry := store.entries[key]
+	entry.FetchedAt = entry.FetchedAt.Add(-2 * time.Hour)
+	store.entries[key] = entry
+	cache.local.Add(key, entry)
+	provider.countries = map[string]string{"Zyx": "UA"}
+
+	data, err := cache.Nationality(ctx, "Zyx")
+	if err != nil {
+		t.Fatalf("Nationality() after the TTL error = %v", err)
+	}
+	if len(data.Country) != 1 || data.Country[0].ID != "UA" {
+		t.Errorf("Nationality() after the TTL = %+v, want UA", data)
+	}
+	if len(provider.asked) != 2 {
+		t.Errorf("provider was asked %d times, want twice", len(provider.asked))
+	}
+}
+
+func TestCachedBatchKeepsNotFound(t *testing.T) {
+	provider := &countingProvider{countries: map[string]string{"Anna": "UA"}}
+	cache, store := newTestCached(provider, time.Hour)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		result, err := cache.NationalityBatch(ctx, []string{"Anna", "Zyx"})
+		if err != nil {
+			t.Fatalf("NationalityBatch() round %d error = %v", i+1, err)
+		}
+		if _, ok := result["Zyx"]; ok || len(result) != 1 {
+			t.Errorf("NationalityBatch() round %d = %v, want only Anna", i+1, result)
+		}
+	}
+	if len(provider.asked) != 2 {
+		t.Errorf("provider was asked for %v, want each name once", provider.asked)
+	}
+
+	entry, err := store.GetEnrichmentCache(ctx, normalizeName("Zyx"), types.AttributeNationality, "")
+	if err != nil || !entry.NotFound {
+		t.Errorf("stored entry = %+v, %v, want a not found marker", entry, err)
+	}
+
+	// a single lookup shares the marker of the batch
+	_, err = cache.Nationality(ctx, "Zyx")
+	if !errors.Is(err, types.ErrNotFound) {
+		t.Errorf("Nationality() error = %v, want %v", err, types.ErrNotFound)
+	}
+	if len(provider.asked) != 2 {
+		t.Errorf("provider was asked for %v, want the cache to answer", provider.asked)
+	}
+}
@@ -0,0 +1,23 @@
+package enrichment
+
+import (
+	"github.com/sirupsen/logrus"
+	"people/internal/types"
+)
+
+// normalizeCountries keeps the candidates with a known ISO 3166-1 code and spells them as alpha-2
+func normalizeCountries(data types.NationalityData, logger *logrus.Logger) types.NationalityData {
+	countries := make([]types.CountryData, 0, len(data.Country))
+	for _, country := range data.Country {
+		code, err := types.NormalizeCountry(country.ID)
+		if err != nil {
+			logger.WithError(err).WithField("name", data.Name).Warnln("Skipping unknown nationality candidate")
+			continue
+		}
+		country.ID = code
+		countries = append(countries, country)
+	}
+
+	data.Country = countries
+	return data
+}
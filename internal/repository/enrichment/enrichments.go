@@ -1,110 +1,303 @@
 package enrichment
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"people/internal/types"
 )
 
+func init() {
+	Register(DefaultProvider, func(cfg types.EnrichmentUrlsConfig, logger *logrus.Logger) (EnrichmentProvider, error) {
+		return New(cfg, logger)
+	})
+}
+
+// Enrichment queries agify, genderize and nationalize public APIs
 type Enrichment struct {
 	AgeUrl         string
 	GenderUrl      string
 	NationalityUrl string
 	Logger         *logrus.Logger
+	client         *http.Client
+	// apiKeys and quotas are kept per attribute since every attribute has its own upstream
+	apiKeys map[string]string
+	quotas  map[string]*quota
 }
 
-func New(ageUrl, genderUrl, nationalityUrl string, logger *logrus.Logger) (*Enrichment, error) {
-	if ageUrl == "" || genderUrl == "" || nationalityUrl == "" {
+func New(cfg types.EnrichmentUrlsConfig, logger *logrus.Logger) (*Enrichment, error) {
+	client, err := newHTTPClient(cfg.HTTP)
+	if err != nil {
+		logger.WithError(err).Errorln("Error creating enrichment HTTP client")
+		return &Enrichment{}, err
+	}
+
+	return NewWithClient(cfg, client, logger)
+}
+
+// NewWithClient uses the given client instead of the one built from cfg.HTTP,
+// e.g. the client of a fake server or a replaying transport from enrichmenttest
+func NewWithClient(cfg types.EnrichmentUrlsConfig, client *http.Client, logger *logrus.Logger) (*Enrichment, error) {
+	if cfg.AgeUrl == "" || cfg.GenderUrl == "" || cfg.NationalityUrl == "" {
 		logrus.Errorf("Age URL or Gender URL or National URL are required")
 		return &Enrichment{}, errors.New("Empty parameter")
 	}
+
+	apiKeys := make(map[string]string, 3)
+	for attribute, source := range map[string][2]string{
+		types.AttributeAge:         {cfg.AgeApiKey, cfg.AgeApiKeyFile},
+		types.AttributeGender:      {cfg.GenderApiKey, cfg.GenderApiKeyFile},
+		types.AttributeNationality: {cfg.NationalityApiKey, cfg.NationalityApiKeyFile},
+	} {
+		key, err := apiKey(source[0], source[1])
+		if err != nil {
+			logger.WithError(err).Errorf("Error loading %s API key", attribute)
+			return &Enrichment{}, err
+		}
+		if key != "" {
+			apiKeys[attribute] = key
+		}
+	}
+
 	return &Enrichment{
-		AgeUrl:         ageUrl,
-		GenderUrl:      genderUrl,
-		NationalityUrl: nationalityUrl,
+		AgeUrl:         cfg.AgeUrl,
+		GenderUrl:      cfg.GenderUrl,
+		NationalityUrl: cfg.NationalityUrl,
 		Logger:         logger,
+		client:         client,
+		apiKeys:        apiKeys,
+		quotas: map[string]*quota{
+			types.AttributeAge:         newQuota(types.AttributeAge),
+			types.AttributeGender:      newQuota(types.AttributeGender),
+			types.AttributeNationality: newQuota(types.AttributeNationality),
+		},
 	}, nil
 }
 
-func (e *Enrichment) Age(name string) (uint8, error) {
+func (e *Enrichment) Age(ctx context.Context, name, country string) (types.AgeData, error) {
 	var ageData types.AgeData
 
-	url := e.AgeUrl + types.NameParam + name
-	body, err := e.httpGet(url)
+	requestUrl := e.requestUrl(types.AttributeAge, e.AgeUrl, withCountry(url.Values{types.NameParam: {name}}, country))
+	body, err := e.httpGet(ctx, types.AttributeAge, requestUrl)
 	if err != nil {
 		e.Logger.WithError(err).Errorln("Error getting age by request")
-		return 0, err
+		return types.AgeData{}, err
 	}
 
 	err = json.Unmarshal(body, &ageData)
 	if err != nil {
 		e.Logger.WithError(err).Errorln("Error unmarshal Age response body")
-		return 0, err
+		return types.AgeData{}, err
 	}
 
-	return ageData.Age, nil
+	return ageData, nil
 }
 
-func (e *Enrichment) Gender(name string) (string, error) {
+func (e *Enrichment) Gender(ctx context.Context, name, country string) (types.GenderData, error) {
 	var genderData types.GenderData
 
-	url := e.GenderUrl + types.NameParam + name
-	body, err := e.httpGet(url)
+	requestUrl := e.requestUrl(types.AttributeGender, e.GenderUrl, withCountry(url.Values{types.NameParam: {name}}, country))
+	body, err := e.httpGet(ctx, types.AttributeGender, requestUrl)
 	if err != nil {
 		e.Logger.WithError(err).Errorln("Error getting gender by request")
-		return "", err
+		return types.GenderData{}, err
 	}
 
 	err = json.Unmarshal(body, &genderData)
 	if err != nil {
 		e.Logger.WithError(err).Errorln("Error unmarshal gender response body")
-		return "", err
+		return types.GenderData{}, err
 	}
 
-	return genderData.Gender, nil
+	return genderData, nil
 }
 
-func (e *Enrichment) Nationality(name string) (string, error) {
+func (e *Enrichment) Nationality(ctx context.Context, name string) (types.NationalityData, error) {
 	var nationality types.NationalityData
 
-	url := e.NationalityUrl + types.NameParam + name
-	body, err := e.httpGet(url)
+	requestUrl := e.requestUrl(types.AttributeNationality, e.NationalityUrl, url.Values{types.NameParam: {name}})
+	body, err := e.httpGet(ctx, types.AttributeNationality, requestUrl)
 	if err != nil {
 		e.Logger.WithError(err).Errorln("Error getting nationality by request")
-		return "", err
+		return types.NationalityData{}, err
 	}
 
 	err = json.Unmarshal(body, &nationality)
 	if err != nil {
 		e.Logger.WithError(err).Errorln("Error unmarshal nationality response body")
-		return "", err
+		return types.NationalityData{}, err
 	}
 
+	nationality = normalizeCountries(nationality, e.Logger)
+
 	if len(nationality.Country) == 0 {
 		e.Logger.WithError(types.ErrNotFound).Errorln("Error getting nationality")
-		return "", types.ErrNotFound
+		return types.NationalityData{}, types.ErrNotFound
+	}
+
+	return nationality, nil
+}
+
+func (e *Enrichment) AgeBatch(ctx context.Context, names []string, country string) (map[string]types.AgeData, error) {
+	result := make(map[string]types.AgeData, len(names))
+
+	for _, batch := range chunks(names) {
+		ageData, err := batchGet[types.AgeData](ctx, e, types.AttributeAge, e.AgeUrl, batch, country)
+		if err != nil {
+			e.Logger.WithError(err).Errorln("Error getting ages by batch request")
+			return nil, err
+		}
+
+		for i, data := range ageData {
+			result[batch[i]] = data
+		}
+	}
+
+	return result, nil
+}
+
+func (e *Enrichment) GenderBatch(ctx context.Context, names []string, country string) (map[string]types.GenderData, error) {
+	result := make(map[string]types.GenderData, len(names))
+
+	for _, batch := range chunks(names) {
+		genderData, err := batchGet[types.GenderData](ctx, e, types.AttributeGender, e.GenderUrl, batch, country)
+		if err != nil {
+			e.Logger.WithError(err).Errorln("Error getting genders by batch request")
+			return nil, err
+		}
+
+		for i, data := range genderData {
+			result[batch[i]] = data
+		}
+	}
+
+	return result, nil
+}
+
+func (e *Enrichment) NationalityBatch(ctx context.Context, names []string) (map[string]types.NationalityData, error) {
+	result := make(map[string]types.NationalityData, len(names))
+
+	for _, batch := range chunks(names) {
+		nationalityData, err := batchGet[types.NationalityData](ctx, e, types.AttributeNationality, e.NationalityUrl, batch, "")
+		if err != nil {
+			e.Logger.WithError(err).Errorln("Error getting nationalities by batch request")
+			return nil, err
+		}
+
+		for i, data := range nationalityData {
+			data = normalizeCountries(data, e.Logger)
+			if len(data.Country) == 0 {
+				continue
+			}
+			result[batch[i]] = data
+		}
+	}
+
+	return result, nil
+}
+
+// batchGet queries up to MaxBatchSize names with the name[] parameter,
+// the upstream answers with an array in the order of the names
+func batchGet[T any](ctx context.Context, e *Enrichment, provider, baseUrl string, names []string, country string) ([]T, error) {
+	requestUrl := e.requestUrl(provider, baseUrl, withCountry(url.Values{types.BatchNameParam: names}, country))
+
+	body, err := e.httpGet(ctx, provider, requestUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []T
+	err = json.Unmarshal(body, &data)
+	if err != nil {
+		e.Logger.WithError(err).Errorln("Error unmarshal batch response body")
+		return nil, err
+	}
+
+	if len(data) != len(names) {
+		err = fmt.Errorf("batch response has %d results for %d names", len(data), len(names))
+		e.Logger.WithError(err).Errorln("Invalid batch response")
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// Quotas returns the last known rate limits of the upstreams
+func (e *Enrichment) Quotas() []types.QuotaState {
+	return []types.QuotaState{
+		e.quotas[types.AttributeAge].state(),
+		e.quotas[types.AttributeGender].state(),
+		e.quotas[types.AttributeNationality].state(),
 	}
+}
 
-	return nationality.Country[0].ID, nil
+// withCountry adds the country hint to the query when there is one
+func withCountry(query url.Values, country string) url.Values {
+	if country != "" {
+		query.Set(types.CountryParam, country)
+	}
+	return query
 }
 
-func (e *Enrichment) httpGet(url string) ([]byte, error) {
-	resp, err := http.Get(url)
+// requestUrl adds the API key of the provider to the query, the names are escaped by Encode
+func (e *Enrichment) requestUrl(provider, baseUrl string, query url.Values) string {
+	if key, ok := e.apiKeys[provider]; ok {
+		query.Set(types.ApiKeyParam, key)
+	}
+	return strings.TrimSuffix(baseUrl, "/") + "/?" + query.Encode()
+}
+
+// httpGet requests the upstream of the provider unless it is known to be rate limited,
+// non 2xx answers are returned as errors
+func (e *Enrichment) httpGet(ctx context.Context, provider, requestUrl string) ([]byte, error) {
+	quota := e.quotas[provider]
+
+	err := quota.allow(time.Now())
 	if err != nil {
+		e.Logger.WithError(err).Warnln("Skipping rate limited request")
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestUrl, nil)
+	if err != nil {
+		e.Logger.WithError(err).Errorln("Error creating request")
+		return nil, err
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		err = redactUrl(err)
 		e.Logger.WithError(err).Errorln("Request failed")
 		return nil, err
 	}
 
 	defer resp.Body.Close()
+
+	err = quota.update(resp, time.Now())
+	if err != nil {
+		e.Logger.WithError(err).Errorln("Request rate limited")
+		return nil, err
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		e.Logger.WithError(err).Errorln("Error get response body")
 		return nil, err
 	}
 
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		err = fmt.Errorf("%s provider answered %s: %s", provider, resp.Status, strings.TrimSpace(string(body)))
+		e.Logger.WithError(err).Errorln("Request failed")
+		return nil, err
+	}
+
 	return body, nil
 }
@@ -0,0 +1,166 @@
+package enrichmenttest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+
+	"people/internal/types"
+)
+
+// ErrNoFixture is returned in the replay mode for a request that was never recorded
+var ErrNoFixture = errors.New("no recorded response")
+
+type Mode int
+
+const (
+	// ModeReplay answers from the fixture file without network access
+	ModeReplay Mode = iota
+	// ModeRecord sends the requests upstream and keeps the answers for Save
+	ModeRecord
+)
+
+// Interaction is a recorded request with its response, the API key is never stored
+type Interaction struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body"`
+}
+
+// Recorder is a RoundTripper that records upstream answers into a JSON fixture file and replays them
+type Recorder struct {
+	mode Mode
+	path string
+	next http.RoundTripper
+
+	mu           sync.Mutex
+	interactions []Interaction
+	replayed     map[string]int
+}
+
+// NewRecorder loads the fixture file in the replay mode, next is used for recording,
+// http.DefaultTransport when nil
+func NewRecorder(path string, mode Mode, next http.RoundTripper) (*Recorder, error) {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	r := &Recorder{
+		mode:     mode,
+		path:     path,
+		next:     next,
+		replayed: make(map[string]int),
+	}
+
+	if mode == ModeReplay {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading fixture: %w", err)
+		}
+		err = json.Unmarshal(data, &r.interactions)
+		if err != nil {
+			return nil, fmt.Errorf("parsing fixture %s: %w", path, err)
+		}
+	}
+
+	return r, nil
+}
+
+// Client returns an http.Client using the recorder, e.g. for enrichment.NewWithClient
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	requestUrl := redact(req.URL)
+
+	if r.mode == ModeReplay {
+		return r.replay(req, requestUrl)
+	}
+
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	r.mu.Lock()
+	r.interactions = append(r.interactions, Interaction{
+		Method: req.Method,
+		URL:    requestUrl,
+		Status: resp.StatusCode,
+		Header: resp.Header.Clone(),
+		Body:   string(body),
+	})
+	r.mu.Unlock()
+
+	return resp, nil
+}
+
+// replay answers with the recorded interactions of the request in the order they were recorded,
+// the last one is repeated when the request is sent more often than it was recorded
+func (r *Recorder) replay(req *http.Request, requestUrl string) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var matches []Interaction
+	for _, interaction := range r.interactions {
+		if interaction.Method == req.Method && interaction.URL == requestUrl {
+			matches = append(matches, interaction)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("%s %s: %w", req.Method, requestUrl, ErrNoFixture)
+	}
+
+	key := req.Method + " " + requestUrl
+	interaction := matches[min(r.replayed[key], len(matches)-1)]
+	r.replayed[key]++
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", interaction.Status, http.StatusText(interaction.Status)),
+		StatusCode:    interaction.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        interaction.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader([]byte(interaction.Body))),
+		ContentLength: int64(len(interaction.Body)),
+		Request:       req,
+	}, nil
+}
+
+// Save writes the recorded interactions to the fixture file
+func (r *Recorder) Save() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data, err := json.MarshalIndent(r.interactions, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.path, data, 0o644)
+}
+
+// redact drops the API key so that fixtures can be committed and replayed without one
+func redact(requestUrl *url.URL) string {
+	redacted := *requestUrl
+	query := redacted.Query()
+	query.Del(types.ApiKeyParam)
+	redacted.RawQuery = query.Encode()
+	return redacted.String()
+}
@@ -0,0 +1,148 @@
+package enrichmenttest_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"people/internal/repository/enrichment"
+	"people/internal/repository/enrichment/enrichmenttest"
+)
+
+// record sends the lookups of fn to the fake through a recording transport and saves the fixture
+func record(t *testing.T, server *enrichmenttest.Server, apiKey string, fn func(provider *enrichment.Enrichment)) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "fixture.json")
+	recorder, err := enrichmenttest.NewRecorder(path, enrichmenttest.ModeRecord, server.Client().Transport)
+	if err != nil {
+		t.Fatalf("NewRecorder: %v", err)
+	}
+
+	cfg := server.Config()
+	cfg.AgeApiKey = apiKey
+	provider, err := enrichment.NewWithClient(cfg, recorder.Client(), quietLogger())
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+
+	fn(provider)
+
+	if err = recorder.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	return path
+}
+
+// replay answers from the fixture, cfg still points at the fake that may be gone by now
+func replay(t *testing.T, server *enrichmenttest.Server, path, apiKey string) *enrichment.Enrichment {
+	t.Helper()
+
+	recorder, err := enrichmenttest.NewRecorder(path, enrichmenttest.ModeReplay, nil)
+	if err != nil {
+		t.Fatalf("NewRecorder: %v", err)
+	}
+
+	cfg := server.Config()
+	cfg.AgeApiKey = apiKey
+	provider, err := enrichment.NewWithClient(cfg, recorder.Client(), quietLogger())
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	return provider
+}
+
+func TestRecorderReplaysWithoutTheUpstream(t *testing.T) {
+	server := enrichmenttest.NewServer()
+	server.SetAge("Ann", "", 31, 120)
+	server.SetGender("Ann", "", "female", 0.98, 300)
+
+	path := record(t, server, "", func(provider *enrichment.Enrichment) {
+		if _, err := provider.Age(context.Background(), "Ann", ""); err != nil {
+			t.Fatalf("Age() while recording error = %v", err)
+		}
+		if _, err := provider.Gender(context.Background(), "Ann", ""); err != nil {
+			t.Fatalf("Gender() while recording error = %v", err)
+		}
+	})
+
+	server.Close()
+	provider := replay(t, server, path, "")
+
+	age, err := provider.Age(context.Background(), "Ann", "")
+	if err != nil || age.Age != 31 {
+		t.Fatalf("replayed Age() = %+v, %v, want 31", age, err)
+	}
+
+	gender, err := provider.Gender(context.Background(), "Ann", "")
+	if err != nil || gender.Gender != "female" {
+		t.Fatalf("replayed Gender() = %+v, %v, want female", gender, err)
+	}
+
+	_, err = provider.Age(context.Background(), "Bob", "")
+	if !errors.Is(err, enrichmenttest.ErrNoFixture) {
+		t.Fatalf("Age() of a name never recorded error = %v, want ErrNoFixture", err)
+	}
+}
+
+func TestRecorderRedactsApiKey(t *testing.T) {
+	server := enrichmenttest.NewServer()
+	defer server.Close()
+
+	server.SetAge("Ann", "", 31, 120)
+	server.RequireApiKey("top-secret-key")
+
+	path := record(t, server, "top-secret-key", func(provider *enrichment.Enrichment) {
+		if _, err := provider.Age(context.Background(), "Ann", ""); err != nil {
+			t.Fatalf("Age() while recording error = %v", err)
+		}
+	})
+
+	fixture, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading fixture: %v", err)
+	}
+	if strings.Contains(string(fixture), "top-secret-key") || strings.Contains(string(fixture), "apikey") {
+		t.Fatalf("fixture keeps the API key:\n%s", fixture)
+	}
+
+	// a fixture recorded with a key is replayed with another one, or with none
+	for _, apiKey := range []string{"another-key", ""} {
+		age, err := replay(t, server, path, apiKey).Age(context.Background(), "Ann", "")
+		if err != nil || age.Age != 31 {
+			t.Fatalf("replayed Age() with key %q = %+v, %v, want 31", apiKey, age, err)
+		}
+	}
+}
+
+func TestRecorderReplaysInOrderAndRepeatsTheLast(t *testing.T) {
+	server := enrichmenttest.NewServer()
+	defer server.Close()
+
+	path := record(t, server, "", func(provider *enrichment.Enrichment) {
+		for _, age := range []uint8{31, 32} {
+			server.SetAge("Ann", "", age, 120)
+			if _, err := provider.Age(context.Background(), "Ann", ""); err != nil {
+				t.Fatalf("Age() while recording error = %v", err)
+			}
+		}
+	})
+
+	provider := replay(t, server, path, "")
+	for _, want := range []uint8{31, 32, 32} {
+		age, err := provider.Age(context.Background(), "Ann", "")
+		if err != nil || age.Age != want {
+			t.Fatalf("replayed Age() = %+v, %v, want %d", age, err, want)
+		}
+	}
+}
+
+func TestRecorderRequiresFixtureInReplayMode(t *testing.T) {
+	_, err := enrichmenttest.NewRecorder(filepath.Join(t.TempDir(), "missing.json"), enrichmenttest.ModeReplay, nil)
+	if err == nil {
+		t.Fatal("NewRecorder() of a missing fixture error = nil")
+	}
+}
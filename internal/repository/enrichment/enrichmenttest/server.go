@@ -0,0 +1,288 @@
+// Package enrichmenttest provides a fake of the agify, genderize and nationalize APIs
+// and a record/replay transport for testing the enrichment offline
+package enrichmenttest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"people/internal/repository/enrichment"
+	"people/internal/types"
+)
+
+// paths of the fake upstreams
+const (
+	AgePath         = "/agify"
+	GenderPath      = "/genderize"
+	NationalityPath = "/nationalize"
+)
+
+// Failure is an injected answer of an upstream
+type Failure struct {
+	Status int
+	Body   string
+	// Times is the number of requests that fail, 0 fails every request until ClearFailure
+	Times int
+}
+
+// Server fakes the three upstreams. Unknown names are answered like the real APIs do:
+// a null age, a null gender and no countries
+type Server struct {
+	*httptest.Server
+
+	mu            sync.Mutex
+	ages          map[string]types.AgeData
+	genders       map[string]types.GenderData
+	nationalities map[string]types.NationalityData
+	latency       time.Duration
+	failures      map[string]*Failure
+	apiKey        string
+	limit         int
+	remaining     int
+	reset         time.Duration
+	requests      map[string][]*http.Request
+}
+
+// NewServer starts a fake with no names, Close it when done
+func NewServer() *Server {
+	s := &Server{
+		ages:          make(map[string]types.AgeData),
+		genders:       make(map[string]types.GenderData),
+		nationalities: make(map[string]types.NationalityData),
+		failures:      make(map[string]*Failure),
+		requests:      make(map[string][]*http.Request),
+		limit:         -1,
+	}
+
+	mux := http.NewServeMux()
+	for path, answer := range map[string]func(name, country string) any{
+		AgePath:         s.age,
+		GenderPath:      s.gender,
+		NationalityPath: s.nationality,
+	} {
+		// the provider asks for "<url>/?name=..."
+		mux.HandleFunc(path, s.handle(path, answer))
+		mux.HandleFunc(path+"/", s.handle(path, answer))
+	}
+	s.Server = httptest.NewServer(mux)
+
+	return s
+}
+
+// Config points the "api" provider at the fake
+func (s *Server) Config() types.EnrichmentUrlsConfig {
+	return types.EnrichmentUrlsConfig{
+		Provider:       enrichment.DefaultProvider,
+		AgeUrl:         s.URL + AgePath,
+		GenderUrl:      s.URL + GenderPath,
+		NationalityUrl: s.URL + NationalityPath,
+	}
+}
+
+// Provider returns the "api" provider talking to the fake
+func (s *Server) Provider(logger *logrus.Logger) (*enrichment.Enrichment, error) {
+	return enrichment.NewWithClient(s.Config(), s.Client(), logger)
+}
+
+// SetAge answers the age of the name, country is the country_id it applies to, empty for any
+func (s *Server) SetAge(name, country string, age uint8, count uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ages[key(name, country)] = types.AgeData{Name: name, Age: age, Count: count}
+}
+
+// SetGender answers the gender of the name, country is the country_id it applies to, empty for any
+func (s *Server) SetGender(name, country, gender string, probability float32, count uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.genders[key(name, country)] = types.GenderData{Name: name, Gender: gender, Probability: probability, Count: count}
+}
+
+// SetNationality answers the country candidates of the name, the most probable first
+func (s *Server) SetNationality(name string, count uint64, countries ...types.CountryData) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nationalities[key(name, "")] = types.NationalityData{Name: name, Count: count, Country: countries}
+}
+
+// SetLatency delays every answer, the delay is cut short when the client gives up
+func (s *Server) SetLatency(latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.latency = latency
+}
+
+// Fail injects a failure into the upstream at path
+func (s *Server) Fail(path string, failure Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures[path] = &failure
+}
+
+// ClearFailure makes the upstream at path answer again
+func (s *Server) ClearFailure(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.failures, path)
+}
+
+// RequireApiKey rejects requests without the apikey parameter with 401
+func (s *Server) RequireApiKey(apiKey string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.apiKey = apiKey
+}
+
+// SetRateLimit sends the X-Rate-Limit headers, requests beyond remaining get 429 with Retry-After
+func (s *Server) SetRateLimit(limit, remaining int, reset time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.limit = limit
+	s.remaining = remaining
+	s.reset = reset
+}
+
+// Requests returns the requests the upstream at path has received
+func (s *Server) Requests(path string) []*http.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]*http.Request(nil), s.requests[path]...)
+}
+
+func (s *Server) handle(path string, answer func(name, country string) any) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests[path] = append(s.requests[path], r)
+		latency := s.latency
+		s.mu.Unlock()
+
+		if latency > 0 {
+			select {
+			case <-time.After(latency):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		s.mu.Lock()
+		status, body := s.reject(path, w, r)
+		s.mu.Unlock()
+
+		if status != 0 {
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(body))
+			return
+		}
+
+		query := r.URL.Query()
+		country := query.Get(types.CountryParam)
+
+		var response any
+		if names, ok := query[types.BatchNameParam]; ok {
+			answers := make([]any, 0, len(names))
+			for _, name := range names {
+				answers = append(answers, answer(name, country))
+			}
+			response = answers
+		} else {
+			response = answer(query.Get(types.NameParam), country)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(response)
+	}
+}
+
+// reject applies the injected failures, the API key and the rate limit, it is called with mu held
+func (s *Server) reject(path string, w http.ResponseWriter, r *http.Request) (int, string) {
+	if failure, ok := s.failures[path]; ok {
+		if failure.Times > 0 {
+			failure.Times--
+			if failure.Times == 0 {
+				delete(s.failures, path)
+			}
+		}
+		return failure.Status, failure.Body
+	}
+
+	if s.apiKey != "" && r.URL.Query().Get(types.ApiKeyParam) != s.apiKey {
+		return http.StatusUnauthorized, `{"error":"Invalid API key"}`
+	}
+
+	if s.limit < 0 {
+		return 0, ""
+	}
+
+	reset := strconv.Itoa(int(s.reset / time.Second))
+	w.Header().Set("X-Rate-Limit-Limit", strconv.Itoa(s.limit))
+	w.Header().Set("X-Rate-Limit-Reset", reset)
+
+	if s.remaining <= 0 {
+		w.Header().Set("X-Rate-Limit-Remaining", "0")
+		w.Header().Set("Retry-After", reset)
+		return http.StatusTooManyRequests, `{"error":"Request limit reached"}`
+	}
+
+	s.remaining--
+	w.Header().Set("X-Rate-Limit-Remaining", strconv.Itoa(s.remaining))
+	return 0, ""
+}
+
+func (s *Server) age(name, country string) any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// AgeData has no json tags, the answer is spelled like agify's
+	if data, ok := lookup(s.ages, name, country); ok {
+		return map[string]any{"name": name, "age": data.Age, "count": data.Count}
+	}
+	return map[string]any{"name": name, "age": nil, "count": 0}
+}
+
+func (s *Server) gender(name, country string) any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if data, ok := lookup(s.genders, name, country); ok {
+		return data
+	}
+	return map[string]any{"name": name, "gender": nil, "probability": 0, "count": 0}
+}
+
+func (s *Server) nationality(name, _ string) any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if data, ok := lookup(s.nationalities, name, ""); ok {
+		return data
+	}
+	return types.NationalityData{Name: name, Country: []types.CountryData{}}
+}
+
+// lookup prefers the answer for the country and falls back to the one for any country
+func lookup[V any](answers map[string]V, name, country string) (V, bool) {
+	if data, ok := answers[key(name, country)]; ok {
+		return data, true
+	}
+	data, ok := answers[key(name, "")]
+	return data, ok
+}
+
+func key(name, country string) string {
+	return strings.ToUpper(country) + ":" + strings.ToLower(strings.TrimSpace(name))
+}
@@ -0,0 +1,246 @@
+package enrichmenttest_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"people/internal/repository/enrichment"
+	"people/internal/repository/enrichment/enrichmenttest"
+	"people/internal/types"
+)
+
+func quietLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+func newProvider(t *testing.T, server *enrichmenttest.Server) *enrichment.Enrichment {
+	t.Helper()
+
+	provider, err := server.Provider(quietLogger())
+	if err != nil {
+		t.Fatalf("Provider: %v", err)
+	}
+	return provider
+}
+
+func TestServerAnswersKnownNames(t *testing.T) {
+	server := enrichmenttest.NewServer()
+	defer server.Close()
+
+	server.SetAge("Ann", "", 31, 120)
+	server.SetAge("Ann", "DE", 42, 12)
+	server.SetGender("Ann", "", "female", 0.98, 300)
+	server.SetNationality("Ann", 500, types.CountryData{ID: "DE", Probability: 0.4}, types.CountryData{ID: "FR", Probability: 0.2})
+
+	provider := newProvider(t, server)
+	ctx := context.Background()
+
+	age, err := provider.Age(ctx, "ann", "")
+	if err != nil || age.Age != 31 || age.Count != 120 {
+		t.Fatalf("Age() = %+v, %v, want 31 from 120", age, err)
+	}
+
+	age, err = provider.Age(ctx, "Ann", "DE")
+	if err != nil || age.Age != 42 {
+		t.Fatalf("Age(DE) = %+v, %v, want the answer for DE", age, err)
+	}
+
+	age, err = provider.Age(ctx, "Ann", "FR")
+	if err != nil || age.Age != 31 {
+		t.Fatalf("Age(FR) = %+v, %v, want the answer for any country", age, err)
+	}
+
+	gender, err := provider.Gender(ctx, "Ann", "")
+	if err != nil || gender.Gender != "female" {
+		t.Fatalf("Gender() = %+v, %v, want female", gender, err)
+	}
+
+	nationality, err := provider.Nationality(ctx, "Ann")
+	if err != nil || len(nationality.Country) != 2 || nationality.Country[0].ID != "DE" {
+		t.Fatalf("Nationality() = %+v, %v, want DE then FR", nationality, err)
+	}
+
+	requests := server.Requests(enrichmenttest.AgePath)
+	if len(requests) != 3 || requests[1].URL.Query().Get(types.CountryParam) != "DE" {
+		t.Fatalf("age upstream got %d requests, want 3 with the country hint on the second", len(requests))
+	}
+}
+
+func TestServerAnswersUnknownNamesLikeTheRealApis(t *testing.T) {
+	server := enrichmenttest.NewServer()
+	defer server.Close()
+
+	provider := newProvider(t, server)
+	ctx := context.Background()
+
+	age, err := provider.Age(ctx, "Nobody", "")
+	if err != nil || age.Age != 0 || age.Count != 0 {
+		t.Fatalf("Age() = %+v, %v, want a null age", age, err)
+	}
+
+	gender, err := provider.Gender(ctx, "Nobody", "")
+	if err != nil || gender.Gender != "" {
+		t.Fatalf("Gender() = %+v, %v, want a null gender", gender, err)
+	}
+
+	_, err = provider.Nationality(ctx, "Nobody")
+	if !errors.Is(err, types.ErrNotFound) {
+		t.Fatalf("Nationality() error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestServerAnswersBatches(t *testing.T) {
+	server := enrichmenttest.NewServer()
+	defer server.Close()
+
+	server.SetAge("Ann", "", 31, 120)
+	server.SetAge("Bob", "", 45, 80)
+
+	provider := newProvider(t, server)
+
+	ages, err := provider.AgeBatch(context.Background(), []string{"Ann", "Bob"}, "")
+	if err != nil {
+		t.Fatalf("AgeBatch() error = %v", err)
+	}
+	if ages["Ann"].Age != 31 || ages["Bob"].Age != 45 {
+		t.Fatalf("AgeBatch() = %+v, want Ann 31 and Bob 45", ages)
+	}
+
+	if requests := server.Requests(enrichmenttest.AgePath); len(requests) != 1 {
+		t.Fatalf("age upstream got %d requests, want a single batch request", len(requests))
+	}
+}
+
+func TestServerInjectsFailures(t *testing.T) {
+	server := enrichmenttest.NewServer()
+	defer server.Close()
+
+	server.SetAge("Ann", "", 31, 120)
+	server.Fail(enrichmenttest.AgePath, enrichmenttest.Failure{Status: http.StatusBadGateway, Body: "down", Times: 1})
+
+	provider := newProvider(t, server)
+	ctx := context.Background()
+
+	if _, err := provider.Age(ctx, "Ann", ""); err == nil {
+		t.Fatal("Age() error = nil, want the injected failure")
+	}
+
+	age, err := provider.Age(ctx, "Ann", "")
+	if err != nil || age.Age != 31 {
+		t.Fatalf("Age() after the failure = %+v, %v, want 31", age, err)
+	}
+
+	server.Fail(enrichmenttest.GenderPath, enrichmenttest.Failure{Status: http.StatusInternalServerError})
+	for range 2 {
+		if _, err := provider.Gender(ctx, "Ann", ""); err == nil {
+			t.Fatal("Gender() error = nil, want the failure until ClearFailure")
+		}
+	}
+
+	server.ClearFailure(enrichmenttest.GenderPath)
+	if _, err := provider.Gender(ctx, "Ann", ""); err != nil {
+		t.Fatalf("Gender() after ClearFailure error = %v", err)
+	}
+}
+
+func TestServerRequiresApiKey(t *testing.T) {
+	server := enrichmenttest.NewServer()
+	defer server.Close()
+
+	server.SetAge("Ann", "", 31, 120)
+	server.RequireApiKey("secret")
+
+	provider := newProvider(t, server)
+	if _, err := provider.Age(context.Background(), "Ann", ""); err == nil {
+		t.Fatal("Age() without API key error = nil, want 401")
+	}
+
+	cfg := server.Config()
+	cfg.AgeApiKey = "secret"
+	provider, err := enrichment.NewWithClient(cfg, server.Client(), quietLogger())
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+
+	age, err := provider.Age(context.Background(), "Ann", "")
+	if err != nil || age.Age != 31 {
+		t.Fatalf("Age() with API key = %+v, %v, want 31", age, err)
+	}
+}
+
+func TestServerRateLimits(t *testing.T) {
+	server := enrichmenttest.NewServer()
+	defer server.Close()
+
+	server.SetAge("Ann", "", 31, 120)
+	server.SetRateLimit(100, 1, 30*time.Second)
+
+	provider := newProvider(t, server)
+	ctx := context.Background()
+
+	if _, err := provider.Age(ctx, "Ann", ""); err != nil {
+		t.Fatalf("Age() within the quota error = %v", err)
+	}
+
+	quotas := enrichment.Quotas(provider)
+	if quotas[0].Remaining == nil || *quotas[0].Remaining != 0 {
+		t.Fatalf("age quota = %+v, want 0 requests remaining", quotas[0])
+	}
+
+	_, err := provider.Age(ctx, "Ann", "")
+	var rateLimitErr *types.RateLimitError
+	if !errors.As(err, &rateLimitErr) || rateLimitErr.RetryAfter <= 0 || rateLimitErr.RetryAfter > 30*time.Second {
+		t.Fatalf("Age() beyond the quota error = %v, want a RateLimitError of up to 30s", err)
+	}
+
+	// the known quota is not spent on a request that would be rejected
+	if requests := server.Requests(enrichmenttest.AgePath); len(requests) != 1 {
+		t.Fatalf("age upstream got %d requests, want 1", len(requests))
+	}
+}
+
+func TestServerAnswers429WithRetryAfter(t *testing.T) {
+	server := enrichmenttest.NewServer()
+	defer server.Close()
+
+	server.SetRateLimit(100, 0, 20*time.Second)
+
+	provider := newProvider(t, server)
+
+	_, err := provider.Gender(context.Background(), "Ann", "")
+	var rateLimitErr *types.RateLimitError
+	if !errors.As(err, &rateLimitErr) || rateLimitErr.RetryAfter != 20*time.Second {
+		t.Fatalf("Gender() error = %v, want a RateLimitError of 20s", err)
+	}
+	if !errors.Is(err, types.ErrRateLimited) {
+		t.Fatalf("Gender() error = %v, want it to match ErrRateLimited", err)
+	}
+}
+
+func TestServerLatencyIsCutShortByTheClient(t *testing.T) {
+	server := enrichmenttest.NewServer()
+	defer server.Close()
+
+	server.SetLatency(time.Minute)
+
+	provider := newProvider(t, server)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := provider.Age(ctx, "Ann", "")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Age() error = %v, want the deadline of the client", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("Age() took %s, want it to give up with the client", elapsed)
+	}
+}
@@ -0,0 +1,153 @@
+package enrichment
+
+import (
+	"context"
+	_ "embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"people/internal/types"
+)
+
+// LocalProvider is the name of the offline provider in config.yml
+const LocalProvider = "local"
+
+//go:embed names.csv
+var embeddedNames string
+
+func init() {
+	Register(LocalProvider, func(cfg types.EnrichmentUrlsConfig, logger *logrus.Logger) (EnrichmentProvider, error) {
+		return NewLocal(cfg.LocalFile, logger)
+	})
+}
+
+type nameStats struct {
+	Age         uint8
+	Gender      string
+	Nationality string
+}
+
+// Local answers enrichment lookups from a name statistics table without network access
+type Local struct {
+	names  map[string]nameStats
+	Logger *logrus.Logger
+}
+
+// NewLocal loads the statistics from a CSV file (name,age,gender,nationality),
+// falling back to the table embedded into the binary when path is empty
+func NewLocal(path string, logger *logrus.Logger) (*Local, error) {
+	var source io.Reader = strings.NewReader(embeddedNames)
+
+	if path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			logger.WithError(err).Errorln("Error opening local names file")
+			return &Local{}, err
+		}
+		defer file.Close()
+		source = file
+	}
+
+	names, err := readNames(source)
+	if err != nil {
+		logger.WithError(err).Errorln("Error reading local names table")
+		return &Local{}, err
+	}
+
+	return &Local{
+		names:  names,
+		Logger: logger,
+	}, nil
+}
+
+func readNames(source io.Reader) (map[string]nameStats, error) {
+	reader := csv.NewReader(source)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = 4
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, errors.New("empty names table")
+	}
+
+	names := make(map[string]nameStats, len(records))
+	// the first record is the header
+	for i, record := range records[1:] {
+		age, err := strconv.ParseUint(strings.TrimSpace(record[1]), 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid age: %w", i+2, err)
+		}
+
+		nationality, err := types.NormalizeCountry(record[3])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+2, err)
+		}
+
+		names[normalizeName(record[0])] = nameStats{
+			Age:         uint8(age),
+			Gender:      strings.TrimSpace(record[2]),
+			Nationality: nationality,
+		}
+	}
+
+	return names, nil
+}
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+func (l *Local) lookup(ctx context.Context, name string) (nameStats, error) {
+	if err := ctx.Err(); err != nil {
+		return nameStats{}, err
+	}
+
+	stats, ok := l.names[normalizeName(name)]
+	if !ok {
+		l.Logger.WithField("name", name).Warnln("Name not found in local table")
+		return nameStats{}, types.ErrNotFound
+	}
+
+	return stats, nil
+}
+
+// Age returns the typical age, the local table carries no sample counts and has one row per name,
+// so the country hint is ignored
+func (l *Local) Age(ctx context.Context, name, country string) (types.AgeData, error) {
+	stats, err := l.lookup(ctx, name)
+	if err != nil {
+		return types.AgeData{}, err
+	}
+	return types.AgeData{Name: name, Age: stats.Age}, nil
+}
+
+// Gender returns the gender, the local table carries no probabilities
+func (l *Local) Gender(ctx context.Context, name, country string) (types.GenderData, error) {
+	stats, err := l.lookup(ctx, name)
+	if err != nil {
+		return types.GenderData{}, err
+	}
+	return types.GenderData{Name: name, Gender: stats.Gender}, nil
+}
+
+// Nationality returns the single country of the name from the local table
+func (l *Local) Nationality(ctx context.Context, name string) (types.NationalityData, error) {
+	stats, err := l.lookup(ctx, name)
+	if err != nil {
+		return types.NationalityData{}, err
+	}
+	return types.NationalityData{
+		Name:    name,
+		Country: []types.CountryData{{ID: stats.Nationality}},
+	}, nil
+}
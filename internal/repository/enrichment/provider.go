@@ -0,0 +1,83 @@
+package enrichment
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"people/internal/types"
+)
+
+// DefaultProvider is used when config.yml does not select a provider
+const DefaultProvider = "api"
+
+// EnrichmentProvider resolves age, gender and nationality by a first name.
+// country is an optional ISO 3166-1 alpha-2 hint for age and gender, empty when unknown.
+// Nationality returns types.ErrNotFound when there is no country for the name
+type EnrichmentProvider interface {
+	Age(ctx context.Context, name, country string) (types.AgeData, error)
+	Gender(ctx context.Context, name, country string) (types.GenderData, error)
+	Nationality(ctx context.Context, name string) (types.NationalityData, error)
+}
+
+// Factory builds a provider from the enrichment section of config.yml
+type Factory func(cfg types.EnrichmentUrlsConfig, logger *logrus.Logger) (EnrichmentProvider, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register makes a provider available under the given name, usually from init()
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	name = strings.ToLower(name)
+	if factory == nil {
+		panic("enrichment: Register factory is nil")
+	}
+	if _, ok := registry[name]; ok {
+		panic("enrichment: Register called twice for provider " + name)
+	}
+	registry[name] = factory
+}
+
+// Providers returns the names of all registered providers
+func Providers() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	return names
+}
+
+// NewProvider builds the provider selected by cfg.Provider
+func NewProvider(cfg types.EnrichmentUrlsConfig, logger *logrus.Logger) (EnrichmentProvider, error) {
+	name := strings.ToLower(cfg.Provider)
+	if name == "" {
+		name = DefaultProvider
+	}
+
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		logger.Errorf("Unknown enrichment provider %q", name)
+		return nil, fmt.Errorf("unknown enrichment provider %q", name)
+	}
+
+	provider, err := factory(cfg, logger)
+	if err != nil {
+		logger.WithError(err).Errorf("Error creating %s enrichment provider", name)
+		return nil, err
+	}
+
+	logger.Infof("Using %s enrichment provider", name)
+	return WithCircuitBreakers(WithTimeouts(provider, cfg), cfg.Breaker), nil
+}
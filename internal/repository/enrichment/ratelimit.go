@@ -0,0 +1,146 @@
+package enrichment
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"people/internal/types"
+)
+
+const (
+	rateLimitLimitHeader     = "X-Rate-Limit-Limit"
+	rateLimitRemainingHeader = "X-Rate-Limit-Remaining"
+	rateLimitResetHeader     = "X-Rate-Limit-Reset"
+	retryAfterHeader         = "Retry-After"
+
+	// defaultRateLimitBackoff is used when a 429 carries no reset time
+	defaultRateLimitBackoff = time.Minute
+)
+
+// QuotaReporter is implemented by providers that track the upstream quota
+type QuotaReporter interface {
+	Quotas() []types.QuotaState
+}
+
+// Quotas returns the quota state of the provider, looking through the wrapping providers
+func Quotas(provider EnrichmentProvider) []types.QuotaState {
+	for provider != nil {
+		if reporter, ok := provider.(QuotaReporter); ok {
+			return reporter.Quotas()
+		}
+		wrapper, ok := provider.(interface{ Unwrap() EnrichmentProvider })
+		if !ok {
+			break
+		}
+		provider = wrapper.Unwrap()
+	}
+	return nil
+}
+
+// quota tracks the rate limit of one upstream from its response headers
+type quota struct {
+	mu           sync.Mutex
+	provider     string
+	known        bool
+	limit        int
+	remaining    int
+	resetAt      time.Time
+	blockedUntil time.Time
+}
+
+func newQuota(provider string) *quota {
+	return &quota{provider: provider}
+}
+
+// allow fails fast while the upstream is known to reject requests
+func (q *quota) allow(now time.Time) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if now.Before(q.blockedUntil) {
+		return &types.RateLimitError{
+			Provider:   q.provider,
+			RetryAfter: q.blockedUntil.Sub(now),
+		}
+	}
+	return nil
+}
+
+// update reads the quota headers of the response, it returns a RateLimitError for 429 responses
+func (q *quota) update(resp *http.Response, now time.Time) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	reset, hasReset := headerSeconds(resp.Header, rateLimitResetHeader)
+	if hasReset {
+		q.resetAt = now.Add(reset)
+	}
+
+	if limit, err := strconv.Atoi(resp.Header.Get(rateLimitLimitHeader)); err == nil {
+		q.limit = limit
+		q.known = true
+	}
+
+	if remaining, err := strconv.Atoi(resp.Header.Get(rateLimitRemainingHeader)); err == nil {
+		q.remaining = remaining
+		q.known = true
+
+		// the answer is still valid, only the next requests are rejected
+		if remaining <= 0 && hasReset {
+			q.blockedUntil = q.resetAt
+		}
+	}
+
+	if resp.StatusCode != http.StatusTooManyRequests {
+		return nil
+	}
+
+	retryAfter, ok := headerSeconds(resp.Header, retryAfterHeader)
+	if !ok {
+		retryAfter, ok = reset, hasReset
+	}
+	if !ok || retryAfter <= 0 {
+		retryAfter = defaultRateLimitBackoff
+	}
+
+	q.remaining = 0
+	q.blockedUntil = now.Add(retryAfter)
+
+	return &types.RateLimitError{
+		Provider:   q.provider,
+		RetryAfter: retryAfter,
+	}
+}
+
+func (q *quota) state() types.QuotaState {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	state := types.QuotaState{
+		Provider: q.provider,
+	}
+	if q.known {
+		limit, remaining := q.limit, q.remaining
+		state.Limit = &limit
+		state.Remaining = &remaining
+	}
+	if !q.resetAt.IsZero() {
+		resetAt := q.resetAt
+		state.ResetAt = &resetAt
+	}
+	if time.Now().Before(q.blockedUntil) {
+		blockedUntil := q.blockedUntil
+		state.BlockedUntil = &blockedUntil
+	}
+	return state
+}
+
+func headerSeconds(header http.Header, key string) (time.Duration, bool) {
+	seconds, err := strconv.Atoi(header.Get(key))
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
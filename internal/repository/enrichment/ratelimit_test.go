@@ -0,0 +1,146 @@
+package enrichment
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"people/internal/types"
+)
+
+func response(status int, headers map[string]string) *http.Response {
+	resp := &http.Response{StatusCode: status, Header: make(http.Header)}
+	for key, value := range headers {
+		resp.Header.Set(key, value)
+	}
+	return resp
+}
+
+func TestQuotaTracksTheHeaders(t *testing.T) {
+	q := newQuota(types.AttributeAge)
+	now := time.Now()
+
+	err := q.update(response(http.StatusOK, map[string]string{
+		rateLimitLimitHeader:     "1000",
+		rateLimitRemainingHeader: "999",
+		rateLimitResetHeader:     "3600",
+	}), now)
+	if err != nil {
+		t.Fatalf("update() error = %v", err)
+	}
+
+	state := q.state()
+	if state.Limit == nil || *state.Limit != 1000 || state.Remaining == nil || *state.Remaining != 999 {
+		t.Fatalf("state = %+v, want 999 of 1000 remaining", state)
+	}
+	if state.ResetAt == nil || !state.ResetAt.Equal(now.Add(time.Hour)) || state.BlockedUntil != nil {
+		t.Fatalf("state = %+v, want the reset in an hour and nothing blocked", state)
+	}
+	if err = q.allow(now); err != nil {
+		t.Fatalf("allow() error = %v, want requests let through", err)
+	}
+}
+
+func TestQuotaWithoutHeadersIsUnknown(t *testing.T) {
+	q := newQuota(types.AttributeAge)
+
+	if err := q.update(response(http.StatusOK, nil), time.Now()); err != nil {
+		t.Fatalf("update() error = %v", err)
+	}
+	if state := q.state(); state.Limit != nil || state.Remaining != nil || state.ResetAt != nil {
+		t.Fatalf("state = %+v, want nothing known", state)
+	}
+}
+
+func TestQuotaBlocksOnceRemainingReachesZero(t *testing.T) {
+	q := newQuota(types.AttributeAge)
+	now := time.Now()
+
+	// the answer that used up the quota is still valid
+	err := q.update(response(http.StatusOK, map[string]string{
+		rateLimitLimitHeader:     "100",
+		rateLimitRemainingHeader: "0",
+		rateLimitResetHeader:     "30",
+	}), now)
+	if err != nil {
+		t.Fatalf("update() of the last request error = %v, want its answer kept", err)
+	}
+
+	err = q.allow(now.Add(10 * time.Second))
+	var rateLimitErr *types.RateLimitError
+	if !errors.As(err, &rateLimitErr) || rateLimitErr.RetryAfter != 20*time.Second {
+		t.Fatalf("allow() error = %v, want a RateLimitError of the 20s left", err)
+	}
+
+	if err = q.allow(now.Add(30 * time.Second)); err != nil {
+		t.Fatalf("allow() after the reset error = %v", err)
+	}
+}
+
+func TestQuotaRemainingZeroWithoutResetDoesNotBlock(t *testing.T) {
+	q := newQuota(types.AttributeAge)
+	now := time.Now()
+
+	err := q.update(response(http.StatusOK, map[string]string{rateLimitRemainingHeader: "0"}), now)
+	if err != nil {
+		t.Fatalf("update() error = %v", err)
+	}
+	if err = q.allow(now); err != nil {
+		t.Fatalf("allow() error = %v, want the upstream asked since the wait is unknown", err)
+	}
+}
+
+func TestQuota429(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		headers map[string]string
+		wait    time.Duration
+	}{
+		{
+			name:    "Retry-After",
+			headers: map[string]string{retryAfterHeader: "20", rateLimitResetHeader: "40"},
+			wait:    20 * time.Second,
+		},
+		{
+			name:    "X-Rate-Limit-Reset without Retry-After",
+			headers: map[string]string{rateLimitResetHeader: "40"},
+			wait:    40 * time.Second,
+		},
+		{
+			name: "neither",
+			wait: defaultRateLimitBackoff,
+		},
+		{
+			name:    "unreadable Retry-After",
+			headers: map[string]string{retryAfterHeader: "Wed, 21 Oct 2015 07:28:00 GMT"},
+			wait:    defaultRateLimitBackoff,
+		},
+		{
+			name:    "zero Retry-After",
+			headers: map[string]string{retryAfterHeader: "0"},
+			wait:    defaultRateLimitBackoff,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			q := newQuota(types.AttributeGender)
+			now := time.Now()
+
+			err := q.update(response(http.StatusTooManyRequests, test.headers), now)
+			var rateLimitErr *types.RateLimitError
+			if !errors.As(err, &rateLimitErr) || rateLimitErr.RetryAfter != test.wait {
+				t.Fatalf("update() error = %v, want a RateLimitError of %s", err, test.wait)
+			}
+			if !errors.Is(err, types.ErrRateLimited) {
+				t.Fatalf("update() error = %v, want it to match ErrRateLimited", err)
+			}
+
+			if err = q.allow(now.Add(test.wait - time.Second)); err == nil {
+				t.Fatal("allow() before the wait is over error = nil, want the upstream not asked")
+			}
+			if err = q.allow(now.Add(test.wait)); err != nil {
+				t.Fatalf("allow() after the wait error = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package enrichment
+
+import (
+	"context"
+	"time"
+
+	"people/internal/types"
+)
+
+// DefaultTimeout bounds a single lookup when config.yml does not set one
+const DefaultTimeout = 5 * time.Second
+
+// timeoutProvider puts a per-call deadline on every lookup of the wrapped provider
+type timeoutProvider struct {
+	next        EnrichmentProvider
+	age         time.Duration
+	gender      time.Duration
+	nationality time.Duration
+}
+
+// WithTimeouts wraps the provider with the per-provider timeouts from the config
+func WithTimeouts(next EnrichmentProvider, cfg types.EnrichmentUrlsConfig) EnrichmentProvider {
+	return &timeoutProvider{
+		next:        next,
+		age:         timeoutOrDefault(cfg.AgeTimeout),
+		gender:      timeoutOrDefault(cfg.GenderTimeout),
+		nationality: timeoutOrDefault(cfg.NationalityTimeout),
+	}
+}
+
+func timeoutOrDefault(timeout time.Duration) time.Duration {
+	if timeout <= 0 {
+		return DefaultTimeout
+	}
+	return timeout
+}
+
+func (t *timeoutProvider) Age(ctx context.Context, name, country string) (types.AgeData, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.age)
+	defer cancel()
+
+	return t.next.Age(ctx, name, country)
+}
+
+func (t *timeoutProvider) Gender(ctx context.Context, name, country string) (types.GenderData, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.gender)
+	defer cancel()
+
+	return t.next.Gender(ctx, name, country)
+}
+
+func (t *timeoutProvider) Nationality(ctx context.Context, name string) (types.NationalityData, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.nationality)
+	defer cancel()
+
+	return t.next.Nationality(ctx, name)
+}
+
+func (t *timeoutProvider) AgeBatch(ctx context.Context, names []string, country string) (map[string]types.AgeData, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.age)
+	defer cancel()
+
+	return AgeBatch(ctx, t.next, names, country)
+}
+
+func (t *timeoutProvider) GenderBatch(ctx context.Context, names []string, country string) (map[string]types.GenderData, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.gender)
+	defer cancel()
+
+	return GenderBatch(ctx, t.next, names, country)
+}
+
+func (t *timeoutProvider) NationalityBatch(ctx context.Context, names []string) (map[string]types.NationalityData, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.nationality)
+	defer cancel()
+
+	return NationalityBatch(ctx, t.next, names)
+}
+
+// Unwrap returns the wrapped provider
+func (t *timeoutProvider) Unwrap() EnrichmentProvider {
+	return t.next
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+
+	"people/internal/types"
+)
+
+// GetAuditEvents lists the audit events matching the filter, the newest first
+func (s *Storage) GetAuditEvents(ctx context.Context, filter types.AuditFilter) ([]types.AuditEvent, error) {
+	connection, err := s.pool.Acquire(ctx)
+	if err != nil {
+		s.logger.WithError(err).Errorln("Error whole acquiring connection from the database pool!")
+		return nil, err
+	}
+
+	defer connection.Release()
+
+	rows, err := connection.Query(
+		ctx,
+		GetAuditEventsTemplate,
+		filter.UserID,
+		filter.Actor,
+		filter.Action,
+		filter.Entity,
+		filter.EntityID,
+		filter.RequestID,
+		filter.From,
+		filter.To,
+		filter.Before,
+		filter.Limit,
+	)
+	if err != nil {
+		s.logger.WithError(err).Errorln("Error getting audit events")
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	events := make([]types.AuditEvent, 0, filter.Limit)
+	for rows.Next() {
+		var event types.AuditEvent
+		err = rows.Scan(
+			&event.ID,
+			&event.OccurredAt,
+			&event.Actor,
+			&event.ActorSource,
+			&event.Action,
+			&event.Entity,
+			&event.EntityID,
+			&event.UserIDs,
+			&event.RequestID,
+			&event.Before,
+			&event.After,
+			&event.RedactedAt,
+		)
+		if err != nil {
+			s.logger.WithError(err).Errorln("Error getting audit event")
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		s.logger.WithError(err).Errorln("Error getting audit events")
+		return nil, err
+	}
+
+	return events, nil
+}
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"people/internal/types"
+)
+
+// GetUserAsOf reconstructs the user, its emails and its friends at the given time from the history tables,
+// all of them are read from the same snapshot
+func (s *Storage) GetUserAsOf(ctx context.Context, id uint64, asOf time.Time) (types.UserSnapshot, error) {
+	connection, err := s.pool.Acquire(ctx)
+	if err != nil {
+		s.logger.WithError(err).Errorln("Error whole acquiring connection from the database pool!")
+		return types.UserSnapshot{}, err
+	}
+
+	defer connection.Release()
+
+	tx, err := connection.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		s.logger.WithError(err).Errorln("Error starting transaction")
+		return types.UserSnapshot{}, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	snapshot := types.UserSnapshot{AsOf: asOf}
+	user := &snapshot.UserInfo
+	err = tx.QueryRow(ctx, GetUserAsOfTemplate, id, asOf).Scan(
+		&user.ID,
+		&user.FirstName,
+		&user.LastName,
+		&user.CountryHint,
+		&user.Gender,
+		&user.Age,
+		&user.Nationality,
+		&user.EnrichmentStatus.Age,
+		&user.EnrichmentStatus.Gender,
+		&user.EnrichmentStatus.Nationality,
+		&user.Version,
+		&user.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			s.logger.WithError(err).Errorln("No such row in users history")
+			return types.UserSnapshot{}, fmt.Errorf("user %d at %s: %w", id, asOf.Format(time.RFC3339), types.ErrNotFound)
+		}
+		s.logger.WithError(err).Errorln("Error getting user history")
+		return types.UserSnapshot{}, err
+	}
+
+	user.Emails, err = collectAsOf[string](ctx, tx, GetUserEmailsAsOfTemplate, id, asOf)
+	if err != nil {
+		s.logger.WithError(err).Errorln("Error getting emails history")
+		return types.UserSnapshot{}, err
+	}
+
+	snapshot.FriendIDs, err = collectAsOf[uint64](ctx, tx, GetUserFriendsAsOfTemplate, id, asOf)
+	if err != nil {
+		s.logger.WithError(err).Errorln("Error getting friends history")
+		return types.UserSnapshot{}, err
+	}
+
+	friendCount := uint64(len(snapshot.FriendIDs))
+	user.FriendCount = &friendCount
+
+	return snapshot, nil
+}
+
+// collectAsOf reads the single column of a history query of the user
+func collectAsOf[T any](ctx context.Context, tx pgx.Tx, template string, id uint64, asOf time.Time) ([]T, error) {
+	rows, err := tx.Query(ctx, template, id, asOf)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	values := make([]T, 0)
+	for rows.Next() {
+		var value T
+		err = rows.Scan(&value)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+
+	return values, rows.Err()
+}
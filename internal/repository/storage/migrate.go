@@ -0,0 +1,340 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"people/internal/types"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migration is a pair of files migrations/<version>_<name>.up.sql and .down.sql, the down file is optional
+type migration struct {
+	version  int64
+	name     string
+	up       string
+	down     string
+	checksum string
+}
+
+type appliedMigration struct {
+	version   int64
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// loadMigrations reads the migrations ordered by version
+func loadMigrations(files fs.FS) ([]migration, error) {
+	paths, err := fs.Glob(files, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*migration)
+	for _, file := range paths {
+		base := path.Base(file)
+		stem, direction, ok := strings.Cut(strings.TrimSuffix(base, ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("migration %s: expected <version>_<name>.up.sql or .down.sql", base)
+		}
+		prefix, name, ok := strings.Cut(stem, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s: expected <version>_<name>.up.sql or .down.sql", base)
+		}
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: invalid version %q", base, prefix)
+		}
+
+		data, err := fs.ReadFile(files, file)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &migration{version: version, name: name}
+			byVersion[version] = m
+		}
+		if m.name != name {
+			return nil, fmt.Errorf("migration %d is named both %s and %s", version, m.name, name)
+		}
+
+		if direction == "up" {
+			m.up = string(data)
+			sum := sha256.Sum256(data)
+			m.checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.down = string(data)
+		}
+	}
+
+	migrations := make([]migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.version, m.name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].version < migrations[j].version
+	})
+
+	return migrations, nil
+}
+
+// Migrations brings the schema to the latest version
+func (s *Storage) Migrations(ctx context.Context) error {
+	return s.MigrateUp(ctx, 0)
+}
+
+// MigrateUp applies the pending migrations up to and including target, 0 applies all of them.
+// Every migration runs in its own transaction
+func (s *Storage) MigrateUp(ctx context.Context, target int64) error {
+	return s.withMigrationLock(ctx, func(connection *pgxpool.Conn, migrations []migration, applied map[int64]appliedMigration) error {
+		for _, m := range migrations {
+			if _, ok := applied[m.version]; ok {
+				continue
+			}
+			if target > 0 && m.version > target {
+				break
+			}
+
+			err := s.runMigration(ctx, connection, m.up, func(tx pgx.Tx) error {
+				_, err := tx.Exec(ctx, addAppliedMigrationTemplate, m.version, m.name, m.checksum)
+				return err
+			})
+			if err != nil {
+				s.logger.WithError(err).Errorf("Error applying migration %d_%s", m.version, m.name)
+				return fmt.Errorf("applying migration %d_%s: %w", m.version, m.name, err)
+			}
+			s.logger.Infof("Applied migration %d_%s", m.version, m.name)
+		}
+		return nil
+	})
+}
+
+// MigrateDown rolls back the last steps applied migrations, the newest first
+func (s *Storage) MigrateDown(ctx context.Context, steps int) error {
+	return s.withMigrationLock(ctx, func(connection *pgxpool.Conn, migrations []migration, applied map[int64]appliedMigration) error {
+		for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+			m := migrations[i]
+			if _, ok := applied[m.version]; !ok {
+				continue
+			}
+			if m.down == "" {
+				err := fmt.Errorf("migration %d_%s has no down file", m.version, m.name)
+				s.logger.WithError(err).Errorln("Error rolling back migration")
+				return err
+			}
+
+			err := s.runMigration(ctx, connection, m.down, func(tx pgx.Tx) error {
+				_, err := tx.Exec(ctx, deleteAppliedMigrationTemplate, m.version)
+				return err
+			})
+			if err != nil {
+				s.logger.WithError(err).Errorf("Error rolling back migration %d_%s", m.version, m.name)
+				return fmt.Errorf("rolling back migration %d_%s: %w", m.version, m.name, err)
+			}
+			s.logger.Infof("Rolled back migration %d_%s", m.version, m.name)
+			steps--
+		}
+		return nil
+	})
+}
+
+// MigrationStatus lists the known and the applied migrations ordered by version
+func (s *Storage) MigrationStatus(ctx context.Context) ([]types.MigrationStatus, error) {
+	migrations, err := loadMigrations(migrationFiles)
+	if err != nil {
+		s.logger.WithError(err).Errorln("Error loading migrations")
+		return nil, err
+	}
+
+	connection, err := s.pool.Acquire(ctx)
+	if err != nil {
+		s.logger.WithError(err).Errorln("Error whole acquiring connection from the database pool!")
+		return nil, err
+	}
+
+	defer connection.Release()
+
+	_, err = connection.Exec(ctx, createSchemaMigrationsTableTemplate)
+	if err != nil {
+		s.logger.WithError(err).Errorln("Error create schema_migrations table")
+		return nil, err
+	}
+
+	applied, err := s.appliedMigrations(ctx, connection)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]types.MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		status := types.MigrationStatus{Version: m.version, Name: m.name}
+		if a, ok := applied[m.version]; ok {
+			status.Applied = true
+			status.AppliedAt = &a.appliedAt
+			status.Modified = a.checksum != m.checksum
+			delete(applied, m.version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, a := range applied {
+		statuses = append(statuses, types.MigrationStatus{
+			Version:   a.version,
+			Name:      a.name,
+			Applied:   true,
+			AppliedAt: &a.appliedAt,
+			Missing:   true,
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+
+	return statuses, nil
+}
+
+// withMigrationLock runs fn holding the advisory lock, so that instances starting together migrate one after
+// another, after the applied migrations were checked against the files
+func (s *Storage) withMigrationLock(
+	ctx context.Context,
+	fn func(connection *pgxpool.Conn, migrations []migration, applied map[int64]appliedMigration) error,
+) error {
+	migrations, err := loadMigrations(migrationFiles)
+	if err != nil {
+		s.logger.WithError(err).Errorln("Error loading migrations")
+		return err
+	}
+
+	connection, err := s.pool.Acquire(ctx)
+	if err != nil {
+		s.logger.WithError(err).Errorln("Error whole acquiring connection from the database pool!")
+		return err
+	}
+
+	defer connection.Release()
+
+	_, err = connection.Exec(ctx, lockMigrationsTemplate, int64(migrationsLockKey))
+	if err != nil {
+		s.logger.WithError(err).Errorln("Error taking the migrations lock")
+		return err
+	}
+
+	defer func() {
+		// the lock belongs to the session, it must be released before the connection goes back to the pool
+		_, err := connection.Exec(context.Background(), unlockMigrationsTemplate, int64(migrationsLockKey))
+		if err != nil {
+			s.logger.WithError(err).Errorln("Error releasing the migrations lock")
+			connection.Conn().Close(context.Background())
+		}
+	}()
+
+	_, err = connection.Exec(ctx, createSchemaMigrationsTableTemplate)
+	if err != nil {
+		s.logger.WithError(err).Errorln("Error create schema_migrations table")
+		return err
+	}
+
+	applied, err := s.appliedMigrations(ctx, connection)
+	if err != nil {
+		return err
+	}
+
+	err = verifyMigrations(migrations, applied)
+	if err != nil {
+		s.logger.WithError(err).Errorln("Applied migrations do not match the build")
+		return err
+	}
+
+	return fn(connection, migrations, applied)
+}
+
+// runMigration executes the statements of a migration file and records the change in one transaction
+func (s *Storage) runMigration(ctx context.Context, connection *pgxpool.Conn, statements string, record func(tx pgx.Tx) error) error {
+	tx, err := connection.Begin(ctx)
+	if err != nil {
+		s.logger.WithError(err).Errorln("Error starting transaction")
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, statements)
+	if err != nil {
+		return err
+	}
+
+	err = record(tx)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// verifyMigrations checks that every applied migration is known and unchanged
+func verifyMigrations(migrations []migration, applied map[int64]appliedMigration) error {
+	known := make(map[int64]migration, len(migrations))
+	for _, m := range migrations {
+		known[m.version] = m
+	}
+
+	for version, a := range applied {
+		m, ok := known[version]
+		if !ok {
+			return fmt.Errorf("%w: %d_%s is applied but not part of this build", types.ErrUnknownMigration, version, a.name)
+		}
+		if m.checksum != a.checksum {
+			return fmt.Errorf("%w: %d_%s was changed after it was applied", types.ErrMigrationChecksum, version, m.name)
+		}
+	}
+
+	return nil
+}
+
+func (s *Storage) appliedMigrations(ctx context.Context, connection *pgxpool.Conn) (map[int64]appliedMigration, error) {
+	applied := make(map[int64]appliedMigration)
+
+	rows, err := connection.Query(ctx, getAppliedMigrationsTemplate)
+	if err != nil {
+		s.logger.WithError(err).Errorln("Error getting applied migrations")
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var a appliedMigration
+		err = rows.Scan(&a.version, &a.name, &a.checksum, &a.appliedAt)
+		if err != nil {
+			s.logger.WithError(err).Errorln("Error getting applied migration")
+			return nil, err
+		}
+		applied[a.version] = a
+	}
+
+	err = rows.Err()
+	if err != nil {
+		s.logger.WithError(err).Errorln("Error getting applied migrations")
+		return nil, err
+	}
+
+	return applied, nil
+}
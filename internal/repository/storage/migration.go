@@ -1,33 +1,24 @@
 package storage
 
+// the schema itself lives in the versioned files of migrations/, these statements keep track of them
 const (
-	createUsersTableTemplate = `CREATE TABLE IF NOT EXISTS Users(
-		id serial primary key,
-		first_name text not null,
-		last_name text not null,
-		gender text not null,
-		nationality text not null,
-		age integer not null
-	);`
+	// migrationsLockKey is the pg_advisory_lock key held while migrating, "people" in ASCII
+	migrationsLockKey = 0x70656f706c65
 
-	createEmailsTableTemplate = `CREATE TABLE IF NOT EXISTS Emails(
-    	id serial primary key,
-    	user_id integer not null,
-    	email text not null UNIQUE,
-                                
-        FOREIGN KEY (user_id) REFERENCES Users(id) ON DELETE CASCADE ON UPDATE CASCADE
+	createSchemaMigrationsTableTemplate = `CREATE TABLE IF NOT EXISTS schema_migrations(
+		version bigint primary key,
+		name text not null,
+		checksum text not null,
+		applied_at timestamptz not null default now()
 	);`
 
-	createFriendsTableTemplate = `CREATE TABLE IF NOT EXISTS Friends(
-    	id_first_friend integer not null,
-    	id_second_friend integer not null,
-    	
-    	PRIMARY KEY (id_first_friend, id_second_friend),
-    
-    	FOREIGN KEY (id_first_friend) REFERENCES Users(id) ON DELETE CASCADE ON UPDATE CASCADE,
-    
-    	FOREIGN KEY (id_second_friend) REFERENCES Users(id) ON DELETE CASCADE ON UPDATE CASCADE
-	);`
+	lockMigrationsTemplate = `SELECT pg_advisory_lock($1);`
+
+	unlockMigrationsTemplate = `SELECT pg_advisory_unlock($1);`
+
+	getAppliedMigrationsTemplate = `SELECT version, name, checksum, applied_at FROM schema_migrations ORDER BY version;`
+
+	addAppliedMigrationTemplate = `INSERT INTO schema_migrations(version, name, checksum) VALUES ($1, $2, $3);`
 
-	createFriendsIndexTemplates = `CREATE INDEX IF NOT EXISTS id_second_first_friend ON Friends(id_second_friend, id_first_friend);`
+	deleteAppliedMigrationTemplate = `DELETE FROM schema_migrations WHERE version = $1;`
 )
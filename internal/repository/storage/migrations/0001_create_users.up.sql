@@ -0,0 +1,13 @@
+-- 0001 to 0012 keep IF NOT EXISTS: databases created before the versioned migrations adopt them as they are
+
+CREATE TABLE IF NOT EXISTS Users(
+	id serial primary key,
+	first_name text not null,
+	last_name text not null,
+	gender text,
+	nationality text,
+	age integer,
+	age_status text not null default 'ok',
+	gender_status text not null default 'ok',
+	nationality_status text not null default 'ok'
+);
@@ -0,0 +1,4 @@
+ALTER TABLE Users
+	DROP COLUMN IF EXISTS age_status,
+	DROP COLUMN IF EXISTS gender_status,
+	DROP COLUMN IF EXISTS nationality_status;
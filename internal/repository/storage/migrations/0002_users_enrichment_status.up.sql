@@ -0,0 +1,8 @@
+-- attributes became nullable with the best-effort enrichment policy
+ALTER TABLE Users
+	ALTER COLUMN gender DROP NOT NULL,
+	ALTER COLUMN nationality DROP NOT NULL,
+	ALTER COLUMN age DROP NOT NULL,
+	ADD COLUMN IF NOT EXISTS age_status text not null default 'ok',
+	ADD COLUMN IF NOT EXISTS gender_status text not null default 'ok',
+	ADD COLUMN IF NOT EXISTS nationality_status text not null default 'ok';
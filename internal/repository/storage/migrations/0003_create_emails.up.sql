@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS Emails(
+	id serial primary key,
+	user_id integer not null,
+	email text not null UNIQUE,
+
+	FOREIGN KEY (user_id) REFERENCES Users(id) ON DELETE CASCADE ON UPDATE CASCADE
+);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS Friends(
+	id_first_friend integer not null,
+	id_second_friend integer not null,
+
+	PRIMARY KEY (id_first_friend, id_second_friend),
+
+	FOREIGN KEY (id_first_friend) REFERENCES Users(id) ON DELETE CASCADE ON UPDATE CASCADE,
+
+	FOREIGN KEY (id_second_friend) REFERENCES Users(id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS id_second_first_friend ON Friends(id_second_friend, id_first_friend);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS enrichment_cache(
+	name text not null,
+	attribute text not null,
+	value text not null,
+	probability real,
+	count bigint,
+	fetched_at timestamptz not null default now(),
+
+	PRIMARY KEY (name, attribute)
+);
+
+ALTER TABLE enrichment_cache ADD COLUMN IF NOT EXISTS payload jsonb;
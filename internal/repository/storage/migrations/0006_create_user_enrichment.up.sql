@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS user_enrichment(
+	user_id integer primary key,
+	age_count bigint,
+	gender_probability real,
+	gender_count bigint,
+	nationality_count bigint,
+
+	FOREIGN KEY (user_id) REFERENCES Users(id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS user_nationalities(
+	user_id integer not null,
+	rank smallint not null,
+	country_id text not null,
+	probability real not null,
+
+	PRIMARY KEY (user_id, rank),
+
+	FOREIGN KEY (user_id) REFERENCES Users(id) ON DELETE CASCADE ON UPDATE CASCADE
+);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS enrichment_jobs(
+	id bigserial primary key,
+	user_id integer not null,
+	status text not null default 'queued',
+	attempts integer not null default 0,
+	last_error text,
+	run_at timestamptz not null default now(),
+	created_at timestamptz not null default now(),
+	updated_at timestamptz not null default now(),
+
+	FOREIGN KEY (user_id) REFERENCES Users(id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS enrichment_jobs_status_run_at ON enrichment_jobs(status, run_at);
+CREATE INDEX IF NOT EXISTS enrichment_jobs_user_id ON enrichment_jobs(user_id);
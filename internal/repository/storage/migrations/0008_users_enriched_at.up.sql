@@ -0,0 +1 @@
+ALTER TABLE Users ADD COLUMN IF NOT EXISTS enriched_at timestamptz;
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS enrichment_changes(
+	id bigserial primary key,
+	user_id integer not null,
+	attribute text not null,
+	old_value text,
+	new_value text,
+	changed_at timestamptz not null default now(),
+
+	FOREIGN KEY (user_id) REFERENCES Users(id) ON DELETE CASCADE ON UPDATE CASCADE
+);
@@ -0,0 +1,6 @@
+-- the lookups with a country hint have no place in the old primary key
+DROP INDEX IF EXISTS enrichment_cache_name_attribute_country;
+DELETE FROM enrichment_cache WHERE country <> '';
+ALTER TABLE enrichment_cache DROP COLUMN IF EXISTS country;
+ALTER TABLE enrichment_cache ADD PRIMARY KEY (name, attribute);
+ALTER TABLE Users DROP COLUMN IF EXISTS country_hint;
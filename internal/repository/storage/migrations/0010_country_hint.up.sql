@@ -0,0 +1,7 @@
+ALTER TABLE Users ADD COLUMN IF NOT EXISTS country_hint text;
+
+-- lookups with a country hint are cached apart from the global ones
+ALTER TABLE enrichment_cache ADD COLUMN IF NOT EXISTS country text not null default '';
+ALTER TABLE enrichment_cache DROP CONSTRAINT IF EXISTS enrichment_cache_pkey;
+CREATE UNIQUE INDEX IF NOT EXISTS enrichment_cache_name_attribute_country
+	ON enrichment_cache(name, attribute, country);
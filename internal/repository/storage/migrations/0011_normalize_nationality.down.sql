@@ -0,0 +1 @@
+-- the nationalities stay upper case, the API accepts them in any case
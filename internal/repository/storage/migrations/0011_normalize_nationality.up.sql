@@ -0,0 +1,2 @@
+-- nationalities stored before the normalization may be lower case
+UPDATE Users SET nationality = upper(nationality) WHERE nationality <> upper(nationality);
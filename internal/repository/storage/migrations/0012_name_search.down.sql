@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS users_search_vector;
+DROP INDEX IF EXISTS users_search_name_trgm;
+ALTER TABLE Users
+	DROP COLUMN IF EXISTS search_vector,
+	DROP COLUMN IF EXISTS search_name;
+DROP FUNCTION IF EXISTS people_search_text(text);
@@ -0,0 +1,24 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE EXTENSION IF NOT EXISTS unaccent;
+
+-- people_search_text folds a name for searching: lower case, no diacritics and Cyrillic spelled in Latin,
+-- so that "Ivanov", "IVANOV" and "Иванов" all become "ivanov". unaccent is only stable, pinning its
+-- dictionary makes the function safe to declare immutable and to use in generated columns
+CREATE OR REPLACE FUNCTION people_search_text(value text) RETURNS text
+	LANGUAGE sql IMMUTABLE PARALLEL SAFE AS $$
+	SELECT translate(
+		replace(replace(replace(replace(replace(replace(replace(replace(replace(
+			lower(public.unaccent('public.unaccent'::regdictionary, value)),
+			'щ', 'shch'), 'ш', 'sh'), 'ч', 'ch'), 'ж', 'zh'), 'ю', 'yu'), 'я', 'ya'), 'х', 'kh'), 'ц', 'ts'), 'є', 'ye'),
+		'абвгдеёзийклмнопрстуфыэіїґъь',
+		'abvgdeeziyklmnoprstufyeiig')
+$$;
+
+ALTER TABLE Users
+	ADD COLUMN IF NOT EXISTS search_name text
+		GENERATED ALWAYS AS (people_search_text(first_name || ' ' || last_name)) STORED,
+	ADD COLUMN IF NOT EXISTS search_vector tsvector
+		GENERATED ALWAYS AS (to_tsvector('simple', people_search_text(first_name || ' ' || last_name))) STORED;
+
+CREATE INDEX IF NOT EXISTS users_search_name_trgm ON Users USING gin (search_name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS users_search_vector ON Users USING gin (search_vector);
@@ -0,0 +1,10 @@
+DROP TRIGGER IF EXISTS user_nationalities_touch_users ON user_nationalities;
+DROP TRIGGER IF EXISTS user_enrichment_touch_users ON user_enrichment;
+DROP TRIGGER IF EXISTS friends_touch_users ON Friends;
+DROP TRIGGER IF EXISTS emails_touch_users ON Emails;
+DROP FUNCTION IF EXISTS users_touch();
+DROP TRIGGER IF EXISTS users_next_version ON Users;
+DROP FUNCTION IF EXISTS users_next_version();
+ALTER TABLE Users
+	DROP COLUMN IF EXISTS updated_at,
+	DROP COLUMN IF EXISTS version;
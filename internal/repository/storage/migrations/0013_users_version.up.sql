@@ -0,0 +1,58 @@
+-- version is the ETag of a user, every update of the row increments it. The rows of the user in the other
+-- tables are part of what GET /users/:id returns, changing them touches the user as well
+ALTER TABLE Users
+	ADD COLUMN IF NOT EXISTS version bigint not null default 1,
+	ADD COLUMN IF NOT EXISTS updated_at timestamptz not null default now();
+
+CREATE OR REPLACE FUNCTION users_next_version() RETURNS trigger
+	LANGUAGE plpgsql AS $$
+BEGIN
+	NEW.version := OLD.version + 1;
+	NEW.updated_at := now();
+	RETURN NEW;
+END
+$$;
+
+DROP TRIGGER IF EXISTS users_next_version ON Users;
+CREATE TRIGGER users_next_version BEFORE UPDATE ON Users
+	FOR EACH ROW EXECUTE FUNCTION users_next_version();
+
+-- users_touch updates the users a row of Emails, user_enrichment or user_nationalities belongs to,
+-- Friends belongs to both of its users
+CREATE OR REPLACE FUNCTION users_touch() RETURNS trigger
+	LANGUAGE plpgsql AS $$
+DECLARE
+	ids integer[] := '{}';
+BEGIN
+	IF TG_OP IN ('UPDATE', 'DELETE') THEN
+		IF TG_TABLE_NAME = 'friends' THEN
+			ids := ids || OLD.id_first_friend || OLD.id_second_friend;
+		ELSE
+			ids := ids || OLD.user_id;
+		END IF;
+	END IF;
+	IF TG_OP IN ('INSERT', 'UPDATE') THEN
+		IF TG_TABLE_NAME = 'friends' THEN
+			ids := ids || NEW.id_first_friend || NEW.id_second_friend;
+		ELSE
+			ids := ids || NEW.user_id;
+		END IF;
+	END IF;
+
+	UPDATE Users SET updated_at = now() WHERE id = ANY(ids);
+	RETURN NULL;
+END
+$$;
+
+DROP TRIGGER IF EXISTS emails_touch_users ON Emails;
+CREATE TRIGGER emails_touch_users AFTER INSERT OR UPDATE OR DELETE ON Emails
+	FOR EACH ROW EXECUTE FUNCTION users_touch();
+DROP TRIGGER IF EXISTS friends_touch_users ON Friends;
+CREATE TRIGGER friends_touch_users AFTER INSERT OR UPDATE OR DELETE ON Friends
+	FOR EACH ROW EXECUTE FUNCTION users_touch();
+DROP TRIGGER IF EXISTS user_enrichment_touch_users ON user_enrichment;
+CREATE TRIGGER user_enrichment_touch_users AFTER INSERT OR UPDATE OR DELETE ON user_enrichment
+	FOR EACH ROW EXECUTE FUNCTION users_touch();
+DROP TRIGGER IF EXISTS user_nationalities_touch_users ON user_nationalities;
+CREATE TRIGGER user_nationalities_touch_users AFTER INSERT OR UPDATE OR DELETE ON user_nationalities
+	FOR EACH ROW EXECUTE FUNCTION users_touch();
@@ -0,0 +1,4 @@
+-- deleted users keep their rows, with their emails and friendships, until the retention purges them
+ALTER TABLE Users ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
+
+CREATE INDEX IF NOT EXISTS users_deleted_at ON Users(deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,6 @@
+DROP TRIGGER IF EXISTS friends_audit ON Friends;
+DROP TRIGGER IF EXISTS emails_audit ON Emails;
+DROP TRIGGER IF EXISTS users_audit ON Users;
+DROP FUNCTION IF EXISTS audit_change();
+DROP TABLE IF EXISTS audit_events;
+DROP FUNCTION IF EXISTS audit_events_append_only();
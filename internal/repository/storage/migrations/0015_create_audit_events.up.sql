@@ -0,0 +1,116 @@
+-- audit_events is append-only, the triggers of Users, Emails and Friends write it in the transaction of the
+-- change. The application sets people.actor and people.request_id for its transactions, other writers are
+-- recorded as system
+CREATE TABLE IF NOT EXISTS audit_events(
+	id bigserial primary key,
+	occurred_at timestamptz not null default now(),
+	actor text not null,
+	action text not null,
+	entity text not null,
+	entity_id text not null,
+	user_ids integer[] not null,
+	request_id text,
+	before jsonb,
+	after jsonb
+);
+
+CREATE INDEX IF NOT EXISTS audit_events_user_ids ON audit_events USING gin (user_ids);
+CREATE INDEX IF NOT EXISTS audit_events_occurred_at ON audit_events(occurred_at);
+CREATE INDEX IF NOT EXISTS audit_events_request_id ON audit_events(request_id) WHERE request_id IS NOT NULL;
+
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger
+	LANGUAGE plpgsql AS $$
+BEGIN
+	RAISE EXCEPTION 'audit_events is append-only';
+END
+$$;
+
+DROP TRIGGER IF EXISTS audit_events_append_only ON audit_events;
+CREATE TRIGGER audit_events_append_only BEFORE UPDATE OR DELETE OR TRUNCATE ON audit_events
+	FOR EACH STATEMENT EXECUTE FUNCTION audit_events_append_only();
+
+-- audit_change records a created or deleted row whole and of an updated row only the changed columns,
+-- bookkeeping columns are left out and an update that changes nothing else is not recorded
+CREATE OR REPLACE FUNCTION audit_change() RETURNS trigger
+	LANGUAGE plpgsql AS $$
+DECLARE
+	ignored text[] := ARRAY['version', 'updated_at', 'search_name', 'search_vector'];
+	row_before jsonb;
+	row_after jsonb;
+	changed_before jsonb;
+	changed_after jsonb;
+	audit_row jsonb;
+	audit_entity text;
+	audit_entity_id text;
+	audit_user_ids integer[];
+	audit_action text;
+BEGIN
+	IF TG_OP <> 'INSERT' THEN
+		row_before := to_jsonb(OLD) - ignored;
+	END IF;
+	IF TG_OP <> 'DELETE' THEN
+		row_after := to_jsonb(NEW) - ignored;
+	END IF;
+	audit_row := COALESCE(row_after, row_before);
+
+	IF TG_OP = 'UPDATE' THEN
+		SELECT jsonb_object_agg(k, row_before -> k), jsonb_object_agg(k, row_after -> k)
+		INTO changed_before, changed_after
+		FROM jsonb_object_keys(row_after) AS k
+		WHERE row_before -> k IS DISTINCT FROM row_after -> k;
+
+		IF changed_after IS NULL THEN
+			RETURN NULL;
+		END IF;
+	ELSE
+		changed_before := row_before;
+		changed_after := row_after;
+	END IF;
+
+	IF TG_TABLE_NAME = 'users' THEN
+		audit_entity := 'user';
+		audit_entity_id := audit_row ->> 'id';
+		audit_user_ids := ARRAY[(audit_row ->> 'id')::integer];
+	ELSIF TG_TABLE_NAME = 'emails' THEN
+		audit_entity := 'email';
+		audit_entity_id := audit_row ->> 'id';
+		audit_user_ids := ARRAY[(audit_row ->> 'user_id')::integer];
+	ELSE
+		audit_entity := 'friendship';
+		audit_entity_id := (audit_row ->> 'id_first_friend') || '-' || (audit_row ->> 'id_second_friend');
+		audit_user_ids := ARRAY[(audit_row ->> 'id_first_friend')::integer, (audit_row ->> 'id_second_friend')::integer];
+	END IF;
+
+	audit_action := audit_entity || '.' || CASE
+		WHEN TG_OP = 'INSERT' THEN 'create'
+		WHEN TG_OP = 'DELETE' AND audit_entity = 'user' THEN 'purge'
+		WHEN TG_OP = 'DELETE' THEN 'delete'
+		WHEN row_before ->> 'deleted_at' IS NULL AND row_after ->> 'deleted_at' IS NOT NULL THEN 'delete'
+		WHEN row_before ->> 'deleted_at' IS NOT NULL AND row_after ->> 'deleted_at' IS NULL THEN 'restore'
+		ELSE 'update'
+	END;
+
+	INSERT INTO audit_events(actor, action, entity, entity_id, user_ids, request_id, before, after)
+	VALUES (
+		COALESCE(NULLIF(current_setting('people.actor', true), ''), 'system'),
+		audit_action,
+		audit_entity,
+		audit_entity_id,
+		audit_user_ids,
+		NULLIF(current_setting('people.request_id', true), ''),
+		changed_before,
+		changed_after
+	);
+	RETURN NULL;
+END
+$$;
+
+DROP TRIGGER IF EXISTS users_audit ON Users;
+CREATE TRIGGER users_audit AFTER INSERT OR UPDATE OR DELETE ON Users
+	FOR EACH ROW EXECUTE FUNCTION audit_change();
+DROP TRIGGER IF EXISTS emails_audit ON Emails;
+CREATE TRIGGER emails_audit AFTER INSERT OR UPDATE OR DELETE ON Emails
+	FOR EACH ROW EXECUTE FUNCTION audit_change();
+DROP TRIGGER IF EXISTS friends_audit ON Friends;
+CREATE TRIGGER friends_audit AFTER INSERT OR UPDATE OR DELETE ON Friends
+	FOR EACH ROW EXECUTE FUNCTION audit_change();
@@ -0,0 +1,11 @@
+DROP TRIGGER IF EXISTS friends_history ON Friends;
+DROP FUNCTION IF EXISTS friends_history();
+DROP TRIGGER IF EXISTS emails_history ON Emails;
+DROP FUNCTION IF EXISTS emails_history();
+DROP TRIGGER IF EXISTS users_history ON Users;
+DROP FUNCTION IF EXISTS users_history();
+DROP TABLE IF EXISTS friends_history;
+DROP TABLE IF EXISTS emails_history;
+ALTER TABLE Friends DROP COLUMN IF EXISTS created_at;
+ALTER TABLE Emails DROP COLUMN IF EXISTS created_at;
+DROP TABLE IF EXISTS users_history;
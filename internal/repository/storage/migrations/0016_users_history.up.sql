@@ -0,0 +1,81 @@
+-- users_history keeps every replaced version of a Users row, valid for [valid_from, valid_to), the current
+-- version is valid from its updated_at. The row is stored as jsonb so that the history survives new columns.
+-- Emails and Friends keep their deleted rows the same way, valid for [created_at, deleted_at)
+CREATE TABLE IF NOT EXISTS users_history(
+	user_id integer not null,
+	version bigint not null,
+	valid_from timestamptz not null,
+	valid_to timestamptz not null,
+	data jsonb not null
+);
+
+CREATE INDEX IF NOT EXISTS users_history_user_id ON users_history(user_id, valid_to);
+
+ALTER TABLE Emails ADD COLUMN IF NOT EXISTS created_at timestamptz not null default now();
+ALTER TABLE Friends ADD COLUMN IF NOT EXISTS created_at timestamptz not null default now();
+
+CREATE TABLE IF NOT EXISTS emails_history(
+	id integer not null,
+	user_id integer not null,
+	email text not null,
+	created_at timestamptz not null,
+	deleted_at timestamptz not null
+);
+
+CREATE INDEX IF NOT EXISTS emails_history_user_id ON emails_history(user_id, deleted_at);
+
+CREATE TABLE IF NOT EXISTS friends_history(
+	id_first_friend integer not null,
+	id_second_friend integer not null,
+	created_at timestamptz not null,
+	deleted_at timestamptz not null
+);
+
+CREATE INDEX IF NOT EXISTS friends_history_first_friend ON friends_history(id_first_friend, deleted_at);
+CREATE INDEX IF NOT EXISTS friends_history_second_friend ON friends_history(id_second_friend, deleted_at);
+
+-- versions replaced within the transaction that created them were never visible, they are not kept
+CREATE OR REPLACE FUNCTION users_history() RETURNS trigger
+	LANGUAGE plpgsql AS $$
+BEGIN
+	IF OLD.updated_at < now() THEN
+		INSERT INTO users_history(user_id, version, valid_from, valid_to, data)
+		VALUES (OLD.id, OLD.version, OLD.updated_at, now(), to_jsonb(OLD) - ARRAY['search_name', 'search_vector']);
+	END IF;
+	RETURN NULL;
+END
+$$;
+
+DROP TRIGGER IF EXISTS users_history ON Users;
+CREATE TRIGGER users_history AFTER UPDATE OR DELETE ON Users
+	FOR EACH ROW EXECUTE FUNCTION users_history();
+
+CREATE OR REPLACE FUNCTION emails_history() RETURNS trigger
+	LANGUAGE plpgsql AS $$
+BEGIN
+	IF OLD.created_at < now() THEN
+		INSERT INTO emails_history(id, user_id, email, created_at, deleted_at)
+		VALUES (OLD.id, OLD.user_id, OLD.email, OLD.created_at, now());
+	END IF;
+	RETURN NULL;
+END
+$$;
+
+DROP TRIGGER IF EXISTS emails_history ON Emails;
+CREATE TRIGGER emails_history AFTER DELETE ON Emails
+	FOR EACH ROW EXECUTE FUNCTION emails_history();
+
+CREATE OR REPLACE FUNCTION friends_history() RETURNS trigger
+	LANGUAGE plpgsql AS $$
+BEGIN
+	IF OLD.created_at < now() THEN
+		INSERT INTO friends_history(id_first_friend, id_second_friend, created_at, deleted_at)
+		VALUES (OLD.id_first_friend, OLD.id_second_friend, OLD.created_at, now());
+	END IF;
+	RETURN NULL;
+END
+$$;
+
+DROP TRIGGER IF EXISTS friends_history ON Friends;
+CREATE TRIGGER friends_history AFTER DELETE ON Friends
+	FOR EACH ROW EXECUTE FUNCTION friends_history();
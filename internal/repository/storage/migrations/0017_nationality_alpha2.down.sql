@@ -0,0 +1 @@
+-- the original spelling of the mapped nationalities is not kept, they stay alpha-2
@@ -0,0 +1,274 @@
+-- 0011 only upper-cased the nationalities, values stored before the API normalized them may still be
+-- alpha-3 codes or English country names. They are mapped to ISO 3166-1 alpha-2 like types.NormalizeCountry
+-- does, values that can not be mapped are dropped and the attribute is left to a refresh
+CREATE TEMPORARY TABLE country_codes(
+	code text PRIMARY KEY,
+	alpha2 text NOT NULL
+) ON COMMIT DROP;
+
+INSERT INTO country_codes(code, alpha2) VALUES
+	('AD', 'AD'), ('AND', 'AD'), ('ANDORRA', 'AD'),
+	('AE', 'AE'), ('ARE', 'AE'), ('UNITED ARAB EMIRATES', 'AE'),
+	('AF', 'AF'), ('AFG', 'AF'), ('AFGHANISTAN', 'AF'),
+	('AG', 'AG'), ('ANTIGUA AND BARBUDA', 'AG'), ('ATG', 'AG'),
+	('AI', 'AI'), ('AIA', 'AI'), ('ANGUILLA', 'AI'),
+	('AL', 'AL'), ('ALB', 'AL'), ('ALBANIA', 'AL'),
+	('AM', 'AM'), ('ARM', 'AM'), ('ARMENIA', 'AM'),
+	('AN', 'AN'), ('ANT', 'AN'), ('NETHERLANDS ANTILLES', 'AN'),
+	('AGO', 'AO'), ('ANGOLA', 'AO'), ('AO', 'AO'),
+	('ANTARCTICA', 'AQ'), ('AQ', 'AQ'), ('ATA', 'AQ'),
+	('AR', 'AR'), ('ARG', 'AR'), ('ARGENTINA', 'AR'),
+	('AMERICAN SAMOA', 'AS'), ('AS', 'AS'), ('ASM', 'AS'),
+	('AT', 'AT'), ('AUSTRIA', 'AT'), ('AUT', 'AT'),
+	('AU', 'AU'), ('AUS', 'AU'), ('AUSTRALIA', 'AU'),
+	('ABW', 'AW'), ('ARUBA', 'AW'), ('AW', 'AW'),
+	('ALA', 'AX'), ('ALAND ISLANDS', 'AX'), ('AX', 'AX'),
+	('AZ', 'AZ'), ('AZE', 'AZ'), ('AZERBAIJAN', 'AZ'),
+	('BA', 'BA'), ('BIH', 'BA'), ('BOSNIA AND HERZEGOVINA', 'BA'),
+	('BARBADOS', 'BB'), ('BB', 'BB'), ('BRB', 'BB'),
+	('BANGLADESH', 'BD'), ('BD', 'BD'), ('BGD', 'BD'),
+	('BE', 'BE'), ('BEL', 'BE'), ('BELGIUM', 'BE'),
+	('BF', 'BF'), ('BFA', 'BF'), ('BURKINA FASO', 'BF'),
+	('BG', 'BG'), ('BGR', 'BG'), ('BULGARIA', 'BG'),
+	('BAHRAIN', 'BH'), ('BH', 'BH'), ('BHR', 'BH'),
+	('BDI', 'BI'), ('BI', 'BI'), ('BURUNDI', 'BI'),
+	('BEN', 'BJ'), ('BENIN', 'BJ'), ('BJ', 'BJ'),
+	('BL', 'BL'), ('BLM', 'BL'), ('SAINT BARTHELEMY', 'BL'),
+	('BERMUDA', 'BM'), ('BM', 'BM'), ('BMU', 'BM'),
+	('BN', 'BN'), ('BRN', 'BN'), ('BRUNEI DARUSSALAM', 'BN'),
+	('BO', 'BO'), ('BOL', 'BO'), ('BOLIVIA', 'BO'),
+	('BES', 'BQ'), ('BONAIRE, SINT EUSTATIUS AND SABA', 'BQ'), ('BQ', 'BQ'),
+	('BR', 'BR'), ('BRA', 'BR'), ('BRAZIL', 'BR'),
+	('BAHAMAS', 'BS'), ('BHS', 'BS'), ('BS', 'BS'),
+	('BHUTAN', 'BT'), ('BT', 'BT'), ('BTN', 'BT'),
+	('BOUVET ISLAND', 'BV'), ('BV', 'BV'), ('BVT', 'BV'),
+	('BOTSWANA', 'BW'), ('BW', 'BW'), ('BWA', 'BW'),
+	('BELARUS', 'BY'), ('BLR', 'BY'), ('BY', 'BY'),
+	('BELIZE', 'BZ'), ('BLZ', 'BZ'), ('BZ', 'BZ'),
+	('CA', 'CA'), ('CAN', 'CA'), ('CANADA', 'CA'),
+	('CC', 'CC'), ('CCK', 'CC'), ('COCOS (KEELING) ISLANDS', 'CC'),
+	('CD', 'CD'), ('COD', 'CD'), ('DEMOCRATIC REPUBLIC OF THE CONGO', 'CD'),
+	('CAF', 'CF'), ('CENTRAL AFRICAN REPUBLIC', 'CF'), ('CF', 'CF'),
+	('CG', 'CG'), ('COG', 'CG'), ('CONGO', 'CG'),
+	('CH', 'CH'), ('CHE', 'CH'), ('SWITZERLAND', 'CH'),
+	('CI', 'CI'), ('CIV', 'CI'), ('COTE D''IVOIRE', 'CI'),
+	('CK', 'CK'), ('COK', 'CK'), ('COOK ISLANDS', 'CK'),
+	('CHILE', 'CL'), ('CHL', 'CL'), ('CL', 'CL'),
+	('CAMEROON', 'CM'), ('CM', 'CM'), ('CMR', 'CM'),
+	('CHINA', 'CN'), ('CHN', 'CN'), ('CN', 'CN'),
+	('CO', 'CO'), ('COL', 'CO'), ('COLOMBIA', 'CO'),
+	('COSTA RICA', 'CR'), ('CR', 'CR'), ('CRI', 'CR'),
+	('CU', 'CU'), ('CUB', 'CU'), ('CUBA', 'CU'),
+	('CAPE VERDE', 'CV'), ('CPV', 'CV'), ('CV', 'CV'),
+	('CURACAO', 'CW'), ('CUW', 'CW'), ('CW', 'CW'),
+	('CHRISTMAS ISLAND', 'CX'), ('CX', 'CX'), ('CXR', 'CX'),
+	('CY', 'CY'), ('CYP', 'CY'), ('CYPRUS', 'CY'),
+	('CZ', 'CZ'), ('CZE', 'CZ'), ('CZECHIA', 'CZ'),
+	('DE', 'DE'), ('DEU', 'DE'), ('GERMANY', 'DE'),
+	('DJ', 'DJ'), ('DJI', 'DJ'), ('DJIBOUTI', 'DJ'),
+	('DENMARK', 'DK'), ('DK', 'DK'), ('DNK', 'DK'),
+	('DM', 'DM'), ('DMA', 'DM'), ('DOMINICA', 'DM'),
+	('DO', 'DO'), ('DOM', 'DO'), ('DOMINICAN REPUBLIC', 'DO'),
+	('ALGERIA', 'DZ'), ('DZ', 'DZ'), ('DZA', 'DZ'),
+	('EC', 'EC'), ('ECU', 'EC'), ('ECUADOR', 'EC'),
+	('EE', 'EE'), ('EST', 'EE'), ('ESTONIA', 'EE'),
+	('EG', 'EG'), ('EGY', 'EG'), ('EGYPT', 'EG'),
+	('EH', 'EH'), ('ESH', 'EH'), ('WESTERN SAHARA', 'EH'),
+	('ER', 'ER'), ('ERI', 'ER'), ('ERITREA', 'ER'),
+	('ES', 'ES'), ('ESP', 'ES'), ('SPAIN', 'ES'),
+	('ET', 'ET'), ('ETH', 'ET'), ('ETHIOPIA', 'ET'),
+	('FI', 'FI'), ('FIN', 'FI'), ('FINLAND', 'FI'),
+	('FIJI', 'FJ'), ('FJ', 'FJ'), ('FJI', 'FJ'),
+	('FALKLAND ISLANDS (MALVINAS)', 'FK'), ('FK', 'FK'), ('FLK', 'FK'),
+	('FM', 'FM'), ('FSM', 'FM'), ('MICRONESIA (FEDERATED STATES OF)', 'FM'),
+	('FAROE ISLANDS', 'FO'), ('FO', 'FO'), ('FRO', 'FO'),
+	('FR', 'FR'), ('FRA', 'FR'), ('FRANCE', 'FR'),
+	('GA', 'GA'), ('GAB', 'GA'), ('GABON', 'GA'),
+	('GB', 'GB'), ('GBR', 'GB'), ('UNITED KINGDOM', 'GB'),
+	('GD', 'GD'), ('GRD', 'GD'), ('GRENADA', 'GD'),
+	('GE', 'GE'), ('GEO', 'GE'), ('GEORGIA', 'GE'),
+	('FRENCH GUIANA', 'GF'), ('GF', 'GF'), ('GUF', 'GF'),
+	('GG', 'GG'), ('GGY', 'GG'), ('GUERNSEY', 'GG'),
+	('GH', 'GH'), ('GHA', 'GH'), ('GHANA', 'GH'),
+	('GI', 'GI'), ('GIB', 'GI'), ('GIBRALTAR', 'GI'),
+	('GL', 'GL'), ('GREENLAND', 'GL'), ('GRL', 'GL'),
+	('GAMBIA', 'GM'), ('GM', 'GM'), ('GMB', 'GM'),
+	('GIN', 'GN'), ('GN', 'GN'), ('GUINEA', 'GN'),
+	('GLP', 'GP'), ('GP', 'GP'), ('GUADELOUPE', 'GP'),
+	('EQUATORIAL GUINEA', 'GQ'), ('GNQ', 'GQ'), ('GQ', 'GQ'),
+	('GR', 'GR'), ('GRC', 'GR'), ('GREECE', 'GR'),
+	('GS', 'GS'), ('SGS', 'GS'), ('SOUTH GEORGIA AND THE SOUTH SANDWICH ISLANDS', 'GS'),
+	('GT', 'GT'), ('GTM', 'GT'), ('GUATEMALA', 'GT'),
+	('GU', 'GU'), ('GUAM', 'GU'), ('GUM', 'GU'),
+	('GNB', 'GW'), ('GUINEA-BISSAU', 'GW'), ('GW', 'GW'),
+	('GUY', 'GY'), ('GUYANA', 'GY'), ('GY', 'GY'),
+	('HK', 'HK'), ('HKG', 'HK'), ('HONG KONG (SPECIAL ADMINISTRATIVE REGION OF CHINA)', 'HK'),
+	('HEARD ISLAND AND MCDONALD ISLANDS', 'HM'), ('HM', 'HM'), ('HMD', 'HM'),
+	('HN', 'HN'), ('HND', 'HN'), ('HONDURAS', 'HN'),
+	('CROATIA', 'HR'), ('HR', 'HR'), ('HRV', 'HR'),
+	('HAITI', 'HT'), ('HT', 'HT'), ('HTI', 'HT'),
+	('HU', 'HU'), ('HUN', 'HU'), ('HUNGARY', 'HU'),
+	('ID', 'ID'), ('IDN', 'ID'), ('INDONESIA', 'ID'),
+	('IE', 'IE'), ('IRELAND', 'IE'), ('IRL', 'IE'),
+	('IL', 'IL'), ('ISR', 'IL'), ('ISRAEL', 'IL'),
+	('IM', 'IM'), ('IMN', 'IM'), ('ISLE OF MAN', 'IM'),
+	('IN', 'IN'), ('IND', 'IN'), ('INDIA', 'IN'),
+	('BRITISH INDIAN OCEAN TERRITORY', 'IO'), ('IO', 'IO'), ('IOT', 'IO'),
+	('IQ', 'IQ'), ('IRAQ', 'IQ'), ('IRQ', 'IQ'),
+	('IR', 'IR'), ('IRAN (ISLAMIC REPUBLIC OF)', 'IR'), ('IRN', 'IR'),
+	('ICELAND', 'IS'), ('IS', 'IS'), ('ISL', 'IS'),
+	('IT', 'IT'), ('ITA', 'IT'), ('ITALY', 'IT'),
+	('JE', 'JE'), ('JERSEY', 'JE'), ('JEY', 'JE'),
+	('JAM', 'JM'), ('JAMAICA', 'JM'), ('JM', 'JM'),
+	('JO', 'JO'), ('JOR', 'JO'), ('JORDAN', 'JO'),
+	('JAPAN', 'JP'), ('JP', 'JP'), ('JPN', 'JP'),
+	('KE', 'KE'), ('KEN', 'KE'), ('KENYA', 'KE'),
+	('KG', 'KG'), ('KGZ', 'KG'), ('KYRGYZSTAN', 'KG'),
+	('CAMBODIA', 'KH'), ('KH', 'KH'), ('KHM', 'KH'),
+	('KI', 'KI'), ('KIR', 'KI'), ('KIRIBATI', 'KI'),
+	('COM', 'KM'), ('COMOROS', 'KM'), ('KM', 'KM'),
+	('KN', 'KN'), ('KNA', 'KN'), ('SAINT KITTS AND NEVIS', 'KN'),
+	('DEMOCRATIC PEOPLE''S REPUBLIC OF KOREA', 'KP'), ('KP', 'KP'), ('PRK', 'KP'),
+	('KOR', 'KR'), ('KR', 'KR'), ('REPUBLIC OF KOREA', 'KR'),
+	('KUWAIT', 'KW'), ('KW', 'KW'), ('KWT', 'KW'),
+	('CAYMAN ISLANDS', 'KY'), ('CYM', 'KY'), ('KY', 'KY'),
+	('KAZ', 'KZ'), ('KAZAKHSTAN', 'KZ'), ('KZ', 'KZ'),
+	('LA', 'LA'), ('LAO', 'LA'), ('LAO PEOPLE''S DEMOCRATIC REPUBLIC', 'LA'),
+	('LB', 'LB'), ('LBN', 'LB'), ('LEBANON', 'LB'),
+	('LC', 'LC'), ('LCA', 'LC'), ('SAINT LUCIA', 'LC'),
+	('LI', 'LI'), ('LIE', 'LI'), ('LIECHTENSTEIN', 'LI'),
+	('LK', 'LK'), ('LKA', 'LK'), ('SRI LANKA', 'LK'),
+	('LBR', 'LR'), ('LIBERIA', 'LR'), ('LR', 'LR'),
+	('LESOTHO', 'LS'), ('LS', 'LS'), ('LSO', 'LS'),
+	('LITHUANIA', 'LT'), ('LT', 'LT'), ('LTU', 'LT'),
+	('LU', 'LU'), ('LUX', 'LU'), ('LUXEMBOURG', 'LU'),
+	('LATVIA', 'LV'), ('LV', 'LV'), ('LVA', 'LV'),
+	('LBY', 'LY'), ('LIBYAN ARAB JAMAHIRIYA', 'LY'), ('LY', 'LY'),
+	('MA', 'MA'), ('MAR', 'MA'), ('MOROCCO', 'MA'),
+	('MC', 'MC'), ('MCO', 'MC'), ('MONACO', 'MC'),
+	('MD', 'MD'), ('MDA', 'MD'), ('MOLDOVA (REPUBLIC OF)', 'MD'),
+	('ME', 'ME'), ('MNE', 'ME'), ('MONTENEGRO', 'ME'),
+	('MAF', 'MF'), ('MF', 'MF'), ('SAINT MARTIN FRENCH', 'MF'),
+	('MADAGASCAR', 'MG'), ('MDG', 'MG'), ('MG', 'MG'),
+	('MARSHALL ISLANDS', 'MH'), ('MH', 'MH'), ('MHL', 'MH'),
+	('MK', 'MK'), ('MKD', 'MK'), ('NORTH MACEDONIA (REPUBLIC OF NORTH MACEDONIA)', 'MK'),
+	('MALI', 'ML'), ('ML', 'ML'), ('MLI', 'ML'),
+	('MM', 'MM'), ('MMR', 'MM'), ('MYANMAR', 'MM'),
+	('MN', 'MN'), ('MNG', 'MN'), ('MONGOLIA', 'MN'),
+	('MAC', 'MO'), ('MACAU (SPECIAL ADMINISTRATIVE REGION OF CHINA)', 'MO'), ('MO', 'MO'),
+	('MNP', 'MP'), ('MP', 'MP'), ('NORTHERN MARIANA ISLANDS', 'MP'),
+	('MARTINIQUE', 'MQ'), ('MQ', 'MQ'), ('MTQ', 'MQ'),
+	('MAURITANIA', 'MR'), ('MR', 'MR'), ('MRT', 'MR'),
+	('MONTSERRAT', 'MS'), ('MS', 'MS'), ('MSR', 'MS'),
+	('MALTA', 'MT'), ('MLT', 'MT'), ('MT', 'MT'),
+	('MAURITIUS', 'MU'), ('MU', 'MU'), ('MUS', 'MU'),
+	('MALDIVES', 'MV'), ('MDV', 'MV'), ('MV', 'MV'),
+	('MALAWI', 'MW'), ('MW', 'MW'), ('MWI', 'MW'),
+	('MEX', 'MX'), ('MEXICO', 'MX'), ('MX', 'MX'),
+	('MALAYSIA', 'MY'), ('MY', 'MY'), ('MYS', 'MY'),
+	('MOZ', 'MZ'), ('MOZAMBIQUE', 'MZ'), ('MZ', 'MZ'),
+	('NA', 'NA'), ('NAM', 'NA'), ('NAMIBIA', 'NA'),
+	('NC', 'NC'), ('NCL', 'NC'), ('NEW CALEDONIA', 'NC'),
+	('NE', 'NE'), ('NER', 'NE'), ('NIGER', 'NE'),
+	('NF', 'NF'), ('NFK', 'NF'), ('NORFOLK ISLAND', 'NF'),
+	('NG', 'NG'), ('NGA', 'NG'), ('NIGERIA', 'NG'),
+	('NI', 'NI'), ('NIC', 'NI'), ('NICARAGUA', 'NI'),
+	('NETHERLANDS', 'NL'), ('NL', 'NL'), ('NLD', 'NL'),
+	('NO', 'NO'), ('NOR', 'NO'), ('NORWAY', 'NO'),
+	('NEPAL', 'NP'), ('NP', 'NP'), ('NPL', 'NP'),
+	('NAURU', 'NR'), ('NR', 'NR'), ('NRU', 'NR'),
+	('NIU', 'NU'), ('NIUE', 'NU'), ('NU', 'NU'),
+	('NEW ZEALAND', 'NZ'), ('NZ', 'NZ'), ('NZL', 'NZ'),
+	('OM', 'OM'), ('OMAN', 'OM'), ('OMN', 'OM'),
+	('PA', 'PA'), ('PAN', 'PA'), ('PANAMA', 'PA'),
+	('PE', 'PE'), ('PER', 'PE'), ('PERU', 'PE'),
+	('FRENCH POLYNESIA', 'PF'), ('PF', 'PF'), ('PYF', 'PF'),
+	('PAPUA NEW GUINEA', 'PG'), ('PG', 'PG'), ('PNG', 'PG'),
+	('PH', 'PH'), ('PHILIPPINES', 'PH'), ('PHL', 'PH'),
+	('PAK', 'PK'), ('PAKISTAN', 'PK'), ('PK', 'PK'),
+	('PL', 'PL'), ('POL', 'PL'), ('POLAND', 'PL'),
+	('PM', 'PM'), ('SAINT PIERRE AND MIQUELON', 'PM'), ('SPM', 'PM'),
+	('PCN', 'PN'), ('PITCAIRN', 'PN'), ('PN', 'PN'),
+	('PR', 'PR'), ('PRI', 'PR'), ('PUERTO RICO', 'PR'),
+	('PALESTINIAN TERRITORY (OCCUPIED)', 'PS'), ('PS', 'PS'), ('PSE', 'PS'),
+	('PORTUGAL', 'PT'), ('PRT', 'PT'), ('PT', 'PT'),
+	('PALAU', 'PW'), ('PLW', 'PW'), ('PW', 'PW'),
+	('PARAGUAY', 'PY'), ('PRY', 'PY'), ('PY', 'PY'),
+	('QA', 'QA'), ('QAT', 'QA'), ('QATAR', 'QA'),
+	('RE', 'RE'), ('REU', 'RE'), ('REUNION', 'RE'),
+	('RO', 'RO'), ('ROMANIA', 'RO'), ('ROU', 'RO'),
+	('RS', 'RS'), ('SERBIA', 'RS'), ('SRB', 'RS'),
+	('RU', 'RU'), ('RUS', 'RU'), ('RUSSIAN FEDERATION', 'RU'),
+	('RW', 'RW'), ('RWA', 'RW'), ('RWANDA', 'RW'),
+	('SA', 'SA'), ('SAU', 'SA'), ('SAUDI ARABIA', 'SA'),
+	('SB', 'SB'), ('SLB', 'SB'), ('SOLOMON ISLANDS', 'SB'),
+	('SC', 'SC'), ('SEYCHELLES', 'SC'), ('SYC', 'SC'),
+	('SD', 'SD'), ('SDN', 'SD'), ('SUDAN', 'SD'),
+	('SE', 'SE'), ('SWE', 'SE'), ('SWEDEN', 'SE'),
+	('SG', 'SG'), ('SGP', 'SG'), ('SINGAPORE', 'SG'),
+	('SAINT HELENA', 'SH'), ('SH', 'SH'), ('SHN', 'SH'),
+	('SI', 'SI'), ('SLOVENIA', 'SI'), ('SVN', 'SI'),
+	('SJ', 'SJ'), ('SJM', 'SJ'), ('SVALBARD AND JAN MAYEN ISLANDS', 'SJ'),
+	('SK', 'SK'), ('SLOVAKIA', 'SK'), ('SVK', 'SK'),
+	('SIERRA LEONE', 'SL'), ('SL', 'SL'), ('SLE', 'SL'),
+	('SAN MARINO', 'SM'), ('SM', 'SM'), ('SMR', 'SM'),
+	('SEN', 'SN'), ('SENEGAL', 'SN'), ('SN', 'SN'),
+	('SO', 'SO'), ('SOM', 'SO'), ('SOMALIA', 'SO'),
+	('SR', 'SR'), ('SUR', 'SR'), ('SURINAME', 'SR'),
+	('SOUTH SUDAN', 'SS'), ('SS', 'SS'), ('SSD', 'SS'),
+	('SAO TOME AND PRINCIPE', 'ST'), ('ST', 'ST'), ('STP', 'ST'),
+	('EL SALVADOR', 'SV'), ('SLV', 'SV'), ('SV', 'SV'),
+	('SINT MAARTEN DUTCH', 'SX'), ('SX', 'SX'), ('SXM', 'SX'),
+	('SY', 'SY'), ('SYR', 'SY'), ('SYRIAN ARAB REPUBLIC', 'SY'),
+	('SWAZILAND', 'SZ'), ('SWZ', 'SZ'), ('SZ', 'SZ'),
+	('TC', 'TC'), ('TCA', 'TC'), ('TURKS AND CAICOS ISLANDS', 'TC'),
+	('CHAD', 'TD'), ('TCD', 'TD'), ('TD', 'TD'),
+	('ATF', 'TF'), ('FRENCH SOUTHERN TERRITORIES', 'TF'), ('TF', 'TF'),
+	('TG', 'TG'), ('TGO', 'TG'), ('TOGO', 'TG'),
+	('TH', 'TH'), ('THA', 'TH'), ('THAILAND', 'TH'),
+	('TAJIKISTAN', 'TJ'), ('TJ', 'TJ'), ('TJK', 'TJ'),
+	('TK', 'TK'), ('TKL', 'TK'), ('TOKELAU', 'TK'),
+	('TIMOR-LESTE (EAST TIMOR)', 'TL'), ('TL', 'TL'), ('TLS', 'TL'),
+	('TKM', 'TM'), ('TM', 'TM'), ('TURKMENISTAN', 'TM'),
+	('TN', 'TN'), ('TUN', 'TN'), ('TUNISIA', 'TN'),
+	('TO', 'TO'), ('TON', 'TO'), ('TONGA', 'TO'),
+	('TR', 'TR'), ('TUR', 'TR'), ('TURKEY', 'TR'),
+	('TRINIDAD AND TOBAGO', 'TT'), ('TT', 'TT'), ('TTO', 'TT'),
+	('TUV', 'TV'), ('TUVALU', 'TV'), ('TV', 'TV'),
+	('TAIWAN (PROVINCE OF CHINA)', 'TW'), ('TW', 'TW'), ('TWN', 'TW'),
+	('TANZANIA (UNITED REPUBLIC OF)', 'TZ'), ('TZ', 'TZ'), ('TZA', 'TZ'),
+	('UA', 'UA'), ('UKR', 'UA'), ('UKRAINE', 'UA'),
+	('UG', 'UG'), ('UGA', 'UG'), ('UGANDA', 'UG'),
+	('UM', 'UM'), ('UMI', 'UM'), ('UNITED STATES MINOR OUTLYING ISLANDS', 'UM'),
+	('UNITED STATES', 'US'), ('US', 'US'), ('USA', 'US'),
+	('URUGUAY', 'UY'), ('URY', 'UY'), ('UY', 'UY'),
+	('UZ', 'UZ'), ('UZB', 'UZ'), ('UZBEKISTAN', 'UZ'),
+	('HOLY SEE (VATICAN CITY STATE)', 'VA'), ('VA', 'VA'), ('VAT', 'VA'),
+	('SAINT VINCENT AND THE GRENADINES', 'VC'), ('VC', 'VC'), ('VCT', 'VC'),
+	('VE', 'VE'), ('VEN', 'VE'), ('VENEZUELA', 'VE'),
+	('VG', 'VG'), ('VGB', 'VG'), ('VIRGIN ISLANDS BRITISH', 'VG'),
+	('VI', 'VI'), ('VIR', 'VI'), ('VIRGIN ISLANDS US', 'VI'),
+	('VIETNAM', 'VN'), ('VN', 'VN'), ('VNM', 'VN'),
+	('VANUATU', 'VU'), ('VU', 'VU'), ('VUT', 'VU'),
+	('WALLIS AND FUTUNA ISLANDS', 'WF'), ('WF', 'WF'), ('WLF', 'WF'),
+	('SAMOA', 'WS'), ('WS', 'WS'), ('WSM', 'WS'),
+	('KOSOVO', 'XK'), ('XK', 'XK'), ('XKX', 'XK'),
+	('YE', 'YE'), ('YEM', 'YE'), ('YEMEN', 'YE'),
+	('MAYOTTE', 'YT'), ('MYT', 'YT'), ('YT', 'YT'),
+	('YU', 'YU'), ('YUG', 'YU'), ('YUGOSLAVIA', 'YU'),
+	('SOUTH AFRICA', 'ZA'), ('ZA', 'ZA'), ('ZAF', 'ZA'),
+	('ZAMBIA', 'ZM'), ('ZM', 'ZM'), ('ZMB', 'ZM'),
+	('ZIMBABWE', 'ZW'), ('ZW', 'ZW'), ('ZWE', 'ZW');
+
+UPDATE Users u SET nationality = c.alpha2
+FROM country_codes c
+WHERE c.code = upper(trim(u.nationality)) AND u.nationality <> c.alpha2;
+
+UPDATE Users SET nationality = NULL, nationality_status = 'failed'
+WHERE nationality IS NOT NULL AND nationality NOT IN (SELECT alpha2 FROM country_codes);
+
+UPDATE user_nationalities n SET country_id = c.alpha2
+FROM country_codes c
+WHERE c.code = upper(trim(n.country_id)) AND n.country_id <> c.alpha2;
+
+DELETE FROM user_nationalities WHERE country_id NOT IN (SELECT alpha2 FROM country_codes);
@@ -0,0 +1,9 @@
+DROP TRIGGER IF EXISTS audit_events_redact_only ON audit_events;
+DROP FUNCTION IF EXISTS audit_events_redact_only();
+
+DROP TRIGGER IF EXISTS audit_events_append_only ON audit_events;
+CREATE TRIGGER audit_events_append_only BEFORE UPDATE OR DELETE OR TRUNCATE ON audit_events
+	FOR EACH STATEMENT EXECUTE FUNCTION audit_events_append_only();
+
+-- the redacted events stay redacted
+ALTER TABLE audit_events DROP COLUMN IF EXISTS redacted_at;
@@ -0,0 +1,26 @@
+-- a purge removes the personal data of the purged users from the audit trail and from the history: the
+-- events stay with their actor, action and ids, before and after are redacted. Only a transaction that sets
+-- people.redact may do that, audit_events stays append-only otherwise
+ALTER TABLE audit_events ADD COLUMN IF NOT EXISTS redacted_at timestamptz;
+
+DROP TRIGGER IF EXISTS audit_events_append_only ON audit_events;
+CREATE TRIGGER audit_events_append_only BEFORE DELETE OR TRUNCATE ON audit_events
+	FOR EACH STATEMENT EXECUTE FUNCTION audit_events_append_only();
+
+CREATE OR REPLACE FUNCTION audit_events_redact_only() RETURNS trigger
+	LANGUAGE plpgsql AS $$
+BEGIN
+	IF current_setting('people.redact', true) IS DISTINCT FROM 'on'
+		OR NEW.before IS NOT NULL OR NEW.after IS NOT NULL OR NEW.redacted_at IS NULL
+		OR (NEW.id, NEW.occurred_at, NEW.actor, NEW.action, NEW.entity, NEW.entity_id, NEW.user_ids, NEW.request_id)
+			IS DISTINCT FROM (OLD.id, OLD.occurred_at, OLD.actor, OLD.action, OLD.entity, OLD.entity_id, OLD.user_ids, OLD.request_id)
+	THEN
+		RAISE EXCEPTION 'audit_events is append-only, a purge may only redact before and after';
+	END IF;
+	RETURN NEW;
+END
+$$;
+
+DROP TRIGGER IF EXISTS audit_events_redact_only ON audit_events;
+CREATE TRIGGER audit_events_redact_only BEFORE UPDATE ON audit_events
+	FOR EACH ROW EXECUTE FUNCTION audit_events_redact_only();
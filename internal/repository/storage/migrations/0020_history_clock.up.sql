@@ -0,0 +1,146 @@
+-- the history went by now(), the start of the transaction: a transaction that started before the one it waited
+-- for kept the versions that one wrote valid until before they were valid from, or lost them. The history goes by
+-- clock_timestamp() now and leaves a version out only when the transaction that wrote it replaces it, the rows
+-- remember that transaction. The rows written before are taken for written by another transaction
+ALTER TABLE Users ADD COLUMN IF NOT EXISTS written_xact bigint;
+ALTER TABLE Users ALTER COLUMN written_xact SET DEFAULT txid_current();
+ALTER TABLE Users ALTER COLUMN updated_at SET DEFAULT clock_timestamp();
+
+ALTER TABLE Emails ADD COLUMN IF NOT EXISTS created_xact bigint;
+ALTER TABLE Emails ALTER COLUMN created_xact SET DEFAULT txid_current();
+ALTER TABLE Emails ALTER COLUMN created_at SET DEFAULT clock_timestamp();
+
+ALTER TABLE Friends ADD COLUMN IF NOT EXISTS created_xact bigint;
+ALTER TABLE Friends ALTER COLUMN created_xact SET DEFAULT txid_current();
+ALTER TABLE Friends ALTER COLUMN created_at SET DEFAULT clock_timestamp();
+
+-- a version replaced by the transaction that wrote it was never visible, the next one is valid from the same time
+CREATE OR REPLACE FUNCTION users_next_version() RETURNS trigger
+	LANGUAGE plpgsql AS $$
+BEGIN
+	NEW.version := OLD.version + 1;
+	NEW.written_xact := txid_current();
+	IF OLD.written_xact IS DISTINCT FROM NEW.written_xact THEN
+		NEW.updated_at := clock_timestamp();
+	ELSE
+		NEW.updated_at := OLD.updated_at;
+	END IF;
+	RETURN NEW;
+END
+$$;
+
+CREATE OR REPLACE FUNCTION users_history() RETURNS trigger
+	LANGUAGE plpgsql AS $$
+BEGIN
+	IF OLD.written_xact IS DISTINCT FROM txid_current() THEN
+		INSERT INTO users_history(user_id, version, valid_from, valid_to, data)
+		VALUES (
+			OLD.id,
+			OLD.version,
+			OLD.updated_at,
+			CASE WHEN TG_OP = 'UPDATE' THEN NEW.updated_at ELSE clock_timestamp() END,
+			to_jsonb(OLD) - ARRAY['search_name', 'search_vector', 'written_xact']
+		);
+	END IF;
+	RETURN NULL;
+END
+$$;
+
+CREATE OR REPLACE FUNCTION emails_history() RETURNS trigger
+	LANGUAGE plpgsql AS $$
+BEGIN
+	IF OLD.created_xact IS DISTINCT FROM txid_current() THEN
+		INSERT INTO emails_history(id, user_id, email, created_at, deleted_at)
+		VALUES (OLD.id, OLD.user_id, OLD.email, OLD.created_at, clock_timestamp());
+	END IF;
+	RETURN NULL;
+END
+$$;
+
+CREATE OR REPLACE FUNCTION friends_history() RETURNS trigger
+	LANGUAGE plpgsql AS $$
+BEGIN
+	IF OLD.created_xact IS DISTINCT FROM txid_current() THEN
+		INSERT INTO friends_history(id_first_friend, id_second_friend, created_at, deleted_at)
+		VALUES (OLD.id_first_friend, OLD.id_second_friend, OLD.created_at, clock_timestamp());
+	END IF;
+	RETURN NULL;
+END
+$$;
+
+-- audit_change records a created or deleted row whole and of an updated row only the changed columns,
+-- bookkeeping columns are left out and an update that changes nothing else is not recorded
+CREATE OR REPLACE FUNCTION audit_change() RETURNS trigger
+	LANGUAGE plpgsql AS $$
+DECLARE
+	ignored text[] := ARRAY['version', 'updated_at', 'search_name', 'search_vector', 'written_xact', 'created_xact'];
+	row_before jsonb;
+	row_after jsonb;
+	changed_before jsonb;
+	changed_after jsonb;
+	audit_row jsonb;
+	audit_entity text;
+	audit_entity_id text;
+	audit_user_ids integer[];
+	audit_action text;
+BEGIN
+	IF TG_OP <> 'INSERT' THEN
+		row_before := to_jsonb(OLD) - ignored;
+	END IF;
+	IF TG_OP <> 'DELETE' THEN
+		row_after := to_jsonb(NEW) - ignored;
+	END IF;
+	audit_row := COALESCE(row_after, row_before);
+
+	IF TG_OP = 'UPDATE' THEN
+		SELECT jsonb_object_agg(k, row_before -> k), jsonb_object_agg(k, row_after -> k)
+		INTO changed_before, changed_after
+		FROM jsonb_object_keys(row_after) AS k
+		WHERE row_before -> k IS DISTINCT FROM row_after -> k;
+
+		IF changed_after IS NULL THEN
+			RETURN NULL;
+		END IF;
+	ELSE
+		changed_before := row_before;
+		changed_after := row_after;
+	END IF;
+
+	IF TG_TABLE_NAME = 'users' THEN
+		audit_entity := 'user';
+		audit_entity_id := audit_row ->> 'id';
+		audit_user_ids := ARRAY[(audit_row ->> 'id')::integer];
+	ELSIF TG_TABLE_NAME = 'emails' THEN
+		audit_entity := 'email';
+		audit_entity_id := audit_row ->> 'id';
+		audit_user_ids := ARRAY[(audit_row ->> 'user_id')::integer];
+	ELSE
+		audit_entity := 'friendship';
+		audit_entity_id := (audit_row ->> 'id_first_friend') || '-' || (audit_row ->> 'id_second_friend');
+		audit_user_ids := ARRAY[(audit_row ->> 'id_first_friend')::integer, (audit_row ->> 'id_second_friend')::integer];
+	END IF;
+
+	audit_action := audit_entity || '.' || CASE
+		WHEN TG_OP = 'INSERT' THEN 'create'
+		WHEN TG_OP = 'DELETE' AND audit_entity = 'user' THEN 'purge'
+		WHEN TG_OP = 'DELETE' THEN 'delete'
+		WHEN row_before ->> 'deleted_at' IS NULL AND row_after ->> 'deleted_at' IS NOT NULL THEN 'delete'
+		WHEN row_before ->> 'deleted_at' IS NOT NULL AND row_after ->> 'deleted_at' IS NULL THEN 'restore'
+		ELSE 'update'
+	END;
+
+	INSERT INTO audit_events(actor, actor_source, action, entity, entity_id, user_ids, request_id, before, after)
+	VALUES (
+		COALESCE(NULLIF(current_setting('people.actor', true), ''), 'system'),
+		COALESCE(NULLIF(current_setting('people.actor_source', true), ''), 'system'),
+		audit_action,
+		audit_entity,
+		audit_entity_id,
+		audit_user_ids,
+		NULLIF(current_setting('people.request_id', true), ''),
+		changed_before,
+		changed_after
+	);
+	RETURN NULL;
+END
+$$;
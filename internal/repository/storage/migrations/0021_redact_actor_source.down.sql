@@ -0,0 +1,13 @@
+CREATE OR REPLACE FUNCTION audit_events_redact_only() RETURNS trigger
+	LANGUAGE plpgsql AS $$
+BEGIN
+	IF current_setting('people.redact', true) IS DISTINCT FROM 'on'
+		OR NEW.before IS NOT NULL OR NEW.after IS NOT NULL OR NEW.redacted_at IS NULL
+		OR (NEW.id, NEW.occurred_at, NEW.actor, NEW.action, NEW.entity, NEW.entity_id, NEW.user_ids, NEW.request_id)
+			IS DISTINCT FROM (OLD.id, OLD.occurred_at, OLD.actor, OLD.action, OLD.entity, OLD.entity_id, OLD.user_ids, OLD.request_id)
+	THEN
+		RAISE EXCEPTION 'audit_events is append-only, a purge may only redact before and after';
+	END IF;
+	RETURN NEW;
+END
+$$;
@@ -0,0 +1,2 @@
+DELETE FROM enrichment_cache WHERE not_found;
+ALTER TABLE enrichment_cache DROP COLUMN IF EXISTS not_found;
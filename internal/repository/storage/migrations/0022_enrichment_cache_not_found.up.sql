@@ -0,0 +1,2 @@
+-- names the upstream has no data for are cached as well, without a payload
+ALTER TABLE enrichment_cache ADD COLUMN IF NOT EXISTS not_found boolean not null default false;
@@ -0,0 +1,344 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"people/internal/types"
+)
+
+// CreateUserPending stores the user without attributes and queues its enrichment in one transaction
+func (s *Storage) CreateUserPending(ctx context.Context, name types.Name) (uint64, uint64, error) {
+	var userID, jobID uint64
+	err := s.InTransaction(ctx, func(uow *UnitOfWork) error {
+		var err error
+		userID, err = uow.CreateUser(ctx, types.User{Name: name}, nil)
+		if err != nil {
+			return err
+		}
+		jobID, err = uow.EnqueueEnrichment(ctx, userID)
+		return err
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return userID, jobID, nil
+}
+
+// ClaimEnrichmentJob locks the next due job for this worker, it returns types.ErrNotFound when the queue is empty.
+// A job whose worker died is taken again after lease while it has attempts left
+func (s *Storage) ClaimEnrichmentJob(ctx context.Context, lease time.Duration, maxAttempts int) (types.EnrichmentJob, error) {
+	connection, err := s.pool.Acquire(ctx)
+	if err != nil {
+		s.logger.WithError(err).Errorln("Error whole acquiring connection from the database pool!")
+		return types.EnrichmentJob{}, err
+	}
+
+	defer connection.Release()
+
+	var job types.EnrichmentJob
+	err = connection.QueryRow(ctx, ClaimEnrichmentJobTemplate, lease, maxAttempts).Scan(
+		&job.ID,
+		&job.UserID,
+		&job.FirstName,
+		&job.CountryHint,
+		&job.Status,
+		&job.Attempts,
+		&job.LastError,
+		&job.RunAt,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return types.EnrichmentJob{}, types.ErrNotFound
+		}
+		s.logger.WithError(err).Errorln("Error claiming enrichment job")
+		return types.EnrichmentJob{}, err
+	}
+
+	return job, nil
+}
+
+// BuryEnrichmentJobs moves the jobs whose worker died after the last attempt to the dead-letter state,
+// ClaimEnrichmentJob does not take them again
+func (s *Storage) BuryEnrichmentJobs(ctx context.Context, lease time.Duration, maxAttempts int) error {
+	connection, err := s.pool.Acquire(ctx)
+	if err != nil {
+		s.logger.WithError(err).Errorln("Error whole acquiring connection from the database pool!")
+		return err
+	}
+
+	defer connection.Release()
+
+	tx, err := connection.Begin(ctx)
+	if err != nil {
+		s.logger.WithError(err).Errorln("Error starting transaction")
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	err = setAuditInfo(ctx, tx)
+	if err != nil {
+		s.logger.WithError(err).Errorln("Error setting audit info")
+		return err
+	}
+
+	lastError := fmt.Sprintf("worker did not finish the job in %s after %d attempts", lease, maxAttempts)
+	_, err = tx.Exec(ctx, BuryEnrichmentJobsTemplate, lease, maxAttempts, lastError)
+	if err != nil {
+		s.logger.WithError(err).Errorln("Failed to bury enrichment jobs")
+		return err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		s.logger.WithError(err).Errorln("Error committing transaction")
+		return err
+	}
+
+	return nil
+}
+
+// SaveUserEnrichment stores the resolved attributes with their details and records what changed,
+// nil attributes are left untouched
+func (s *Storage) SaveUserEnrichment(ctx context.Context, id uint64, user types.User, details types.EnrichmentDetails) ([]types.EnrichmentChange, error) {
+	connection, err := s.pool.Acquire(ctx)
+	if err != nil {
+		s.logger.WithError(err).Errorln("Error whole acquiring connection from the database pool!")
+		return nil, err
+	}
+
+	defer connection.Release()
+
+	tx, err := connection.Begin(ctx)
+	if err != nil {
+		s.logger.WithError(err).Errorln("Error starting transaction")
+		return nil, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	err = setAuditInfo(ctx, tx)
+	if err != nil {
+		s.logger.WithError(err).Errorln("Error setting audit info")
+		return nil, err
+	}
+
+	var current types.User
+	err = tx.QueryRow(ctx, LockUserAttributesTemplate, id).Scan(
+		&current.Age,
+		&current.Gender,
+		&current.Nationality,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			s.logger.Errorf("Not found user with id %d", id)
+			return nil, fmt.Errorf("user %d: %w", id, types.ErrNotFound)
+		}
+		s.logger.WithError(err).Errorln("Failed to lock user")
+		return nil, err
+	}
+
+	changes := types.EnrichmentChanges(current, user)
+
+	batch := &pgx.Batch{}
+	batch.Queue(
+		UpdateUserEnrichmentTemplate,
+		id,
+		user.Age,
+		user.Gender,
+		user.Nationality,
+	)
+	batch.Queue(
+		UpsertUserEnrichmentTemplate,
+		id,
+		details.AgeCount,
+		details.GenderProbability,
+		details.GenderCount,
+		details.NationalityCount,
+	)
+	if details.Countries != nil {
+		batch.Queue(DeleteUserNationalitiesTemplate, id)
+		for rank, country := range details.Countries {
+			batch.Queue(
+				AddUserNationalityTemplate,
+				id,
+				rank+1,
+				country.ID,
+				country.Probability,
+			)
+		}
+	}
+	for _, change := range changes {
+		batch.Queue(
+			AddEnrichmentChangeTemplate,
+			id,
+			change.Attribute,
+			change.OldValue,
+			change.NewValue,
+		)
+	}
+
+	err = tx.SendBatch(ctx, batch).Close()
+	if err != nil {
+		s.logger.WithError(err).Errorln("Failed to save user enrichment")
+		return nil, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		s.logger.WithError(err).Errorln("Error committing transaction")
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// EnqueueEnrichmentRefresh queues jobs for the users matching the filter that have no job in progress
+func (s *Storage) EnqueueEnrichmentRefresh(ctx context.Context, filter types.RefreshFilter) (int64, error) {
+	connection, err := s.pool.Acquire(ctx)
+	if err != nil {
+		s.logger.WithError(err).Errorln("Error whole acquiring connection from the database pool!")
+		return 0, err
+	}
+
+	defer connection.Release()
+
+	var enrichedBefore *time.Time
+	if !filter.EnrichedBefore.IsZero() {
+		enrichedBefore = &filter.EnrichedBefore
+	}
+
+	commandTag, err := connection.Exec(
+		ctx,
+		EnqueueEnrichmentRefreshTemplate,
+		enrichedBefore,
+		filter.MissingOnly,
+		filter.Limit,
+	)
+	if err != nil {
+		s.logger.WithError(err).Errorln("Failed to enqueue enrichment refresh")
+		return 0, err
+	}
+
+	return commandTag.RowsAffected(), nil
+}
+
+// FinishEnrichmentJob stores the new state of a processed job and marks the given attributes as failed
+func (s *Storage) FinishEnrichmentJob(ctx context.Context, job types.EnrichmentJob, failed []string) error {
+	connection, err := s.pool.Acquire(ctx)
+	if err != nil {
+		s.logger.WithError(err).Errorln("Error whole acquiring connection from the database pool!")
+		return err
+	}
+
+	defer connection.Release()
+
+	// the failed statuses change the user, the audit trail gets the worker as the actor
+	tx, err := connection.Begin(ctx)
+	if err != nil {
+		s.logger.WithError(err).Errorln("Error starting transaction")
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	err = setAuditInfo(ctx, tx)
+	if err != nil {
+		s.logger.WithError(err).Errorln("Error setting audit info")
+		return err
+	}
+
+	batch := &pgx.Batch{}
+	batch.Queue(
+		UpdateEnrichmentJobTemplate,
+		job.ID,
+		job.Status,
+		job.LastError,
+		job.RunAt,
+		job.Attempts,
+	)
+	if len(failed) > 0 {
+		batch.Queue(MarkEnrichmentFailedTemplate, job.UserID, failed)
+	}
+
+	err = tx.SendBatch(ctx, batch).Close()
+	if err != nil {
+		s.logger.WithError(err).Errorln("Failed to finish enrichment job")
+		return err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		s.logger.WithError(err).Errorln("Error committing transaction")
+		return err
+	}
+
+	return nil
+}
+
+// GetEnrichmentState returns the attribute statuses of the user with its latest job
+func (s *Storage) GetEnrichmentState(ctx context.Context, id uint64) (types.EnrichmentState, error) {
+	connection, err := s.pool.Acquire(ctx)
+	if err != nil {
+		s.logger.WithError(err).Errorln("Error whole acquiring connection from the database pool!")
+		return types.EnrichmentState{}, err
+	}
+
+	defer connection.Release()
+
+	state := types.EnrichmentState{UserID: id}
+
+	var (
+		jobID     *uint64
+		status    *string
+		attempts  *int
+		lastError *string
+		runAt     *time.Time
+		createdAt *time.Time
+		updatedAt *time.Time
+	)
+
+	err = connection.QueryRow(ctx, GetEnrichmentStateTemplate, id).Scan(
+		&state.EnrichmentStatus.Age,
+		&state.EnrichmentStatus.Gender,
+		&state.EnrichmentStatus.Nationality,
+		&jobID,
+		&status,
+		&attempts,
+		&lastError,
+		&runAt,
+		&createdAt,
+		&updatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			s.logger.WithError(err).Errorln("No such row in Users")
+			return types.EnrichmentState{}, fmt.Errorf("user %d: %w", id, types.ErrNotFound)
+		}
+		s.logger.WithError(err).Errorln("Error getting enrichment state")
+		return types.EnrichmentState{}, err
+	}
+
+	// users enriched synchronously have no job
+	if jobID != nil {
+		state.Job = &types.EnrichmentJob{
+			ID:        *jobID,
+			UserID:    id,
+			Status:    *status,
+			Attempts:  *attempts,
+			LastError: lastError,
+			RunAt:     *runAt,
+			CreatedAt: *createdAt,
+			UpdatedAt: *updatedAt,
+		}
+	}
+
+	return state, nil
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -17,48 +19,22 @@ type Storage struct {
 	logger *logger.Entry
 }
 
-func (s *Storage) Migrations(ctx context.Context) error {
-	connection, err := s.pool.Acquire(context.Background())
-	if err != nil {
-		s.logger.WithError(err).Errorln("Error whole acquiring connection from the database pool!")
-		return err
-	}
-
-	defer connection.Release()
-
-	err = connection.Ping(context.Background())
-	if err != nil {
-		s.logger.WithError(err).Errorln("Could not ping the db!")
-		return err
-	}
-
-	_, err = connection.Exec(ctx, createUsersTableTemplate)
-	if err != nil {
-		s.logger.WithError(err).Errorln("Error create Users table")
-		return err
-	}
-
-	_, err = connection.Exec(ctx, createEmailsTableTemplate)
+// New connects to the database and migrates it to the latest version
+func New(ctx context.Context, dbUrl string, log *logger.Logger) (*Storage, error) {
+	s, err := Connect(ctx, dbUrl, log)
 	if err != nil {
-		s.logger.WithError(err).Errorln("Error create Emails table")
-		return err
+		return &Storage{}, err
 	}
 
-	_, err = connection.Exec(ctx, createFriendsTableTemplate)
-	if err != nil {
-		s.logger.WithError(err).Errorln("Error create Friends table")
-		return err
-	}
-	_, err = connection.Exec(ctx, createFriendsIndexTemplates)
+	err = s.Migrations(ctx)
 	if err != nil {
-		s.logger.WithError(err).Errorln("Error create Friends additional index")
-		return err
+		return &Storage{}, err
 	}
-
-	return nil
+	return s, nil
 }
 
-func New(ctx context.Context, dbUrl string, log *logger.Logger) (*Storage, error) {
+// Connect connects to the database without migrating it
+func Connect(ctx context.Context, dbUrl string, log *logger.Logger) (*Storage, error) {
 	pool, err := pgxpool.New(ctx, dbUrl)
 	if err != nil {
 		return &Storage{}, err
@@ -72,15 +48,10 @@ func New(ctx context.Context, dbUrl string, log *logger.Logger) (*Storage, error
 
 	logEntry := log.WithField("package", "storage")
 
-	r := &Storage{pool: pool, logger: logEntry}
-	err = r.Migrations(ctx)
-	if err != nil {
-		return &Storage{}, err
-	}
-	return r, nil
+	return &Storage{pool: pool, logger: logEntry}, nil
 }
 
-func (s *Storage) GetAllUsersInfo(ctx context.Context) ([]types.UserInfo, error) {
+func (s *Storage) GetAllUsersInfo(ctx context.Context, filter types.UsersFilter) ([]types.UserInfo, error) {
 	connection, err := s.pool.Acquire(ctx)
 	if err != nil {
 		s.logger.WithError(err).Errorln("Error whole acquiring connection from the database pool!")
@@ -89,7 +60,30 @@ func (s *Storage) GetAllUsersInfo(ctx context.Context) ([]types.UserInfo, error)
 
 	defer connection.Release()
 
-	rows, err := connection.Query(ctx, GetAllUsersTemplate)
+	query, after, err := usersQuery(filter)
+	if err != nil {
+		s.logger.WithError(err).Errorln("Error building users query")
+		return []types.UserInfo{}, err
+	}
+
+	var afterID *uint64
+	if filter.After != nil {
+		afterID = &filter.After.ID
+	}
+
+	rows, err := connection.Query(ctx, query,
+		filter.Nationalities,
+		filter.Gender,
+		filter.AgeMin,
+		filter.AgeMax,
+		likePrefix(filter.FirstNamePrefix),
+		likePrefix(filter.LastNamePrefix),
+		filter.HasEmail,
+		filter.Limit,
+		after,
+		afterID,
+		filter.LastName,
+	)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			s.logger.WithError(err).Errorln("No such rows in Users")
@@ -104,13 +98,25 @@ func (s *Storage) GetAllUsersInfo(ctx context.Context) ([]types.UserInfo, error)
 
 	for rows.Next() {
 		var user types.UserInfo
+		var details types.EnrichmentDetails
 		err = rows.Scan(
 			&user.ID,
 			&user.FirstName,
 			&user.LastName,
+			&user.CountryHint,
 			&user.Gender,
 			&user.Age,
 			&user.Nationality,
+			&user.EnrichmentStatus.Age,
+			&user.EnrichmentStatus.Gender,
+			&user.EnrichmentStatus.Nationality,
+			&user.Version,
+			&user.UpdatedAt,
+			&details.AgeCount,
+			&details.GenderProbability,
+			&details.GenderCount,
+			&details.NationalityCount,
+			&details.Countries,
 			&user.Emails,
 		)
 
@@ -119,9 +125,14 @@ func (s *Storage) GetAllUsersInfo(ctx context.Context) ([]types.UserInfo, error)
 			errs = append(errs, err)
 		}
 
+		if !details.IsZero() {
+			user.Enrichment = &details
+		}
+
 		users = append(users, user)
 	}
 
+	errs = append(errs, rows.Err())
 	err = errors.Join(errs...)
 	if err != nil {
 		s.logger.WithError(err).Errorln("Error getting all users info")
@@ -131,6 +142,178 @@ func (s *Storage) GetAllUsersInfo(ctx context.Context) ([]types.UserInfo, error)
 	return users, nil
 }
 
+// usersSort is a sort key of GET /users, integer keys are compared as numbers
+type usersSort struct {
+	expression string
+	integer    bool
+}
+
+var usersSorts = map[string]usersSort{
+	"id":         {expression: "u.id", integer: true},
+	"age":        {expression: "COALESCE(u.age, -1)", integer: true},
+	"first_name": {expression: "u.first_name"},
+	"last_name":  {expression: "u.last_name"},
+}
+
+// usersQuery completes GetAllUsersTemplate for the sort order of the filter and returns the cursor key
+func usersQuery(filter types.UsersFilter) (string, any, error) {
+	name := strings.TrimPrefix(filter.Sort, "-")
+	sort, ok := usersSorts[name]
+	if !ok {
+		return "", nil, fmt.Errorf("unknown sort %q", filter.Sort)
+	}
+
+	direction, comparison := "ASC", ">"
+	if strings.HasPrefix(filter.Sort, "-") {
+		direction, comparison = "DESC", "<"
+	}
+
+	keyType := "text"
+	if sort.integer {
+		keyType = "integer"
+	}
+
+	var after any
+	if filter.After != nil {
+		after = filter.After.Key
+		if sort.integer {
+			key, err := strconv.Atoi(filter.After.Key)
+			if err != nil {
+				return "", nil, fmt.Errorf("%w: %s", types.ErrInvalidCursor, err)
+			}
+			after = key
+		}
+	}
+
+	return fmt.Sprintf(GetAllUsersTemplate, sort.expression, keyType, comparison, direction), after, nil
+}
+
+// likePrefix escapes the LIKE wildcards of a name prefix
+func likePrefix(prefix *string) *string {
+	if prefix == nil {
+		return nil
+	}
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(*prefix)
+	return &escaped
+}
+
+// GetUserByID returns the user with its emails and the number of its friends
+func (s *Storage) GetUserByID(ctx context.Context, id uint64) (types.UserInfo, error) {
+	connection, err := s.pool.Acquire(ctx)
+	if err != nil {
+		s.logger.WithError(err).Errorln("Error whole acquiring connection from the database pool!")
+		return types.UserInfo{}, err
+	}
+
+	defer connection.Release()
+
+	var user types.UserInfo
+	var details types.EnrichmentDetails
+	var friendCount uint64
+	err = connection.QueryRow(ctx, GetUserByIDTemplate, id).Scan(
+		&user.ID,
+		&user.FirstName,
+		&user.LastName,
+		&user.CountryHint,
+		&user.Gender,
+		&user.Age,
+		&user.Nationality,
+		&user.EnrichmentStatus.Age,
+		&user.EnrichmentStatus.Gender,
+		&user.EnrichmentStatus.Nationality,
+		&user.Version,
+		&user.UpdatedAt,
+		&details.AgeCount,
+		&details.GenderProbability,
+		&details.GenderCount,
+		&details.NationalityCount,
+		&details.Countries,
+		&user.Emails,
+		&friendCount,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			s.logger.WithError(err).Errorln("No such row in Users")
+			return types.UserInfo{}, fmt.Errorf("user %d: %w", id, types.ErrNotFound)
+		}
+		s.logger.WithError(err).Errorln("Error getting user info")
+		return types.UserInfo{}, err
+	}
+
+	if !details.IsZero() {
+		user.Enrichment = &details
+	}
+	user.FriendCount = &friendCount
+
+	return user, nil
+}
+
+// SearchUsers returns the users whose names match the query, the best matches first
+func (s *Storage) SearchUsers(ctx context.Context, query string, limit int) ([]types.UserMatch, error) {
+	connection, err := s.pool.Acquire(ctx)
+	if err != nil {
+		s.logger.WithError(err).Errorln("Error whole acquiring connection from the database pool!")
+		return []types.UserMatch{}, err
+	}
+
+	defer connection.Release()
+
+	rows, err := connection.Query(ctx, SearchUsersTemplate, query, limit)
+	if err != nil {
+		s.logger.WithError(err).Errorln("Error searching users")
+		return []types.UserMatch{}, err
+	}
+
+	matches := []types.UserMatch{}
+	var errs []error
+
+	for rows.Next() {
+		var match types.UserMatch
+		var details types.EnrichmentDetails
+		err = rows.Scan(
+			&match.ID,
+			&match.FirstName,
+			&match.LastName,
+			&match.CountryHint,
+			&match.Gender,
+			&match.Age,
+			&match.Nationality,
+			&match.EnrichmentStatus.Age,
+			&match.EnrichmentStatus.Gender,
+			&match.EnrichmentStatus.Nationality,
+			&match.Version,
+			&match.UpdatedAt,
+			&details.AgeCount,
+			&details.GenderProbability,
+			&details.GenderCount,
+			&details.NationalityCount,
+			&details.Countries,
+			&match.Emails,
+			&match.Score,
+		)
+
+		if err != nil {
+			s.logger.WithError(err).Errorln("Error getting found user")
+			errs = append(errs, err)
+		}
+
+		if !details.IsZero() {
+			match.Enrichment = &details
+		}
+
+		matches = append(matches, match)
+	}
+
+	errs = append(errs, rows.Err())
+	err = errors.Join(errs...)
+	if err != nil {
+		s.logger.WithError(err).Errorln("Error searching users")
+		return []types.UserMatch{}, err
+	}
+
+	return matches, nil
+}
+
 func (s *Storage) GetUserInfoBySecondName(ctx context.Context, name string) ([]types.UserInfo, error) {
 	connection, err := s.pool.Acquire(ctx)
 	if err != nil {
@@ -156,13 +339,25 @@ func (s *Storage) GetUserInfoBySecondName(ctx context.Context, name string) ([]t
 
 	for rows.Next() {
 		var user types.UserInfo
+		var details types.EnrichmentDetails
 		err = rows.Scan(
 			&user.ID,
 			&user.FirstName,
 			&user.LastName,
+			&user.CountryHint,
 			&user.Gender,
 			&user.Age,
 			&user.Nationality,
+			&user.EnrichmentStatus.Age,
+			&user.EnrichmentStatus.Gender,
+			&user.EnrichmentStatus.Nationality,
+			&user.Version,
+			&user.UpdatedAt,
+			&details.AgeCount,
+			&details.GenderProbability,
+			&details.GenderCount,
+			&details.NationalityCount,
+			&details.Countries,
 			&user.Emails,
 		)
 
@@ -171,9 +366,14 @@ func (s *Storage) GetUserInfoBySecondName(ctx context.Context, name string) ([]t
 			errs = append(errs, err)
 		}
 
+		if !details.IsZero() {
+			user.Enrichment = &details
+		}
+
 		users = append(users, user)
 	}
 
+	errs = append(errs, rows.Err())
 	err = errors.Join(errs...)
 	if err != nil {
 		s.logger.WithError(err).Errorln("Error getting all users info")
@@ -221,6 +421,7 @@ func (s *Storage) GetUserEmails(ctx context.Context, id uint64) ([]types.Email,
 		emails = append(emails, email)
 	}
 
+	errs = append(errs, rows.Err())
 	err = errors.Join(errs...)
 	if err != nil {
 		s.logger.WithError(err).Errorln("Error getting emails")
@@ -268,6 +469,7 @@ func (s *Storage) GetUserFriends(ctx context.Context, id uint64) ([]types.Friend
 		friends = append(friends, friend)
 	}
 
+	errs = append(errs, rows.Err())
 	err = errors.Join(errs...)
 	if err != nil {
 		s.logger.WithError(err).Errorln("Error getting friends")
@@ -277,176 +479,289 @@ func (s *Storage) GetUserFriends(ctx context.Context, id uint64) ([]types.Friend
 	return friends, nil
 }
 
-func (s *Storage) CreateUser(ctx context.Context, user types.User) (uint64, error) {
-	connection, err := s.pool.Acquire(context.Background())
+// CreateUser stores the user with the enrichment details in one transaction,
+// missing attributes are stored as NULL with the pending status
+func (s *Storage) CreateUser(ctx context.Context, user types.User, details types.EnrichmentDetails) (uint64, error) {
+	var id uint64
+	err := s.InTransaction(ctx, func(uow *UnitOfWork) error {
+		var err error
+		enrichedAt := time.Now()
+		id, err = uow.CreateUser(ctx, user, &enrichedAt)
+		if err != nil {
+			return err
+		}
+		return uow.AddEnrichmentDetails(ctx, id, details)
+	})
 	if err != nil {
-		s.logger.WithError(err).Errorln("Error whole acquiring connection from the database pool!")
 		return 0, err
 	}
 
-	defer connection.Release()
-
-	var id uint64
-
-	err = connection.QueryRow(
-		ctx,
-		AddUserInfoTemplate,
-		user.FirstName,
-		user.LastName,
-		user.Gender,
-		user.Nationality,
-		user.Age,
-	).Scan(&id)
-	if err != nil {
-		s.logger.WithError(err).Errorln("Failed to add user")
-		return 0, err
-	}
 	return id, nil
 }
 
-// AddUserEmails - can add one or more user`s emails
-func (s *Storage) AddUserEmails(ctx context.Context, emails types.EmailRequest, id uint64) error {
-	connection, err := s.pool.Acquire(context.Background())
-	if err != nil {
-		s.logger.WithError(err).Errorln("Error whole acquiring connection from the database pool!")
-		return err
-	}
-
-	defer connection.Release()
-
+// AddUserEmails - can add one or more user`s emails,
+// all of them or none: one item that is not OK rolls back the batch with a types.ItemsError
+func (s *Storage) AddUserEmails(ctx context.Context, emails types.EmailRequest, id uint64) ([]types.ItemResult, error) {
 	if len(emails.Emails) == 0 {
 		s.logger.Errorln("No emails found!")
-		return types.ErrNotFound
+		return nil, types.ErrNotFound
 	}
 
-	batch := &pgx.Batch{}
+	var results []types.ItemResult
+	err := s.InTransaction(ctx, func(uow *UnitOfWork) error {
+		var err error
+		results, err = uow.AddEmails(ctx, id, emails.Emails)
+		if err != nil {
+			return err
+		}
+		return types.RejectItems(results)
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	for _, email := range emails.Emails {
-		batch.Queue(
-			AddEmailTemplate,
-			id,
-			strings.TrimSpace(email),
-		)
+	return results, nil
+}
+
+// AddUserFriends - can add one or more user`s friends,
+// all of them or none: one item that is not OK rolls back the batch with a types.ItemsError
+func (s *Storage) AddUserFriends(ctx context.Context, friends types.Friends, userID uint64) ([]types.ItemResult, error) {
+	if len(friends.FriendsIDs) == 0 {
+		s.logger.Errorln("No fiends found!")
+		return nil, errors.New("No friends")
 	}
 
-	err = connection.SendBatch(ctx, batch).Close()
+	var results []types.ItemResult
+	err := s.InTransaction(ctx, func(uow *UnitOfWork) error {
+		var err error
+		results, err = uow.AddFriends(ctx, userID, friends.FriendsIDs)
+		if err != nil {
+			return err
+		}
+		return types.RejectItems(results)
+	})
 	if err != nil {
-		s.logger.WithError(err).Errorln("Error sending batch!")
-		return err
+		return nil, err
 	}
 
-	return nil
+	return results, nil
 }
 
-// AddUserFriends - can add one or more user`s friends
-func (s *Storage) AddUserFriends(ctx context.Context, friends types.Friends, userID uint64) error {
-	connection, err := s.pool.Acquire(ctx)
-	if err != nil {
-		s.logger.WithError(err).Errorln("Error whole acquiring connection from the database pool!")
+// UpdateUser replaces the user when it matches ifMatch and returns its new version
+func (s *Storage) UpdateUser(ctx context.Context, user types.User, id uint64, ifMatch types.IfMatch) (uint64, error) {
+	var version uint64
+	err := s.InTransaction(ctx, func(uow *UnitOfWork) error {
+		err := uow.MatchVersion(ctx, id, ifMatch)
+		if err != nil {
+			return err
+		}
+
+		status := user.EnrichmentStatus()
+		err = uow.tx.QueryRow(
+			ctx,
+			UpdateUserInfoTemplate,
+			id,
+			user.FirstName,
+			user.LastName,
+			user.Gender,
+			user.Nationality,
+			user.Age,
+			status.Age,
+			status.Gender,
+			status.Nationality,
+			user.CountryHint,
+		).Scan(&version)
+		if err != nil {
+			s.logger.WithError(err).Errorln("Failed to update user")
+		}
 		return err
+	})
+	if err != nil {
+		return 0, err
 	}
 
-	defer connection.Release()
+	return version, nil
+}
 
-	if len(friends.FriendsIDs) == 0 {
-		s.logger.Errorln("No fiends found!")
-		return errors.New("No friends")
-	}
+// DeleteUser marks the user deleted when it matches ifMatch, it is hidden from reads until it is restored
+func (s *Storage) DeleteUser(ctx context.Context, id uint64, ifMatch types.IfMatch) error {
+	return s.InTransaction(ctx, func(uow *UnitOfWork) error {
+		err := uow.MatchVersion(ctx, id, ifMatch)
+		if err != nil {
+			return err
+		}
 
-	batch := &pgx.Batch{}
+		_, err = uow.tx.Exec(ctx, DeleteUserTemplate, id)
+		if err != nil {
+			s.logger.WithError(err).Errorln("Failed to delete user")
+		}
+		return err
+	})
+}
 
-	for _, friend := range friends.FriendsIDs {
-		if friend == userID {
-			s.logger.Warnf("Attempted to add self as friend (userID: %d)\n", userID)
-			continue
+// RestoreUser brings back a deleted user and returns its new version
+func (s *Storage) RestoreUser(ctx context.Context, id uint64) (uint64, error) {
+	var version uint64
+	err := s.InTransaction(ctx, func(uow *UnitOfWork) error {
+		deletedAt, err := uow.deletedAt(ctx, id)
+		if err != nil {
+			return err
 		}
-		// add ids in ascending order to avoid duplicating pairs (since the pairs of id [1, 2] and [2, 1] equal
-		if userID < friend {
-			batch.Queue(
-				AddFriendshipTemplate,
-				userID,
-				friend,
-			)
-		} else {
-			batch.Queue(
-				AddFriendshipTemplate,
-				friend,
-				userID,
-			)
+		if deletedAt == nil {
+			err = fmt.Errorf("user %d: %w", id, types.ErrNotDeleted)
+			s.logger.WithError(err).Errorln("Failed to restore user")
+			return err
 		}
-	}
 
-	err = connection.SendBatch(ctx, batch).Close()
-	if err != nil {
-		s.logger.WithError(err).Errorln("Error sending batch!")
+		err = uow.tx.QueryRow(ctx, RestoreUserTemplate, id).Scan(&version)
+		if err != nil {
+			s.logger.WithError(err).Errorln("Failed to restore user")
+		}
 		return err
+	})
+	if err != nil {
+		return 0, err
 	}
 
-	return nil
+	return version, nil
 }
 
-func (s *Storage) UpdateUser(ctx context.Context, user types.User, id uint64) error {
-	connection, err := s.pool.Acquire(context.Background())
+// PurgeUser removes a deleted user for good together with its emails, friendships and their history,
+// its audit events are kept with before and after redacted
+func (s *Storage) PurgeUser(ctx context.Context, id uint64) error {
+	return s.InTransaction(ctx, func(uow *UnitOfWork) error {
+		deletedAt, err := uow.deletedAt(ctx, id)
+		if err != nil {
+			return err
+		}
+		if deletedAt == nil {
+			err = fmt.Errorf("user %d: %w", id, types.ErrNotDeleted)
+			s.logger.WithError(err).Errorln("Failed to purge user")
+			return err
+		}
+
+		_, err = uow.tx.Exec(ctx, PurgeUserTemplate, id)
+		if err != nil {
+			s.logger.WithError(err).Errorln("Failed to purge user")
+			return err
+		}
+		return uow.forgetUsers(ctx, []uint64{id})
+	})
+}
+
+// PurgeDeletedUsers removes at most limit users deleted before the given time the way PurgeUser does and
+// returns how many were removed
+func (s *Storage) PurgeDeletedUsers(ctx context.Context, before time.Time, limit int) (int64, error) {
+	var purged int64
+	err := s.InTransaction(ctx, func(uow *UnitOfWork) error {
+		rows, err := uow.tx.Query(ctx, PurgeDeletedUsersTemplate, before, limit)
+		if err != nil {
+			s.logger.WithError(err).Errorln("Failed to purge deleted users")
+			return err
+		}
+		ids, err := pgx.CollectRows(rows, pgx.RowTo[uint64])
+		if err != nil {
+			s.logger.WithError(err).Errorln("Failed to purge deleted users")
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		purged = int64(len(ids))
+		return uow.forgetUsers(ctx, ids)
+	})
 	if err != nil {
-		s.logger.WithError(err).Errorln("Error whole acquiring connection from the database pool!")
-		return err
+		return 0, err
 	}
 
-	defer connection.Release()
+	return purged, nil
+}
 
-	commandTag, err := connection.Exec(
-		ctx,
-		UpdateUserInfoTemplate,
-		id,
-		user.FirstName,
-		user.LastName,
-		user.Gender,
-		user.Nationality,
-		user.Age,
-	)
+// DeleteEmails - can delete one or more emails,
+// all of them or none: one item that is not OK rolls back the batch with a types.ItemsError
+func (s *Storage) DeleteEmails(ctx context.Context, emails []uint64) ([]types.ItemResult, error) {
+	if len(emails) == 0 {
+		s.logger.Errorln("No emails found!")
+		return nil, errors.New("No emails")
+	}
+
+	var results []types.ItemResult
+	err := s.InTransaction(ctx, func(uow *UnitOfWork) error {
+		var err error
+		results, err = uow.DeleteEmails(ctx, emails)
+		if err != nil {
+			return err
+		}
+		return types.RejectItems(results)
+	})
 	if err != nil {
-		s.logger.WithError(err).Errorln("Failed to update user")
-		return err
+		return nil, err
 	}
 
-	if commandTag.RowsAffected() == 0 {
-		err = fmt.Errorf("Not found user with id %d", id)
-		s.logger.Errorln(err)
-		return err
+	return results, nil
+}
+
+// DeleteUserFriends - can delete one or more user`s friends,
+// all of them or none: one item that is not OK rolls back the batch with a types.ItemsError
+func (s *Storage) DeleteUserFriends(ctx context.Context, friendsPairs types.Friendships) ([]types.ItemResult, error) {
+	if len(friendsPairs.Friends) == 0 {
+		s.logger.Errorln("No fiends found!")
+		return nil, errors.New("No friends")
 	}
-	return nil
+
+	var results []types.ItemResult
+	err := s.InTransaction(ctx, func(uow *UnitOfWork) error {
+		var err error
+		results, err = uow.DeleteFriendships(ctx, friendsPairs.Friends)
+		if err != nil {
+			return err
+		}
+		return types.RejectItems(results)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
 }
 
-func (s *Storage) DeleteUser(ctx context.Context, id uint64) error {
-	connection, err := s.pool.Acquire(context.Background())
+func (s *Storage) GetEnrichmentCache(ctx context.Context, name, attribute, country string) (types.EnrichmentCacheEntry, error) {
+	connection, err := s.pool.Acquire(ctx)
 	if err != nil {
 		s.logger.WithError(err).Errorln("Error whole acquiring connection from the database pool!")
-		return err
+		return types.EnrichmentCacheEntry{}, err
 	}
 
 	defer connection.Release()
 
-	commandTag, err := connection.Exec(
-		ctx,
-		DeleteUserTemplate,
-		id,
+	entry := types.EnrichmentCacheEntry{
+		Name:      name,
+		Attribute: attribute,
+		Country:   country,
+	}
+
+	err = connection.QueryRow(ctx, GetEnrichmentCacheTemplate, name, attribute, country).Scan(
+		&entry.Value,
+		&entry.Probability,
+		&entry.Count,
+		&entry.Payload,
+		&entry.NotFound,
+		&entry.FetchedAt,
 	)
 	if err != nil {
-		s.logger.WithError(err).Errorln("Failed to delete user")
-		return err
+		if errors.Is(err, pgx.ErrNoRows) {
+			return types.EnrichmentCacheEntry{}, types.ErrNotFound
+		}
+		s.logger.WithError(err).Errorln("Error getting enrichment cache")
+		return types.EnrichmentCacheEntry{}, err
 	}
 
-	if commandTag.RowsAffected() == 0 {
-		err = fmt.Errorf("Not found user with id %d", id)
-		s.logger.Errorln(err)
-		return err
-	}
-	return nil
+	return entry, nil
 }
 
-// DeleteEmails - can delete one or more emails
-func (s *Storage) DeleteEmails(ctx context.Context, emails []uint64) error {
-	connection, err := s.pool.Acquire(context.Background())
+func (s *Storage) SaveEnrichmentCache(ctx context.Context, entry types.EnrichmentCacheEntry) error {
+	connection, err := s.pool.Acquire(ctx)
 	if err != nil {
 		s.logger.WithError(err).Errorln("Error whole acquiring connection from the database pool!")
 		return err
@@ -454,68 +769,64 @@ func (s *Storage) DeleteEmails(ctx context.Context, emails []uint64) error {
 
 	defer connection.Release()
 
-	if len(emails) == 0 {
-		s.logger.Errorln("No emails found!")
-		return errors.New("No emails")
-	}
-
-	batch := &pgx.Batch{}
-
-	for _, email := range emails {
-		batch.Queue(
-			DeleteEmailTemplate,
-			email,
-		)
-	}
-
-	err = connection.SendBatch(ctx, batch).Close()
+	_, err = connection.Exec(
+		ctx,
+		SaveEnrichmentCacheTemplate,
+		entry.Name,
+		entry.Attribute,
+		entry.Country,
+		entry.Value,
+		entry.Probability,
+		entry.Count,
+		entry.Payload,
+		entry.NotFound,
+		entry.FetchedAt,
+	)
 	if err != nil {
-		s.logger.WithError(err).Errorln("Error sending batch!")
+		s.logger.WithError(err).Errorln("Failed to save enrichment cache")
 		return err
 	}
 
 	return nil
 }
 
-// DeleteUserFriends - can delete one or more user`s friends
-func (s *Storage) DeleteUserFriends(ctx context.Context, friendsPairs types.Friendships) error {
-	connection, err := s.pool.Acquire(context.Background())
+func (s *Storage) GetUserName(ctx context.Context, id uint64) (types.Name, error) {
+	connection, err := s.pool.Acquire(ctx)
 	if err != nil {
 		s.logger.WithError(err).Errorln("Error whole acquiring connection from the database pool!")
-		return err
+		return types.Name{}, err
 	}
 
 	defer connection.Release()
 
-	if len(friendsPairs.Friends) == 0 {
-		s.logger.Errorln("No fiends found!")
-		return errors.New("No friends")
-	}
-
-	batch := &pgx.Batch{}
-
-	for _, friends := range friendsPairs.Friends {
-		// use ids in ascending order to avoid duplicating pairs (since the pairs of id [1, 2] and [2, 1] equal
-		if friends.IDFirstUser < friends.IDSecondUser {
-			batch.Queue(
-				DeleteFriendshipTemplate,
-				friends.IDFirstUser,
-				friends.IDSecondUser,
-			)
-		} else {
-			batch.Queue(
-				DeleteFriendshipTemplate,
-				friends.IDSecondUser,
-				friends.IDFirstUser,
-			)
+	var name types.Name
+	err = connection.QueryRow(ctx, GetUserNameTemplate, id).Scan(
+		&name.FirstName,
+		&name.LastName,
+		&name.CountryHint,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			s.logger.WithError(err).Errorln("No such row in Users")
+			return types.Name{}, fmt.Errorf("user %d: %w", id, types.ErrNotFound)
 		}
+		s.logger.WithError(err).Errorln("Error getting user name")
+		return types.Name{}, err
 	}
 
-	err = connection.SendBatch(ctx, batch).Close()
+	return name, nil
+}
+
+// Ping checks that the database answers
+func (s *Storage) Ping(ctx context.Context) error {
+	err := s.pool.Ping(ctx)
 	if err != nil {
-		s.logger.WithError(err).Errorln("Error sending batch!")
-		return err
+		s.logger.WithError(err).Errorln("Could not ping the db!")
 	}
+	return err
+}
 
-	return nil
+// Close closes the connections to the database
+func (s *Storage) Close() {
+	s.pool.Close()
 }
@@ -1,22 +1,125 @@
 package storage
 
 const (
-	GetUserAllInfoTemplate = `SELECT u.id, u.first_name, u.last_name, u.gender, u.age, u.nationality, 
+	GetUserAllInfoTemplate = `SELECT u.id, u.first_name, u.last_name, COALESCE(u.country_hint, ''), u.gender, u.age, u.nationality,
+		u.age_status, u.gender_status, u.nationality_status, u.version, u.updated_at,
+		d.age_count, d.gender_probability, d.gender_count, d.nationality_count,
+		(SELECT json_agg(json_build_object('country_id', n.country_id, 'probability', n.probability) ORDER BY n.rank)
+			FROM user_nationalities n WHERE n.user_id = u.id) AS countries,
     	ARRAY_AGG(e.email) FILTER (WHERE e.email IS NOT NULL) AS emails 
 	FROM Users u LEFT JOIN Emails e ON u.id = e.user_id 
-	WHERE u.last_name = $1 GROUP BY u.id;`
+		LEFT JOIN user_enrichment d ON u.id = d.user_id
+	WHERE u.last_name = $1 AND u.deleted_at IS NULL GROUP BY u.id, d.user_id;`
+
+	GetUserByIDTemplate = `SELECT u.id, u.first_name, u.last_name, COALESCE(u.country_hint, ''), u.gender, u.age, u.nationality,
+		u.age_status, u.gender_status, u.nationality_status, u.version, u.updated_at,
+		d.age_count, d.gender_probability, d.gender_count, d.nationality_count,
+		(SELECT json_agg(json_build_object('country_id', n.country_id, 'probability', n.probability) ORDER BY n.rank)
+			FROM user_nationalities n WHERE n.user_id = u.id) AS countries,
+    	ARRAY_AGG(e.email) FILTER (WHERE e.email IS NOT NULL) AS emails,
+		(SELECT COUNT(*) FROM Friends f JOIN Users x ON x.id IN (f.id_first_friend, f.id_second_friend) AND x.id <> u.id
+			WHERE u.id IN (f.id_first_friend, f.id_second_friend) AND x.deleted_at IS NULL) AS friend_count
+	FROM Users u LEFT JOIN Emails e ON u.id = e.user_id
+		LEFT JOIN user_enrichment d ON u.id = d.user_id
+	WHERE u.id = $1 AND u.deleted_at IS NULL GROUP BY u.id, d.user_id;`
+
+	// GetUserAsOfTemplate finds the version of the user valid at $2, the current one or one of users_history.
+	// The versions do not overlap, where history written by transaction start times does the latest one wins
+	GetUserAsOfTemplate = `WITH versions AS (
+		SELECT u.* FROM Users u WHERE u.id = $1 AND u.updated_at <= $2
+		UNION ALL
+		SELECT r.* FROM users_history h, jsonb_populate_record(NULL::Users, h.data) r
+		WHERE h.user_id = $1 AND h.valid_from <= $2 AND $2 < h.valid_to
+	)
+	SELECT id, first_name, last_name, COALESCE(country_hint, ''), gender, age, nationality,
+		age_status, gender_status, nationality_status, version, updated_at
+	FROM versions
+	WHERE deleted_at IS NULL OR deleted_at > $2
+	ORDER BY version DESC
+	LIMIT 1;`
+
+	// GetUserEmailsAsOfTemplate and GetUserFriendsAsOfTemplate leave out the users deleted at $2, like the current
+	// emails and friends do: either the current row or the version of users_history valid at $2 was deleted by then
+	GetUserEmailsAsOfTemplate = `SELECT e.email FROM (
+		SELECT email FROM Emails WHERE user_id = $1 AND created_at <= $2
+		UNION ALL
+		SELECT email FROM emails_history WHERE user_id = $1 AND created_at <= $2 AND $2 < deleted_at
+	) e
+	WHERE NOT EXISTS (
+		SELECT 1 FROM Users d WHERE d.id = $1 AND d.deleted_at <= $2
+		UNION ALL
+		SELECT 1 FROM users_history h WHERE h.user_id = $1 AND h.valid_from <= $2 AND $2 < h.valid_to
+			AND (h.data ->> 'deleted_at')::timestamptz <= $2
+	)
+	ORDER BY e.email;`
+
+	GetUserFriendsAsOfTemplate = `SELECT f.friend_id FROM (
+		SELECT CASE WHEN id_first_friend = $1 THEN id_second_friend ELSE id_first_friend END AS friend_id FROM Friends 
+		WHERE $1 IN (id_first_friend, id_second_friend) AND created_at <= $2
+		UNION ALL
+		SELECT CASE WHEN id_first_friend = $1 THEN id_second_friend ELSE id_first_friend END FROM friends_history 
+		WHERE $1 IN (id_first_friend, id_second_friend) AND created_at <= $2 AND $2 < deleted_at
+	) f
+	WHERE NOT EXISTS (
+		SELECT 1 FROM Users d WHERE d.id = f.friend_id AND d.deleted_at <= $2
+		UNION ALL
+		SELECT 1 FROM users_history h WHERE h.user_id = f.friend_id AND h.valid_from <= $2 AND $2 < h.valid_to
+			AND (h.data ->> 'deleted_at')::timestamptz <= $2
+	)
+	ORDER BY f.friend_id;`
+
+	// SearchUsersTemplate matches the folded query against the folded full names by trigram similarity,
+	// by similarity to a word of the name and by words, the best of the three is the score
+	SearchUsersTemplate = `WITH q AS (
+		SELECT people_search_text($1) AS text, plainto_tsquery('simple', people_search_text($1)) AS query
+	)
+	SELECT u.id, u.first_name, u.last_name, COALESCE(u.country_hint, ''), u.gender, u.age, u.nationality,
+		u.age_status, u.gender_status, u.nationality_status, u.version, u.updated_at,
+		d.age_count, d.gender_probability, d.gender_count, d.nationality_count,
+		(SELECT json_agg(json_build_object('country_id', n.country_id, 'probability', n.probability) ORDER BY n.rank)
+			FROM user_nationalities n WHERE n.user_id = u.id) AS countries,
+    	ARRAY_AGG(e.email) FILTER (WHERE e.email IS NOT NULL) AS emails,
+		GREATEST(similarity(u.search_name, q.text), word_similarity(q.text, u.search_name),
+			ts_rank(u.search_vector, q.query))::real AS score
+	FROM q, Users u LEFT JOIN Emails e ON u.id = e.user_id
+		LEFT JOIN user_enrichment d ON u.id = d.user_id
+	WHERE u.deleted_at IS NULL AND (u.search_name % q.text OR q.text <% u.search_name OR u.search_vector @@ q.query)
+	GROUP BY u.id, d.user_id, q.text, q.query
+	ORDER BY score DESC, u.id
+	LIMIT $2;`
 
 	//GetUserAllInfoBySecondNameTemplate = `SELECT u.id, u.first_name, u.last_name, u.gender, u.age, u.nationality,
 	//	ARRAY_AGG(e.email) FILTER (WHERE e.email IS NOT NULL) AS emails
 	//FROM Users u LEFT JOIN Emails e ON u.id = e.user_id
 	//WHERE u.last_name = $1 GROUP BY u.last_name;`
 
-	GetAllUsersTemplate = `SELECT u.id, u.first_name, u.last_name, u.gender, u.age, u.nationality, 
+	// GetAllUsersTemplate is completed by usersSorts: %[1]s is the sort key, %[2]s its type,
+	// %[3]s the keyset comparison and %[4]s the direction. $9 and $10 are the key and id of the cursor,
+	// $11 is the exact last name
+	GetAllUsersTemplate = `SELECT u.id, u.first_name, u.last_name, COALESCE(u.country_hint, ''), u.gender, u.age, u.nationality,
+		u.age_status, u.gender_status, u.nationality_status, u.version, u.updated_at,
+		d.age_count, d.gender_probability, d.gender_count, d.nationality_count,
+		(SELECT json_agg(json_build_object('country_id', n.country_id, 'probability', n.probability) ORDER BY n.rank)
+			FROM user_nationalities n WHERE n.user_id = u.id) AS countries,
     	ARRAY_AGG(e.email) FILTER (WHERE e.email IS NOT NULL) AS emails 
 	FROM Users u LEFT JOIN Emails e ON u.id = e.user_id
-	GROUP BY u.id`
+		LEFT JOIN user_enrichment d ON u.id = d.user_id
+	WHERE u.deleted_at IS NULL
+		AND ($1::text[] IS NULL OR u.nationality = ANY($1))
+		AND ($2::text IS NULL OR u.gender = $2)
+		AND ($3::integer IS NULL OR u.age >= $3)
+		AND ($4::integer IS NULL OR u.age <= $4)
+		AND ($5::text IS NULL OR u.first_name ILIKE $5 || '%%')
+		AND ($6::text IS NULL OR u.last_name ILIKE $6 || '%%')
+		AND ($7::boolean IS NULL OR EXISTS (SELECT 1 FROM Emails x WHERE x.user_id = u.id) = $7)
+		AND ($11::text IS NULL OR u.last_name = $11)
+		AND ($10::integer IS NULL OR (%[1]s, u.id) %[3]s ($9::%[2]s, $10))
+	GROUP BY u.id, d.user_id
+	ORDER BY %[1]s %[4]s, u.id %[4]s
+	LIMIT $8;`
 
-	GetAllUserEmailsTemplate = `SELECT id, user_id, email FROM Emails WHERE user_id = $1;`
+	GetAllUserEmailsTemplate = `SELECT e.id, e.user_id, e.email FROM Emails e JOIN Users u ON u.id = e.user_id 
+	WHERE e.user_id = $1 AND u.deleted_at IS NULL;`
 
 	GetUserFriendsTemplate = `SELECT 
     	u.id AS friend_id,
@@ -26,19 +129,194 @@ const (
 	JOIN Users u ON 
 		(f.id_second_friend = u.id AND f.id_first_friend = $1) OR 
     	(f.id_first_friend = u.id AND f.id_second_friend = $1)
-	WHERE $1 IN (f.id_first_friend, f.id_second_friend);`
+	WHERE $1 IN (f.id_first_friend, f.id_second_friend) AND u.deleted_at IS NULL
+		AND EXISTS (SELECT 1 FROM Users o WHERE o.id = $1 AND o.deleted_at IS NULL);`
+
+	AddUserInfoTemplate = `INSERT INTO Users(first_name, last_name, gender, nationality, age, 
+		age_status, gender_status, nationality_status, enriched_at, country_hint) 
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, '')) RETURNING id;`
+
+	AddUserEnrichmentTemplate = `INSERT INTO user_enrichment(user_id, age_count, gender_probability, gender_count, nationality_count) 
+	VALUES ($1, $2, $3, $4, $5);`
+
+	AddUserNationalityTemplate = `INSERT INTO user_nationalities(user_id, rank, country_id, probability) VALUES ($1, $2, $3, $4);`
+
+	// AddEmailTemplate returns the new email or, when it is already stored, the existing one with its owner
+	// and whether the owner is deleted
+	AddEmailTemplate = `WITH added AS (
+		INSERT INTO Emails(user_id, email) VALUES ($1, $2) ON CONFLICT (email) DO NOTHING RETURNING id, user_id
+	)
+	SELECT id, user_id, true, false FROM added
+	UNION ALL
+	SELECT e.id, e.user_id, false, u.deleted_at IS NOT NULL FROM Emails e JOIN Users u ON u.id = e.user_id
+	WHERE e.email = $2 AND NOT EXISTS (SELECT 1 FROM added);`
+
+	AddFriendshipTemplate = `INSERT INTO Friends(id_first_friend, id_second_friend) VALUES ($1, $2) ON CONFLICT (id_first_friend, id_second_friend) DO NOTHING RETURNING id_first_friend;`
+
+	// UpdateUserInfoTemplate keeps the stored country_hint when $10 is empty, PATCH is the way to clear it
+	UpdateUserInfoTemplate = `UPDATE Users SET first_name = $2, last_name = $3, gender = $4, nationality = $5, age = $6,
+		age_status = $7, gender_status = $8, nationality_status = $9, country_hint = COALESCE(NULLIF($10, ''), country_hint) 
+	WHERE id = $1 
+	RETURNING version;`
+
+	// DeleteUserTemplate only marks the user, PurgeUserTemplate removes it with its emails and friendships
+	DeleteUserTemplate = `UPDATE Users SET deleted_at = clock_timestamp() WHERE id = $1;`
+
+	RestoreUserTemplate = `UPDATE Users SET deleted_at = NULL WHERE id = $1 RETURNING version;`
+
+	PurgeUserTemplate = `DELETE FROM Users WHERE id = $1;`
+
+	// PurgeDeletedUsersTemplate removes at most $2 users deleted before $1, the oldest first
+	PurgeDeletedUsersTemplate = `DELETE FROM Users WHERE id IN (
+		SELECT id FROM Users WHERE deleted_at < $1 ORDER BY deleted_at LIMIT $2
+	) RETURNING id;`
+
+	// the forget templates remove the personal data of purged users from the audit trail and the history,
+	// audit_events only lets a transaction that set people.redact clear before and after
+	ForgetSetRedactTemplate = `SELECT set_config('people.redact', 'on', true);`
+
+	ForgetAuditEventsTemplate = `UPDATE audit_events SET before = NULL, after = NULL, redacted_at = now()
+	WHERE user_ids && $1::integer[] AND redacted_at IS NULL;`
+
+	ForgetUsersHistoryTemplate = `DELETE FROM users_history WHERE user_id = ANY($1::integer[]);`
+
+	ForgetEmailsHistoryTemplate = `DELETE FROM emails_history WHERE user_id = ANY($1::integer[]);`
+
+	ForgetFriendsHistoryTemplate = `DELETE FROM friends_history
+	WHERE id_first_friend = ANY($1::integer[]) OR id_second_friend = ANY($1::integer[]);`
+
+	GetUserDeletedAtForUpdateTemplate = `SELECT deleted_at FROM Users WHERE id = $1 FOR UPDATE;`
+
+	LockUserTemplate = `SELECT id FROM Users WHERE id = $1 AND deleted_at IS NULL FOR KEY SHARE;`
+
+	GetUserVersionForUpdateTemplate = `SELECT version FROM Users WHERE id = $1 AND deleted_at IS NULL FOR UPDATE;`
+
+	GetUserForUpdateTemplate = `SELECT first_name, last_name, COALESCE(country_hint, ''), gender, nationality, age 
+	FROM Users WHERE id = $1 AND deleted_at IS NULL FOR UPDATE;`
+
+	// PatchUserTemplate is completed with the assignments of the patched columns, $1 is the id
+	PatchUserTemplate = `UPDATE Users SET %s WHERE id = $1;`
+
+	// DeleteEmailTemplate leaves the emails of deleted users alone, they come back with a restore
+	DeleteEmailTemplate = `DELETE FROM Emails e USING Users u WHERE e.id = $1 AND u.id = e.user_id AND u.deleted_at IS NULL;`
+
+	// DeleteFriendshipTemplate leaves the friendships of deleted users alone, they come back with a restore
+	DeleteFriendshipTemplate = `DELETE FROM Friends WHERE id_first_friend = $1 AND id_second_friend = $2
+		AND NOT EXISTS (SELECT 1 FROM Users WHERE id IN ($1, $2) AND deleted_at IS NOT NULL);`
+
+	// SetAuditInfoTemplate passes who makes the changes of the transaction to the audit triggers
+	SetAuditInfoTemplate = `SELECT set_config('people.actor', $1, true), set_config('people.actor_source', $2, true),
+		set_config('people.request_id', $3, true);`
+
+	GetAuditEventsTemplate = `SELECT id, occurred_at, actor, actor_source, action, entity, entity_id, user_ids, request_id, before, after, redacted_at 
+	FROM audit_events
+	WHERE ($1::integer IS NULL OR user_ids @> ARRAY[$1::integer])
+		AND ($2::text IS NULL OR actor = $2)
+		AND ($3::text IS NULL OR action = $3)
+		AND ($4::text IS NULL OR entity = $4)
+		AND ($5::text IS NULL OR entity_id = $5)
+		AND ($6::text IS NULL OR request_id = $6)
+		AND ($7::timestamptz IS NULL OR occurred_at >= $7)
+		AND ($8::timestamptz IS NULL OR occurred_at < $8)
+		AND ($9::bigint IS NULL OR id < $9)
+	ORDER BY id DESC
+	LIMIT $10;`
+
+	GetEnrichmentCacheTemplate = `SELECT value, probability, count, payload, not_found, fetched_at FROM enrichment_cache 
+	WHERE name = $1 AND attribute = $2 AND country = $3;`
+
+	SaveEnrichmentCacheTemplate = `INSERT INTO enrichment_cache(name, attribute, country, value, probability, count, payload, not_found, fetched_at) 
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	ON CONFLICT (name, attribute, country) DO UPDATE SET 
+		value = EXCLUDED.value, probability = EXCLUDED.probability, count = EXCLUDED.count, 
+		payload = EXCLUDED.payload, not_found = EXCLUDED.not_found, fetched_at = EXCLUDED.fetched_at;`
+
+	UpsertUserEnrichmentTemplate = `INSERT INTO user_enrichment(user_id, age_count, gender_probability, gender_count, nationality_count) 
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (user_id) DO UPDATE SET 
+		age_count = COALESCE(EXCLUDED.age_count, user_enrichment.age_count),
+		gender_probability = COALESCE(EXCLUDED.gender_probability, user_enrichment.gender_probability),
+		gender_count = COALESCE(EXCLUDED.gender_count, user_enrichment.gender_count),
+		nationality_count = COALESCE(EXCLUDED.nationality_count, user_enrichment.nationality_count);`
+
+	DeleteUserNationalitiesTemplate = `DELETE FROM user_nationalities WHERE user_id = $1;`
+
+	// UpdateUserEnrichmentTemplate sets only the resolved attributes, NULL keeps the stored value
+	UpdateUserEnrichmentTemplate = `UPDATE Users SET 
+		age = COALESCE($2, age), 
+		age_status = CASE WHEN $2::integer IS NULL THEN age_status ELSE 'ok' END,
+		gender = COALESCE($3, gender), 
+		gender_status = CASE WHEN $3::text IS NULL THEN gender_status ELSE 'ok' END,
+		nationality = COALESCE($4, nationality), 
+		nationality_status = CASE WHEN $4::text IS NULL THEN nationality_status ELSE 'ok' END,
+		enriched_at = now()
+	WHERE id = $1;`
+
+	// MarkEnrichmentFailedTemplate gives up on pending attributes, enriched values stay as they are
+	MarkEnrichmentFailedTemplate = `UPDATE Users SET 
+		age_status = CASE WHEN 'age' = ANY($2) AND age_status = 'pending' THEN 'failed' ELSE age_status END,
+		gender_status = CASE WHEN 'gender' = ANY($2) AND gender_status = 'pending' THEN 'failed' ELSE gender_status END,
+		nationality_status = CASE WHEN 'nationality' = ANY($2) AND nationality_status = 'pending' THEN 'failed' 
+			ELSE nationality_status END
+	WHERE id = $1;`
+
+	AddEnrichmentJobTemplate = `INSERT INTO enrichment_jobs(user_id) VALUES ($1) RETURNING id;`
+
+	// ClaimEnrichmentJobTemplate takes the next due job, running jobs whose worker died are taken again after $1
+	// unless they already used up $2 attempts. The jobs of deleted users wait for a restore
+	ClaimEnrichmentJobTemplate = `UPDATE enrichment_jobs j SET status = 'running', attempts = j.attempts + 1, updated_at = now()
+	FROM Users u
+	WHERE u.id = j.user_id AND j.id = (
+		SELECT id FROM enrichment_jobs q
+		WHERE ((status = 'queued' AND run_at <= now()) 
+				OR (status = 'running' AND updated_at < now() - $1::interval AND attempts < $2))
+			AND NOT EXISTS (SELECT 1 FROM Users d WHERE d.id = q.user_id AND d.deleted_at IS NOT NULL)
+		ORDER BY run_at, id
+		FOR UPDATE SKIP LOCKED
+		LIMIT 1
+	)
+	RETURNING j.id, j.user_id, u.first_name, COALESCE(u.country_hint, ''), j.status, j.attempts, j.last_error, j.run_at, j.created_at, j.updated_at;`
 
-	AddUserInfoTemplate = `INSERT INTO Users(first_name, last_name, gender, nationality, age) VALUES ($1, $2, $3, $4, $5) RETURNING id;`
+	// BuryEnrichmentJobsTemplate moves running jobs whose worker died after the last of $2 attempts
+	// to the dead-letter state and gives up on the pending attributes of their users
+	BuryEnrichmentJobsTemplate = `WITH dead AS (
+		UPDATE enrichment_jobs SET status = 'dead', last_error = $3, updated_at = now()
+		WHERE status = 'running' AND updated_at < now() - $1::interval AND attempts >= $2
+		RETURNING user_id
+	)
+	UPDATE Users SET 
+		age_status = CASE WHEN age_status = 'pending' THEN 'failed' ELSE age_status END,
+		gender_status = CASE WHEN gender_status = 'pending' THEN 'failed' ELSE gender_status END,
+		nationality_status = CASE WHEN nationality_status = 'pending' THEN 'failed' ELSE nationality_status END
+	WHERE id IN (SELECT user_id FROM dead) AND 'pending' IN (age_status, gender_status, nationality_status);`
 
-	AddEmailTemplate = `INSERT INTO Emails(user_id, email) VALUES ($1, $2) ON CONFLICT (email) DO NOTHING;`
+	// UpdateEnrichmentJobTemplate also stores the attempts, the worker gives back the attempt of a rate limited round
+	UpdateEnrichmentJobTemplate = `UPDATE enrichment_jobs SET status = $2, last_error = $3, run_at = $4, attempts = $5, updated_at = now()
+	WHERE id = $1;`
 
-	AddFriendshipTemplate = `INSERT INTO Friends(id_first_friend, id_second_friend) VALUES ($1, $2) ON CONFLICT (id_first_friend, id_second_friend) DO NOTHING;`
+	GetEnrichmentStateTemplate = `SELECT u.age_status, u.gender_status, u.nationality_status,
+		j.id, j.status, j.attempts, j.last_error, j.run_at, j.created_at, j.updated_at
+	FROM Users u LEFT JOIN LATERAL (
+		SELECT * FROM enrichment_jobs WHERE user_id = u.id ORDER BY id DESC LIMIT 1
+	) j ON true
+	WHERE u.id = $1 AND u.deleted_at IS NULL;`
 
-	UpdateUserInfoTemplate = `UPDATE Users SET first_name = $2, last_name = $3, gender = $4, nationality = $5, age = $6 WHERE id = $1;`
+	GetUserNameTemplate = `SELECT first_name, last_name, COALESCE(country_hint, '') FROM Users 
+	WHERE id = $1 AND deleted_at IS NULL;`
 
-	DeleteUserTemplate = `DELETE FROM Users WHERE id = $1;`
+	LockUserAttributesTemplate = `SELECT age, gender, nationality FROM Users WHERE id = $1 AND deleted_at IS NULL FOR UPDATE;`
 
-	DeleteEmailTemplate = `DELETE FROM Emails WHERE id = $1;`
+	AddEnrichmentChangeTemplate = `INSERT INTO enrichment_changes(user_id, attribute, old_value, new_value) VALUES ($1, $2, $3, $4);`
 
-	DeleteFriendshipTemplate = `DELETE FROM Friends WHERE id_first_friend = $1 AND id_second_friend = $2;`
+	// EnqueueEnrichmentRefreshTemplate skips users that already have a job in progress, never enriched users go first
+	EnqueueEnrichmentRefreshTemplate = `INSERT INTO enrichment_jobs(user_id)
+	SELECT u.id FROM Users u
+	WHERE u.deleted_at IS NULL
+		AND ($1::timestamptz IS NULL OR u.enriched_at IS NULL OR u.enriched_at < $1)
+		AND (NOT $2 OR u.age IS NULL OR u.gender IS NULL OR u.nationality IS NULL)
+		AND NOT EXISTS (
+			SELECT 1 FROM enrichment_jobs j WHERE j.user_id = u.id AND j.status IN ('queued', 'running')
+		)
+	ORDER BY u.enriched_at NULLS FIRST, u.id
+	LIMIT $3;`
 )
@@ -0,0 +1,499 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	logger "github.com/sirupsen/logrus"
+	"people/internal/types"
+)
+
+// UnitOfWork writes through a single transaction, InTransaction commits all of its writes or none of them
+type UnitOfWork struct {
+	tx     pgx.Tx
+	logger *logger.Entry
+}
+
+// InTransaction runs fn in a transaction that is committed when fn returns nil and rolled back otherwise
+func (s *Storage) InTransaction(ctx context.Context, fn func(uow *UnitOfWork) error) error {
+	connection, err := s.pool.Acquire(ctx)
+	if err != nil {
+		s.logger.WithError(err).Errorln("Error whole acquiring connection from the database pool!")
+		return err
+	}
+
+	defer connection.Release()
+
+	tx, err := connection.Begin(ctx)
+	if err != nil {
+		s.logger.WithError(err).Errorln("Error starting transaction")
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	err = setAuditInfo(ctx, tx)
+	if err != nil {
+		s.logger.WithError(err).Errorln("Error setting audit info")
+		return err
+	}
+
+	err = fn(&UnitOfWork{tx: tx, logger: s.logger})
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		s.logger.WithError(err).Errorln("Error committing transaction")
+		return err
+	}
+
+	return nil
+}
+
+// setAuditInfo hands the audit info of ctx to the audit triggers of the transaction
+func setAuditInfo(ctx context.Context, tx pgx.Tx) error {
+	info := types.AuditInfoFrom(ctx)
+	if info == (types.AuditInfo{}) {
+		return nil
+	}
+
+	_, err := tx.Exec(ctx, SetAuditInfoTemplate, info.Actor, info.ActorSource, info.RequestID)
+	return err
+}
+
+// CreateUser adds the user, enrichedAt is nil while the enrichment is still queued
+func (u *UnitOfWork) CreateUser(ctx context.Context, user types.User, enrichedAt *time.Time) (uint64, error) {
+	status := user.EnrichmentStatus()
+
+	var id uint64
+	err := u.tx.QueryRow(
+		ctx,
+		AddUserInfoTemplate,
+		user.FirstName,
+		user.LastName,
+		user.Gender,
+		user.Nationality,
+		user.Age,
+		status.Age,
+		status.Gender,
+		status.Nationality,
+		enrichedAt,
+		user.CountryHint,
+	).Scan(&id)
+	if err != nil {
+		u.logger.WithError(err).Errorln("Failed to add user")
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// AddEnrichmentDetails stores the counts, the probabilities and the ranked countries of a new user
+func (u *UnitOfWork) AddEnrichmentDetails(ctx context.Context, id uint64, details types.EnrichmentDetails) error {
+	batch := &pgx.Batch{}
+	batch.Queue(
+		AddUserEnrichmentTemplate,
+		id,
+		details.AgeCount,
+		details.GenderProbability,
+		details.GenderCount,
+		details.NationalityCount,
+	)
+	for rank, country := range details.Countries {
+		batch.Queue(
+			AddUserNationalityTemplate,
+			id,
+			rank+1,
+			country.ID,
+			country.Probability,
+		)
+	}
+
+	err := u.tx.SendBatch(ctx, batch).Close()
+	if err != nil {
+		u.logger.WithError(err).Errorln("Failed to add user enrichment details")
+		return err
+	}
+
+	return nil
+}
+
+// EnqueueEnrichment queues the enrichment job of a new user
+func (u *UnitOfWork) EnqueueEnrichment(ctx context.Context, userID uint64) (uint64, error) {
+	var jobID uint64
+	err := u.tx.QueryRow(ctx, AddEnrichmentJobTemplate, userID).Scan(&jobID)
+	if err != nil {
+		u.logger.WithError(err).Errorln("Failed to add enrichment job")
+		return 0, err
+	}
+
+	return jobID, nil
+}
+
+// AddEmails adds emails to the user and reports every one of them: created, duplicate when the user already
+// has it, conflict when another user has it, deleted or not, invalid when it is not an email address
+func (u *UnitOfWork) AddEmails(ctx context.Context, userID uint64, emails []string) ([]types.ItemResult, error) {
+	err := u.lockUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(emails))
+	return u.eachItem(ctx, emails, func(tx pgx.Tx, i int) (types.ItemResult, error) {
+		email := strings.TrimSpace(emails[i])
+		if !isEmail(email) {
+			return types.ItemResult{Status: types.ItemInvalid, Message: "not an email address"}, nil
+		}
+		if seen[email] {
+			return types.ItemResult{Status: types.ItemDuplicate, Message: "repeated in the request"}, nil
+		}
+		seen[email] = true
+
+		var id, ownerID uint64
+		var created, ownerDeleted bool
+		err := tx.QueryRow(ctx, AddEmailTemplate, userID, email).Scan(&id, &ownerID, &created, &ownerDeleted)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				// another transaction added it and has not committed yet
+				return types.ItemResult{Status: types.ItemConflict, Message: "being added concurrently"}, nil
+			}
+			return types.ItemResult{}, err
+		}
+
+		switch {
+		case created:
+			return types.ItemResult{Status: types.ItemCreated, ID: id}, nil
+		case ownerID == userID:
+			return types.ItemResult{Status: types.ItemDuplicate, ID: id}, nil
+		case ownerDeleted:
+			// the email comes back with a restore of its owner, it is free once the owner is purged
+			return types.ItemResult{Status: types.ItemConflict, Message: "owned by a deleted user until it is purged"}, nil
+		default:
+			return types.ItemResult{Status: types.ItemConflict, Message: "owned by another user"}, nil
+		}
+	})
+}
+
+// AddFriends befriends the user with every one of friendIDs and reports every one of them: created, duplicate
+// when they are friends already, not_found when there is no such user, invalid for the user itself
+func (u *UnitOfWork) AddFriends(ctx context.Context, userID uint64, friendIDs []uint64) ([]types.ItemResult, error) {
+	err := u.lockUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return u.eachItem(ctx, idStrings(friendIDs), func(tx pgx.Tx, i int) (types.ItemResult, error) {
+		friend := friendIDs[i]
+		if friend == userID {
+			return types.ItemResult{Status: types.ItemInvalid, Message: "a user can not befriend itself"}, nil
+		}
+
+		var active uint64
+		err := tx.QueryRow(ctx, LockUserTemplate, friend).Scan(&active)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return types.ItemResult{Status: types.ItemNotFound}, nil
+			}
+			return types.ItemResult{}, err
+		}
+
+		first, second := friendshipKey(userID, friend)
+		var added uint64
+		err = tx.QueryRow(ctx, AddFriendshipTemplate, first, second).Scan(&added)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return types.ItemResult{Status: types.ItemDuplicate}, nil
+			}
+			return types.ItemResult{}, err
+		}
+		return types.ItemResult{Status: types.ItemCreated}, nil
+	})
+}
+
+// DeleteEmails deletes emails by their ids and reports every one of them: deleted or not_found,
+// the emails of deleted users are not_found
+func (u *UnitOfWork) DeleteEmails(ctx context.Context, ids []uint64) ([]types.ItemResult, error) {
+	return u.eachItem(ctx, idStrings(ids), func(tx pgx.Tx, i int) (types.ItemResult, error) {
+		commandTag, err := tx.Exec(ctx, DeleteEmailTemplate, ids[i])
+		if err != nil {
+			return types.ItemResult{}, err
+		}
+		if commandTag.RowsAffected() == 0 {
+			return types.ItemResult{Status: types.ItemNotFound}, nil
+		}
+		return types.ItemResult{Status: types.ItemDeleted}, nil
+	})
+}
+
+// DeleteFriendships ends the friendships of the pairs and reports every one of them: deleted, not_found
+// when they are not friends or one of them is deleted, invalid when both ids are the same
+func (u *UnitOfWork) DeleteFriendships(ctx context.Context, pairs []types.Friendship) ([]types.ItemResult, error) {
+	items := make([]string, 0, len(pairs))
+	for _, pair := range pairs {
+		items = append(items, fmt.Sprintf("%d-%d", pair.IDFirstUser, pair.IDSecondUser))
+	}
+
+	return u.eachItem(ctx, items, func(tx pgx.Tx, i int) (types.ItemResult, error) {
+		if pairs[i].IDFirstUser == pairs[i].IDSecondUser {
+			return types.ItemResult{Status: types.ItemInvalid, Message: "a user can not befriend itself"}, nil
+		}
+
+		first, second := friendshipKey(pairs[i].IDFirstUser, pairs[i].IDSecondUser)
+		commandTag, err := tx.Exec(ctx, DeleteFriendshipTemplate, first, second)
+		if err != nil {
+			return types.ItemResult{}, err
+		}
+		if commandTag.RowsAffected() == 0 {
+			return types.ItemResult{Status: types.ItemNotFound}, nil
+		}
+		return types.ItemResult{Status: types.ItemDeleted}, nil
+	})
+}
+
+// deletedAt locks the user until the transaction ends and returns when it was deleted, nil for an active user
+func (u *UnitOfWork) deletedAt(ctx context.Context, id uint64) (*time.Time, error) {
+	var deletedAt *time.Time
+	err := u.tx.QueryRow(ctx, GetUserDeletedAtForUpdateTemplate, id).Scan(&deletedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			u.logger.WithError(err).Errorln("No such row in Users")
+			return nil, fmt.Errorf("user %d: %w", id, types.ErrNotFound)
+		}
+		u.logger.WithError(err).Errorln("Error getting user")
+		return nil, err
+	}
+
+	return deletedAt, nil
+}
+
+// forgetUsers redacts the audit events of purged users and removes their history, it runs after the purge
+// whose own events and history rows it takes along
+func (u *UnitOfWork) forgetUsers(ctx context.Context, ids []uint64) error {
+	batch := &pgx.Batch{}
+	batch.Queue(ForgetSetRedactTemplate)
+	batch.Queue(ForgetAuditEventsTemplate, ids)
+	batch.Queue(ForgetUsersHistoryTemplate, ids)
+	batch.Queue(ForgetEmailsHistoryTemplate, ids)
+	batch.Queue(ForgetFriendsHistoryTemplate, ids)
+
+	err := u.tx.SendBatch(ctx, batch).Close()
+	if err != nil {
+		u.logger.WithError(err).Errorln("Failed to forget purged users")
+		return err
+	}
+
+	return nil
+}
+
+// MatchVersion locks the user until the transaction ends and checks its version against ifMatch
+func (u *UnitOfWork) MatchVersion(ctx context.Context, id uint64, ifMatch types.IfMatch) error {
+	var version uint64
+	err := u.tx.QueryRow(ctx, GetUserVersionForUpdateTemplate, id).Scan(&version)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			u.logger.WithError(err).Errorln("No such row in Users")
+			return fmt.Errorf("user %d: %w", id, types.ErrNotFound)
+		}
+		u.logger.WithError(err).Errorln("Error getting user version")
+		return err
+	}
+
+	if !ifMatch.Matches(version) {
+		err = fmt.Errorf("user %d is at version %d: %w", id, version, types.ErrPreconditionFailed)
+		u.logger.WithError(err).Errorln("Stale user version")
+		return err
+	}
+
+	return nil
+}
+
+// GetUserForUpdate reads the user and locks it until the transaction ends
+func (u *UnitOfWork) GetUserForUpdate(ctx context.Context, id uint64) (types.User, error) {
+	var user types.User
+	err := u.tx.QueryRow(ctx, GetUserForUpdateTemplate, id).Scan(
+		&user.FirstName,
+		&user.LastName,
+		&user.CountryHint,
+		&user.Gender,
+		&user.Nationality,
+		&user.Age,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			u.logger.WithError(err).Errorln("No such row in Users")
+			return types.User{}, fmt.Errorf("user %d: %w", id, types.ErrNotFound)
+		}
+		u.logger.WithError(err).Errorln("Error getting user")
+		return types.User{}, err
+	}
+
+	return user, nil
+}
+
+// userColumns are the patchable columns of Users, the attributes set their status along
+var userColumns = map[string]func(user types.User, status types.EnrichmentStatus) []any{
+	"first_name":   func(user types.User, _ types.EnrichmentStatus) []any { return []any{user.FirstName} },
+	"last_name":    func(user types.User, _ types.EnrichmentStatus) []any { return []any{user.LastName} },
+	"country_hint": func(user types.User, _ types.EnrichmentStatus) []any { return []any{nullIfEmpty(user.CountryHint)} },
+	"gender": func(user types.User, status types.EnrichmentStatus) []any {
+		return []any{user.Gender, status.Gender}
+	},
+	"nationality": func(user types.User, status types.EnrichmentStatus) []any {
+		return []any{user.Nationality, status.Nationality}
+	},
+	"age": func(user types.User, status types.EnrichmentStatus) []any {
+		return []any{user.Age, status.Age}
+	},
+}
+
+// PatchUser writes only the given columns of the user
+func (u *UnitOfWork) PatchUser(ctx context.Context, id uint64, user types.User, columns []string) error {
+	if len(columns) == 0 {
+		return nil
+	}
+
+	status := user.EnrichmentStatus()
+	assignments := make([]string, 0, len(columns))
+	args := []any{id}
+	for _, column := range columns {
+		values, ok := userColumns[column]
+		if !ok {
+			return fmt.Errorf("column %q can not be patched", column)
+		}
+
+		for i, value := range values(user, status) {
+			name := column
+			if i == 1 {
+				name = column + "_status"
+			}
+			args = append(args, value)
+			assignments = append(assignments, fmt.Sprintf("%s = $%d", name, len(args)))
+		}
+	}
+
+	_, err := u.tx.Exec(ctx, fmt.Sprintf(PatchUserTemplate, strings.Join(assignments, ", ")), args...)
+	if err != nil {
+		u.logger.WithError(err).Errorln("Failed to patch user")
+		return err
+	}
+
+	return nil
+}
+
+func nullIfEmpty(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+// lockUser keeps the user from being deleted until the transaction ends, types.ErrNotFound when there is none
+func (u *UnitOfWork) lockUser(ctx context.Context, id uint64) error {
+	var lockedID uint64
+	err := u.tx.QueryRow(ctx, LockUserTemplate, id).Scan(&lockedID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			u.logger.WithError(err).Errorln("No such row in Users")
+			return fmt.Errorf("user %d: %w", id, types.ErrNotFound)
+		}
+		u.logger.WithError(err).Errorln("Error locking user")
+		return err
+	}
+
+	return nil
+}
+
+// eachItem runs write for every item under its own savepoint, so that a failed item neither aborts the
+// transaction nor leaves partial writes behind and the items after it are still reported. Database errors
+// of an item become its result, other errors abort the batch. Callers reject the whole transaction when
+// an item is not OK, see types.RejectItems
+func (u *UnitOfWork) eachItem(
+	ctx context.Context,
+	items []string,
+	write func(tx pgx.Tx, i int) (types.ItemResult, error),
+) ([]types.ItemResult, error) {
+	results := make([]types.ItemResult, 0, len(items))
+
+	for i, item := range items {
+		savepoint, err := u.tx.Begin(ctx)
+		if err != nil {
+			u.logger.WithError(err).Errorln("Error creating savepoint")
+			return nil, err
+		}
+
+		result, err := write(savepoint, i)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if !errors.As(err, &pgErr) {
+				_ = savepoint.Rollback(ctx)
+				u.logger.WithError(err).Errorln("Error writing batch item")
+				return nil, err
+			}
+			result = itemResult(pgErr)
+		}
+
+		if result.OK() {
+			err = savepoint.Commit(ctx)
+		} else {
+			err = savepoint.Rollback(ctx)
+		}
+		if err != nil {
+			u.logger.WithError(err).Errorln("Error releasing savepoint")
+			return nil, err
+		}
+
+		result.Index = i
+		result.Item = item
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// itemResult explains why the database rejected an item
+func itemResult(err *pgconn.PgError) types.ItemResult {
+	switch err.Code {
+	case foreignKeyViolation:
+		return types.ItemResult{Status: types.ItemNotFound, Message: "references a user that does not exist"}
+	case uniqueViolation:
+		return types.ItemResult{Status: types.ItemConflict, Message: "already exists"}
+	default:
+		return types.ItemResult{Status: types.ItemInvalid, Message: err.Message}
+	}
+}
+
+const (
+	foreignKeyViolation = "23503"
+	uniqueViolation     = "23505"
+)
+
+// friendshipKey orders a pair of ids ascending, since the pairs [1, 2] and [2, 1] are the same friendship
+func friendshipKey(a, b uint64) (uint64, uint64) {
+	if a < b {
+		return a, b
+	}
+	return b, a
+}
+
+// isEmail accepts a bare address like ann@example.com
+func isEmail(email string) bool {
+	address, err := mail.ParseAddress(email)
+	return err == nil && address.Address == email
+}
+
+func idStrings(ids []uint64) []string {
+	items := make([]string, 0, len(ids))
+	for _, id := range ids {
+		items = append(items, strconv.FormatUint(id, 10))
+	}
+	return items
+}
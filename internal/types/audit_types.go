@@ -0,0 +1,92 @@
+package types
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+const DefaultAuditLimit = 50
+
+// The sources of the actor of an audit event, changes made outside of the service are recorded as system
+const (
+	// ActorSourceHeader is an actor taken from the X-Actor header, the caller says who it is and nobody checks it
+	ActorSourceHeader = "header"
+	// ActorSourceAnonymous is a request without X-Actor
+	ActorSourceAnonymous = "anonymous"
+	// ActorSourceService is a background job of the service, such as the enrichment workers and the retention
+	ActorSourceService = "service"
+)
+
+// AuditInfo tells who makes the changes of a request, it is recorded with every audit event of the request
+type AuditInfo struct {
+	Actor       string
+	ActorSource string
+	RequestID   string
+}
+
+type auditInfoKey struct{}
+
+// WithAuditInfo returns a context carrying the audit info
+func WithAuditInfo(ctx context.Context, info AuditInfo) context.Context {
+	return context.WithValue(ctx, auditInfoKey{}, info)
+}
+
+// AuditInfoFrom returns the audit info of the context, it is empty outside of requests
+func AuditInfoFrom(ctx context.Context) AuditInfo {
+	info, _ := ctx.Value(auditInfoKey{}).(AuditInfo)
+	return info
+}
+
+// AuditEvent is a change of a user, an email or a friendship. Before and After hold the whole row when it was
+// created or deleted and only the changed columns when it was updated
+type AuditEvent struct {
+	ID         uint64    `json:"id"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Actor      string    `json:"actor" example:"alice"`
+	// ActorSource tells where Actor comes from, an actor from the header is whatever the caller claimed
+	ActorSource string          `json:"actor_source" enums:"header,anonymous,service,system,unknown" example:"header"`
+	Action      string          `json:"action" example:"user.update"`
+	Entity      string          `json:"entity" example:"user"`
+	EntityID    string          `json:"entity_id" example:"42"`
+	UserIDs     []uint64        `json:"user_ids"`
+	RequestID   *string         `json:"request_id,omitempty"`
+	Before      json.RawMessage `json:"before,omitempty" swaggertype:"object"`
+	After       json.RawMessage `json:"after,omitempty" swaggertype:"object"`
+	// RedactedAt is when a purge removed before and after of an event of the purged user
+	RedactedAt *time.Time `json:"redacted_at,omitempty"`
+}
+
+// ListAuditRequest is the query of GET /audit and GET /users/:id/history
+type ListAuditRequest struct {
+	Limit     int        `form:"limit" validate:"omitempty,min=1,max=500" example:"50"`
+	Cursor    string     `form:"cursor" validate:"omitempty,max=20"`
+	Actor     string     `form:"actor" validate:"omitempty,max=200"`
+	Action    string     `form:"action" validate:"omitempty,max=100" example:"user.update"`
+	Entity    string     `form:"entity" validate:"omitempty,oneof=user email friendship"`
+	EntityID  string     `form:"entity_id" validate:"omitempty,max=100"`
+	RequestID string     `form:"request_id" validate:"omitempty,max=200"`
+	From      *time.Time `form:"from"`
+	To        *time.Time `form:"to"`
+}
+
+// AuditFilter selects audit events, nil fields do not filter. Events are listed newest first,
+// Before is the id of the last event of the previous page
+type AuditFilter struct {
+	UserID    *uint64
+	Actor     *string
+	Action    *string
+	Entity    *string
+	EntityID  *string
+	RequestID *string
+	From      *time.Time
+	To        *time.Time
+	Before    *uint64
+	Limit     int
+}
+
+// AuditPage is a page of audit events, NextCursor is empty on the last page
+type AuditPage struct {
+	Events     []AuditEvent `json:"events"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+}
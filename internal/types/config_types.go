@@ -1,9 +1,13 @@
 package types
 
+import "time"
+
 type Config struct {
 	Server     ServerConfig
 	Database   DatabaseConfig
 	Enrichment EnrichmentUrlsConfig
+	Queue      QueueConfig
+	Retention  RetentionConfig
 }
 
 type ServerConfig struct {
@@ -21,7 +25,92 @@ type DatabaseConfig struct {
 }
 
 type EnrichmentUrlsConfig struct {
+	// Provider selects a registered enrichment provider: "api" (default) or "local"
+	Provider       string
 	AgeUrl         string
 	GenderUrl      string
 	NationalityUrl string
+	// LocalFile is an optional CSV table for the "local" provider
+	LocalFile string
+	// Mode is "sync" (default) or "async", see EnrichmentModeAsync
+	Mode string
+	// Policy is "strict" (default) or "best-effort", see EnrichmentPolicyStrict
+	Policy string
+	// per-call deadlines of each lookup, e.g. "3s"
+	AgeTimeout         time.Duration
+	GenderTimeout      time.Duration
+	NationalityTimeout time.Duration
+	// CountryHint is the default country_id of age and gender lookups for requests without a country hint
+	CountryHint string
+	// TopCountries is the number of nationality candidates stored per user
+	TopCountries int
+	// CacheTTL enables the enrichment cache, results older than it are fetched again
+	CacheTTL time.Duration
+	// CacheSize is the number of entries of the in-process LRU in front of the cache table
+	CacheSize int
+	// API keys of the paid tiers, usually set by the AGIFY_API_KEY, GENDERIZE_API_KEY and NATIONALIZE_API_KEY
+	// environment variables; the *ApiKeyFile fields read them from secret files instead
+	AgeApiKey             string
+	GenderApiKey          string
+	NationalityApiKey     string
+	AgeApiKeyFile         string
+	GenderApiKeyFile      string
+	NationalityApiKeyFile string
+	// HTTP configures the client used by the "api" provider
+	HTTP HTTPClientConfig
+	// Breaker short-circuits the lookups of an attribute while its provider keeps failing
+	Breaker BreakerConfig
+}
+
+type BreakerConfig struct {
+	// FailureThreshold is the number of consecutive failures that opens the circuit
+	FailureThreshold int
+	// OpenTimeout is how long lookups are rejected before the provider is probed again
+	OpenTimeout time.Duration
+	// HalfOpenRequests is the number of successful probes that closes the circuit
+	HalfOpenRequests int
+}
+
+// HTTPClientConfig configures the outbound HTTP client of the enrichment providers
+type HTTPClientConfig struct {
+	// Proxy is the URL of the outbound proxy, HTTP_PROXY/HTTPS_PROXY/NO_PROXY are used when empty
+	Proxy string
+	// CAFile is a PEM bundle trusted in addition to the system roots
+	CAFile string
+	// CertFile and KeyFile are the client certificate for mutual TLS
+	CertFile string
+	KeyFile  string
+	// InsecureSkipVerify disables the server certificate verification, for debugging only
+	InsecureSkipVerify bool
+	// Timeout bounds a whole request including the body, the per-lookup deadlines still apply
+	Timeout             time.Duration
+	DialTimeout         time.Duration
+	TLSHandshakeTimeout time.Duration
+	IdleConnTimeout     time.Duration
+	MaxIdleConnsPerHost int
+}
+
+// QueueConfig tunes the workers of the enrichment job queue
+type QueueConfig struct {
+	// Workers is the number of goroutines processing jobs, 0 disables processing
+	Workers int
+	// MaxAttempts moves a job to the dead state once reached
+	MaxAttempts int
+	// RetryBackoff is the delay before the first retry, it doubles on every attempt up to MaxRetryBackoff
+	RetryBackoff    time.Duration
+	MaxRetryBackoff time.Duration
+	// PollInterval is how often idle workers look for new jobs
+	PollInterval time.Duration
+	// JobTimeout bounds a single attempt, running jobs older than it are picked up again
+	JobTimeout time.Duration
+}
+
+// RetentionConfig tunes the purge of deleted users
+type RetentionConfig struct {
+	// DeletedUsers is how long a deleted user can still be restored, 0 keeps deleted users forever
+	DeletedUsers time.Duration
+	// Interval is how often the deleted users are purged
+	Interval time.Duration
+	// BatchSize bounds the users removed by a single statement
+	BatchSize int
 }
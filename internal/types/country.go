@@ -0,0 +1,59 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/biter777/countries"
+)
+
+var ErrInvalidCountry = errors.New("Invalid country")
+
+// CountryInfo is derived from the stored ISO 3166-1 alpha-2 nationality
+type CountryInfo struct {
+	Alpha2 string `json:"alpha2" example:"DE"`
+	Alpha3 string `json:"alpha3" example:"DEU"`
+	Name   string `json:"name" example:"Germany"`
+	Region string `json:"region" example:"Europe"`
+}
+
+// NormalizeCountry returns the ISO 3166-1 alpha-2 code of an alpha-2 or alpha-3 code or an English country name
+func NormalizeCountry(country string) (string, error) {
+	code := countries.ByName(strings.TrimSpace(country))
+	if !code.IsValid() {
+		return "", fmt.Errorf("%q: %w", country, ErrInvalidCountry)
+	}
+	return code.Alpha2(), nil
+}
+
+// CountryOf describes the country of an alpha-2 code, nil when the code is unknown
+func CountryOf(alpha2 string) *CountryInfo {
+	code := countries.ByName(alpha2)
+	if !code.IsValid() {
+		return nil
+	}
+	return &CountryInfo{
+		Alpha2: code.Alpha2(),
+		Alpha3: code.Alpha3(),
+		Name:   code.String(),
+		Region: code.Region().String(),
+	}
+}
+
+// RegionCountries returns the alpha-2 codes of the countries in a region given by its name or code,
+// e.g. "Europe" or "EU"
+func RegionCountries(region string) ([]string, error) {
+	regionCode := countries.RegionCodeByName(region)
+	if regionCode == countries.RegionUnknown || regionCode == countries.RegionNone {
+		return nil, fmt.Errorf("region %q: %w", region, ErrInvalidCountry)
+	}
+
+	var codes []string
+	for _, code := range countries.All() {
+		if code.Region() == regionCode {
+			codes = append(codes, code.Alpha2())
+		}
+	}
+	return codes, nil
+}
@@ -1,6 +1,88 @@
 package types
 
-const NameParam = "/?name="
+import (
+	"strconv"
+	"time"
+)
+
+// NameParam is the query parameter of single lookups
+const NameParam = "name"
+
+// CountryParam localizes age and gender lookups, nationalize does not support it
+const CountryParam = "country_id"
+
+// ApiKeyParam carries the API key of the paid tiers
+const ApiKeyParam = "apikey"
+
+// DefaultTopCountries is the number of nationality candidates stored when config.yml does not set one
+const DefaultTopCountries = 3
+
+// BatchNameParam is repeated once per name in batch requests
+const BatchNameParam = "name[]"
+
+const (
+	// EnrichmentPolicyStrict fails the user creation when any lookup fails
+	EnrichmentPolicyStrict = "strict"
+	// EnrichmentPolicyBestEffort stores the user with the attributes that were resolved
+	EnrichmentPolicyBestEffort = "best-effort"
+)
+
+const (
+	// EnrichmentModeSync enriches users inside POST /users
+	EnrichmentModeSync = "sync"
+	// EnrichmentModeAsync stores users right away and enriches them by queued jobs
+	EnrichmentModeAsync = "async"
+)
+
+const (
+	EnrichmentStatusOK      = "ok"
+	EnrichmentStatusPending = "pending"
+	// EnrichmentStatusFailed is set when no value exists for the name or the job ran out of attempts
+	EnrichmentStatusFailed = "failed"
+)
+
+const (
+	JobStatusQueued  = "queued"
+	JobStatusRunning = "running"
+	JobStatusDone    = "done"
+	// JobStatusDead is the dead-letter state of a job that ran out of attempts
+	JobStatusDead = "dead"
+)
+
+const (
+	AttributeAge         = "age"
+	AttributeGender      = "gender"
+	AttributeNationality = "nationality"
+)
+
+// EnrichmentStatus holds the enrichment status of every user attribute
+type EnrichmentStatus struct {
+	Age         string `json:"age" example:"ok"`
+	Gender      string `json:"gender" example:"ok"`
+	Nationality string `json:"nationality" example:"pending"`
+}
+
+// Pending returns the names of attributes that are not enriched yet
+func (s EnrichmentStatus) Pending() []string {
+	var pending []string
+	if s.Age == EnrichmentStatusPending {
+		pending = append(pending, AttributeAge)
+	}
+	if s.Gender == EnrichmentStatusPending {
+		pending = append(pending, AttributeGender)
+	}
+	if s.Nationality == EnrichmentStatusPending {
+		pending = append(pending, AttributeNationality)
+	}
+	return pending
+}
+
+func statusOf(resolved bool) string {
+	if resolved {
+		return EnrichmentStatusOK
+	}
+	return EnrichmentStatusPending
+}
 
 type AgeData struct {
 	Count uint64
@@ -25,3 +107,143 @@ type CountryData struct {
 	ID          string  `json:"country_id"`
 	Probability float32 `json:"probability"`
 }
+
+// EnrichmentCacheEntry is a cached lookup result of one attribute for a first name
+type EnrichmentCacheEntry struct {
+	Name      string
+	Attribute string
+	// Country is the country hint of the lookup, empty for lookups without one
+	Country     string
+	Value       string
+	Probability *float32
+	Count       *uint64
+	// Payload is the whole upstream answer as JSON, empty when NotFound is set
+	Payload []byte
+	// NotFound marks a name the upstream has no data for
+	NotFound  bool
+	FetchedAt time.Time
+}
+
+// EnrichmentDetails are the statistics behind the enriched attributes,
+// consumers apply their own confidence thresholds to them
+type EnrichmentDetails struct {
+	AgeCount          *uint64       `json:"age_count" example:"2231"`
+	GenderProbability *float32      `json:"gender_probability" example:"0.99"`
+	GenderCount       *uint64       `json:"gender_count" example:"25000"`
+	NationalityCount  *uint64       `json:"nationality_count" example:"18000"`
+	Countries         []CountryData `json:"countries"`
+}
+
+// IsZero reports whether no statistics are known, e.g. for users created before they were stored
+func (d EnrichmentDetails) IsZero() bool {
+	return d.AgeCount == nil && d.GenderProbability == nil && d.GenderCount == nil &&
+		d.NationalityCount == nil && len(d.Countries) == 0
+}
+
+// EnrichmentJob is an entry of the enrichment_jobs queue
+type EnrichmentJob struct {
+	ID          uint64    `json:"id"`
+	UserID      uint64    `json:"user_id"`
+	FirstName   string    `json:"-"`
+	CountryHint string    `json:"-"`
+	Status      string    `json:"status" example:"queued"`
+	Attempts    int       `json:"attempts"`
+	LastError   *string   `json:"last_error"`
+	RunAt       time.Time `json:"run_at"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// EnrichmentState is the enrichment progress of a user with its latest job
+type EnrichmentState struct {
+	UserID           uint64           `json:"user_id"`
+	EnrichmentStatus EnrichmentStatus `json:"enrichment_status"`
+	Job              *EnrichmentJob   `json:"job,omitempty"`
+}
+
+// EnrichmentChange is an attribute changed by re-enrichment, values are text
+type EnrichmentChange struct {
+	Attribute string  `json:"attribute" example:"age"`
+	OldValue  *string `json:"old_value" example:"41"`
+	NewValue  *string `json:"new_value" example:"42"`
+}
+
+// EnrichmentChanges compares the attributes resolved in after with before, nil attributes of after are skipped
+func EnrichmentChanges(before, after User) []EnrichmentChange {
+	var changes []EnrichmentChange
+
+	if after.Age != nil && (before.Age == nil || *before.Age != *after.Age) {
+		changes = append(changes, EnrichmentChange{
+			Attribute: AttributeAge,
+			OldValue:  ageText(before.Age),
+			NewValue:  ageText(after.Age),
+		})
+	}
+	if after.Gender != nil && (before.Gender == nil || *before.Gender != *after.Gender) {
+		changes = append(changes, EnrichmentChange{
+			Attribute: AttributeGender,
+			OldValue:  before.Gender,
+			NewValue:  after.Gender,
+		})
+	}
+	if after.Nationality != nil && (before.Nationality == nil || *before.Nationality != *after.Nationality) {
+		changes = append(changes, EnrichmentChange{
+			Attribute: AttributeNationality,
+			OldValue:  before.Nationality,
+			NewValue:  after.Nationality,
+		})
+	}
+
+	return changes
+}
+
+func ageText(age *uint8) *string {
+	if age == nil {
+		return nil
+	}
+	text := strconv.FormatUint(uint64(*age), 10)
+	return &text
+}
+
+// DefaultRefreshLimit bounds a bulk re-enrichment without an explicit limit
+const DefaultRefreshLimit = 1000
+
+// RefreshFilter selects the users of a bulk re-enrichment
+type RefreshFilter struct {
+	// EnrichedBefore selects users enriched before it or never, zero selects everybody
+	EnrichedBefore time.Time
+	// MissingOnly selects users with at least one attribute missing
+	MissingOnly bool
+	Limit       int
+}
+
+// QuotaState is the last known rate limit of an enrichment upstream, nil fields are unknown
+type QuotaState struct {
+	Provider     string     `json:"provider" example:"age"`
+	Limit        *int       `json:"limit" example:"1000"`
+	Remaining    *int       `json:"remaining" example:"998"`
+	ResetAt      *time.Time `json:"reset_at"`
+	BlockedUntil *time.Time `json:"blocked_until,omitempty"`
+}
+
+const (
+	BreakerClosed   = "closed"
+	BreakerHalfOpen = "half-open"
+	BreakerOpen     = "open"
+)
+
+// BreakerState is the circuit breaker of an enrichment upstream with its counters since the start
+type BreakerState struct {
+	Provider            string     `json:"provider" example:"gender"`
+	State               string     `json:"state" example:"closed"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+	Requests            uint64     `json:"requests"`
+	Failures            uint64     `json:"failures"`
+	Rejected            uint64     `json:"rejected"`
+}
+
+type ProvidersStatus struct {
+	Quotas   []QuotaState   `json:"quotas"`
+	Breakers []BreakerState `json:"breakers"`
+}
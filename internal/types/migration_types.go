@@ -0,0 +1,25 @@
+package types
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrMigrationChecksum means an applied migration was edited after it ran
+	ErrMigrationChecksum = errors.New("migration checksum mismatch")
+	// ErrUnknownMigration means the database has a migration this build does not know, it is newer than the build
+	ErrUnknownMigration = errors.New("unknown migration")
+)
+
+// MigrationStatus is a migration known to the build or applied to the database
+type MigrationStatus struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+	// Modified is set for an applied migration whose file no longer matches the recorded checksum
+	Modified bool `json:"modified"`
+	// Missing is set for an applied migration without a file in the build
+	Missing bool `json:"missing"`
+}
@@ -0,0 +1,81 @@
+package types
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultUsersLimit  = 50
+	DefaultUsersSort   = "id"
+	DefaultSearchLimit = 20
+)
+
+var (
+	ErrInvalidCursor = errors.New("Invalid cursor")
+	ErrInvalidFilter = errors.New("Invalid filter")
+)
+
+// UsersCursor is the position behind the last user of a page: its sort key and id
+type UsersCursor struct {
+	Sort string `json:"s"`
+	Key  string `json:"k"`
+	ID   uint64 `json:"i"`
+}
+
+// UsersPage is a page of GET /users, NextCursor is empty on the last page
+type UsersPage struct {
+	Users      []UserInfo `json:"users"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
+
+// NewUsersCursor points behind the user in the given sort order, users without an age sort first
+func NewUsersCursor(sort string, user UserInfo) UsersCursor {
+	cursor := UsersCursor{Sort: sort, ID: user.ID}
+
+	switch strings.TrimPrefix(sort, "-") {
+	case "age":
+		cursor.Key = "-1"
+		if user.Age != nil {
+			cursor.Key = strconv.Itoa(int(*user.Age))
+		}
+	case "first_name":
+		cursor.Key = user.FirstName
+	case "last_name":
+		cursor.Key = user.LastName
+	default:
+		cursor.Key = strconv.FormatUint(user.ID, 10)
+	}
+
+	return cursor
+}
+
+// Encode returns the opaque next_cursor value
+func (c UsersCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeUsersCursor parses a next_cursor value, it must come from a page with the same sort order
+func DecodeUsersCursor(value, sort string) (*UsersCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor UsersCursor
+	err = json.Unmarshal(data, &cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	if cursor.Sort != sort {
+		return nil, fmt.Errorf("%w: it was issued for sort %q", ErrInvalidCursor, cursor.Sort)
+	}
+
+	return &cursor, nil
+}
@@ -0,0 +1,26 @@
+package types
+
+import "errors"
+
+// media types of PATCH /users/:id
+const (
+	MergePatchMediaType = "application/merge-patch+json"
+	JSONPatchMediaType  = "application/json-patch+json"
+)
+
+var (
+	// ErrInvalidPatch means the patch document itself is malformed
+	ErrInvalidPatch = errors.New("Invalid patch")
+	// ErrPatchConflict means the patch does not fit the user, e.g. a failed test or a missing path
+	ErrPatchConflict = errors.New("Patch conflict")
+	// ErrInvalidUser means the patched user does not pass the validation
+	ErrInvalidUser = errors.New("Invalid user")
+	// ErrUnsupportedPatch means the patch media type is neither merge patch nor JSON patch
+	ErrUnsupportedPatch = errors.New("Unsupported patch")
+)
+
+// Patch is the body of a PATCH request with its media type
+type Patch struct {
+	MediaType string
+	Body      []byte
+}
@@ -1,5 +1,19 @@
 package types
 
+const (
+	HealthOK          = "ok"
+	HealthDegraded    = "degraded"
+	HealthUnavailable = "unavailable"
+)
+
+// HealthResponse is ok when the database answers and every circuit is closed,
+// degraded while some enrichment provider is short-circuited
+type HealthResponse struct {
+	Status   string         `json:"status" example:"ok"`
+	Database string         `json:"database" example:"ok"`
+	Breakers []BreakerState `json:"breakers"`
+}
+
 type SuccessResponse struct {
 	Message string `json:"message" example:"OK"`
 }
@@ -7,4 +21,44 @@ type SuccessResponse struct {
 type ErrorResponse struct {
 	Error   string `json:"error"`
 	Message string `json:"message"`
+	// Items reports every item of a batch that was rejected as a whole
+	Items []ItemResult `json:"items,omitempty"`
+}
+
+type CreateUserResponse struct {
+	UserID uint64 `json:"user_id" example:"1"`
+	// Pending lists the attributes that could not be enriched and are stored as null
+	Pending []string `json:"pending,omitempty" example:"nationality"`
+	// JobID is the queued enrichment job in the async mode
+	JobID uint64 `json:"job_id,omitempty"`
+}
+
+// BatchUserResult is the outcome of one row of a batch create, Index is the position in the request
+type BatchUserResult struct {
+	Index   int      `json:"index" example:"0"`
+	UserID  uint64   `json:"user_id,omitempty" example:"1"`
+	Pending []string `json:"pending,omitempty" example:"nationality"`
+	Error   string   `json:"error,omitempty"`
+	// Err is the cause of Error, a rate limited or short-circuited row is answered with Retry-After
+	Err error `json:"-" swaggerignore:"true"`
+}
+
+type BatchCreateUsersResponse struct {
+	Results []BatchUserResult `json:"results"`
+}
+
+// BatchItemsResponse reports every item of a batch that was stored as a whole
+type BatchItemsResponse struct {
+	Results []ItemResult `json:"results"`
+}
+
+type EnrichResponse struct {
+	UserID  uint64             `json:"user_id" example:"1"`
+	Changes []EnrichmentChange `json:"changes"`
+	// Failed lists the attributes that could not be refreshed, their stored values are kept
+	Failed []string `json:"failed,omitempty" example:"nationality"`
+}
+
+type RefreshResponse struct {
+	Queued int64 `json:"queued" example:"42"`
 }
@@ -1,25 +1,244 @@
 package types
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
 
 var ErrNotFound = errors.New("Not found")
 
+// ErrNotDeleted means a restore or a purge targets a user that is not deleted
+var ErrNotDeleted = errors.New("User is not deleted")
+
+// ErrNoWorkers means jobs would be queued while no enrichment worker of this instance processes them
+var ErrNoWorkers = errors.New("No enrichment workers")
+
+// ErrRateLimited is matched by RateLimitError with errors.Is
+var ErrRateLimited = errors.New("Rate limited")
+
+// RateLimitError is returned while an enrichment upstream rejects requests because of its quota
+type RateLimitError struct {
+	Provider   string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s provider is rate limited, retry after %s", e.Provider, e.RetryAfter)
+}
+
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// ErrCircuitOpen is matched by CircuitOpenError with errors.Is
+var ErrCircuitOpen = errors.New("Circuit open")
+
+// CircuitOpenError is returned without calling an enrichment upstream that keeps failing
+type CircuitOpenError struct {
+	Provider   string
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%s provider is unavailable, retry after %s", e.Provider, e.RetryAfter)
+}
+
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+// ErrBatchRejected is matched by ItemsError with errors.Is
+var ErrBatchRejected = errors.New("Batch rejected")
+
+// statuses of the items of a batch
+const (
+	ItemCreated   = "created"
+	ItemDeleted   = "deleted"
+	ItemDuplicate = "duplicate"
+	ItemConflict  = "conflict"
+	ItemNotFound  = "not_found"
+	ItemInvalid   = "invalid"
+	// ItemRolledBack is an item that could be stored but was not, since other items of its batch failed
+	ItemRolledBack = "rolled_back"
+)
+
+// ItemResult is the outcome of one item of a batch, Index is its position in the request
+type ItemResult struct {
+	Index  int    `json:"index" example:"1"`
+	Item   string `json:"item" example:"ann@example.com"`
+	Status string `json:"status" enums:"created,deleted,duplicate,conflict,not_found,invalid,rolled_back" example:"created"`
+	// ID is the id of a created email
+	ID      uint64 `json:"id,omitempty" example:"7"`
+	Message string `json:"message,omitempty" example:"owned by another user"`
+}
+
+// OK is true for the items that are stored as requested, duplicates included
+func (r ItemResult) OK() bool {
+	return r.Status == ItemCreated || r.Status == ItemDeleted || r.Status == ItemDuplicate
+}
+
+// ItemsError rejects a whole batch because of some of its items, nothing of the batch is stored
+type ItemsError struct {
+	Items []ItemResult
+}
+
+// RejectItems returns an ItemsError reporting every item when one of them is not OK, the created and
+// deleted ones are reported as rolled back. It is nil when every item is OK
+func RejectItems(results []ItemResult) error {
+	rejected := false
+	for _, result := range results {
+		if !result.OK() {
+			rejected = true
+			break
+		}
+	}
+	if !rejected {
+		return nil
+	}
+
+	items := make([]ItemResult, 0, len(results))
+	for _, result := range results {
+		if result.Status == ItemCreated || result.Status == ItemDeleted {
+			result.Status = ItemRolledBack
+			result.ID = 0
+			result.Message = "rolled back with the rejected items"
+		}
+		items = append(items, result)
+	}
+	return &ItemsError{Items: items}
+}
+
+func (e *ItemsError) Error() string {
+	messages := make([]string, 0, len(e.Items))
+	for _, item := range e.Items {
+		if item.OK() || item.Status == ItemRolledBack {
+			continue
+		}
+		message := fmt.Sprintf("item %d (%s) %s", item.Index, item.Item, item.Status)
+		if item.Message != "" {
+			message += ": " + item.Message
+		}
+		messages = append(messages, message)
+	}
+	return fmt.Sprintf("%d items rejected: %s", len(messages), strings.Join(messages, "; "))
+}
+
+func (e *ItemsError) Is(target error) bool {
+	return target == ErrBatchRejected
+}
+
+// User attributes are nil when the enrichment did not resolve them
 type User struct {
 	Name
-	Gender      string `json:"gender"`
-	Nationality string `json:"nationality"`
-	Age         uint8  `json:"age"`
+	Gender      *string `json:"gender" validate:"omitempty,oneof=male female"`
+	Nationality *string `json:"nationality"`
+	Age         *uint8  `json:"age" validate:"omitempty,max=150"`
+}
+
+// EnrichmentStatus returns the status of every attribute: ok when it is set, pending otherwise
+func (u User) EnrichmentStatus() EnrichmentStatus {
+	return EnrichmentStatus{
+		Age:         statusOf(u.Age != nil),
+		Gender:      statusOf(u.Gender != nil),
+		Nationality: statusOf(u.Nationality != nil),
+	}
 }
 
 type Name struct {
 	FirstName string `json:"first_name" validate:"required"`
 	LastName  string `json:"last_name" validate:"omitempty"`
+	// CountryHint is the ISO 3166-1 alpha-2 country the name comes from, it narrows the age and gender lookups
+	CountryHint string `json:"country_hint,omitempty" validate:"omitempty,iso3166_1_alpha2" example:"US"`
+}
+
+// CreateUserRequest creates the user together with its emails and friends, all of them or none
+type CreateUserRequest struct {
+	Name
+	Emails     []string `json:"emails,omitempty" validate:"omitempty,max=100,dive,required" example:"ann@example.com"`
+	FriendsIDs []uint64 `json:"friends_ids,omitempty" validate:"omitempty,max=100,dive,required" example:"2"`
+}
+
+type BatchCreateUsersRequest struct {
+	Users []Name `json:"users" validate:"required,min=1,max=100,dive"`
+}
+
+// ListUsersRequest is the query of GET /users, nationality is a comma separated list of countries
+type ListUsersRequest struct {
+	Limit         int    `form:"limit" validate:"omitempty,min=1,max=500" example:"50"`
+	Cursor        string `form:"cursor" validate:"omitempty,max=500"`
+	Sort          string `form:"sort" validate:"omitempty,oneof=id -id age -age first_name -first_name last_name -last_name" example:"-age"`
+	Gender        string `form:"gender" validate:"omitempty,oneof=male female" example:"female"`
+	Nationality   string `form:"nationality" validate:"omitempty,max=200" example:"DE,FR"`
+	Region        string `form:"region" validate:"omitempty,max=20" example:"Europe"`
+	AgeMin        *int   `form:"age_min" validate:"omitempty,min=0,max=150"`
+	AgeMax        *int   `form:"age_max" validate:"omitempty,min=0,max=150"`
+	FirstName     string `form:"first_name" validate:"omitempty,max=100" example:"Ann"`
+	LastName      string `form:"last_name" validate:"omitempty,max=100"`
+	LastNameExact string `form:"last_name_exact" validate:"omitempty,max=100"`
+	HasEmail      *bool  `form:"has_email"`
+}
+
+// SearchUsersRequest is the query of GET /users/search
+type SearchUsersRequest struct {
+	Query string `form:"q" validate:"required,min=2,max=100" example:"ivanov"`
+	Limit int    `form:"limit" validate:"omitempty,min=1,max=100" example:"20"`
+}
+
+// UserMatch is a search result, Score is between 0 and 1, higher matches better
+type UserMatch struct {
+	UserInfo
+	Score float32 `json:"score" example:"0.83"`
+}
+
+// UsersFilter narrows the users list, empty fields match everything
+type UsersFilter struct {
+	// Nationalities are ISO 3166-1 alpha-2 codes, nil matches every nationality
+	Nationalities []string
+	Gender        *string
+	AgeMin        *int
+	AgeMax        *int
+	// FirstNamePrefix and LastNamePrefix match case-insensitively
+	FirstNamePrefix *string
+	LastNamePrefix  *string
+	HasEmail        *bool
+	Sort            string
+	Limit           int
+	// LastName matches the whole last name case-sensitively
+	LastName *string
+	// After continues the list behind the last user of the previous page
+	After *UsersCursor
+}
+
+type RefreshRequest struct {
+	// OlderThan selects users enriched longer ago than this duration, e.g. "720h"
+	OlderThan   string `json:"older_than" example:"720h"`
+	MissingOnly bool   `json:"missing_only"`
+	Limit       int    `json:"limit" validate:"omitempty,min=1,max=10000" example:"100"`
 }
 
 type UserInfo struct {
 	ID uint64 `json:"id"`
 	User
-	Emails []string `json:"emails"`
+	// Country describes the nationality, it is derived on read
+	Country          *CountryInfo       `json:"country,omitempty"`
+	EnrichmentStatus EnrichmentStatus   `json:"enrichment_status"`
+	Enrichment       *EnrichmentDetails `json:"enrichment,omitempty"`
+	Emails           []string           `json:"emails"`
+	// FriendCount is only filled when a single user is fetched by id
+	FriendCount *uint64 `json:"friend_count,omitempty"`
+	// Version grows with every change of the user, its emails, friends and enrichment, it is the ETag
+	Version   uint64    `json:"version"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// UserSnapshot is the user with its emails and friends as it was at AsOf, UpdatedAt is when that version
+// was written. The enrichment details are not kept in the history
+type UserSnapshot struct {
+	UserInfo
+	AsOf      time.Time `json:"as_of"`
+	FriendIDs []uint64  `json:"friend_ids"`
 }
 
 type Email struct {
@@ -29,7 +248,7 @@ type Email struct {
 }
 
 type EmailRequest struct {
-	Emails []string `json:"emails" validate:"required"`
+	Emails []string `json:"emails" validate:"required,min=1,max=100"`
 }
 
 type EmailIDs struct {
@@ -37,7 +256,7 @@ type EmailIDs struct {
 }
 
 type Friends struct {
-	FriendsIDs []uint64 `json:"friends_ids" validate:"required"`
+	FriendsIDs []uint64 `json:"friends_ids" validate:"required,min=1,max=100"`
 }
 
 type Friendship struct {
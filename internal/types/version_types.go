@@ -0,0 +1,54 @@
+package types
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrPreconditionFailed means the If-Match of a write does not match the current version of the user
+var ErrPreconditionFailed = errors.New("Precondition failed")
+
+// ETag is the strong entity tag of a user version
+func ETag(version uint64) string {
+	return `"` + strconv.FormatUint(version, 10) + `"`
+}
+
+// IfMatch holds the entity tags of an If-Match header, a nil IfMatch has no precondition
+type IfMatch []string
+
+// ParseETags splits an If-Match or If-None-Match header into its entity tags
+func ParseETags(header string) []string {
+	var tags []string
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// Matches compares the tags with the version the strong way, weak tags never match
+func (m IfMatch) Matches(version uint64) bool {
+	if m == nil {
+		return true
+	}
+	for _, tag := range m {
+		if tag == "*" || tag == ETag(version) {
+			return true
+		}
+	}
+	return false
+}
+
+// NoneMatch reports whether an If-None-Match header matches the version the weak way,
+// a read is then answered with 304 Not Modified
+func NoneMatch(header string, version uint64) bool {
+	for _, tag := range ParseETags(header) {
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == ETag(version) {
+			return true
+		}
+	}
+	return false
+}
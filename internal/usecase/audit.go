@@ -0,0 +1,78 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"people/internal/types"
+)
+
+// GetAuditEvents returns a page of the audit events matching the request, the newest first.
+// A userID narrows them to the history of that user, its emails and its friendships
+func (s *UseCase) GetAuditEvents(ctx context.Context, req types.ListAuditRequest, userID *uint64) (types.AuditPage, error) {
+	filter, err := auditFilter(req)
+	if err != nil {
+		s.log.WithError(err).Errorln("Invalid audit query")
+		return types.AuditPage{}, err
+	}
+	filter.UserID = userID
+
+	// one more event tells whether there is a next page
+	filter.Limit++
+	events, err := s.storage.GetAuditEvents(ctx, filter)
+	if err != nil {
+		s.log.WithError(err).Errorln("Can`t get audit events")
+		return types.AuditPage{}, err
+	}
+
+	page := types.AuditPage{Events: events}
+	if len(events) > filter.Limit-1 {
+		page.Events = events[:filter.Limit-1]
+		page.NextCursor = strconv.FormatUint(page.Events[len(page.Events)-1].ID, 10)
+	}
+
+	return page, nil
+}
+
+// auditFilter applies the defaults of the audit list
+func auditFilter(req types.ListAuditRequest) (types.AuditFilter, error) {
+	filter := types.AuditFilter{
+		Limit: req.Limit,
+		From:  req.From,
+		To:    req.To,
+	}
+	if filter.Limit == 0 {
+		filter.Limit = types.DefaultAuditLimit
+	}
+
+	if req.Cursor != "" {
+		before, err := strconv.ParseUint(req.Cursor, 10, 64)
+		if err != nil {
+			return types.AuditFilter{}, types.ErrInvalidCursor
+		}
+		filter.Before = &before
+	}
+
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return types.AuditFilter{}, fmt.Errorf("%w: from must be before to", types.ErrInvalidFilter)
+	}
+
+	for _, field := range []struct {
+		value  string
+		target **string
+	}{
+		{req.Actor, &filter.Actor},
+		{req.Action, &filter.Action},
+		{req.Entity, &filter.Entity},
+		{req.EntityID, &filter.EntityID},
+		{req.RequestID, &filter.RequestID},
+	} {
+		if field.value != "" {
+			value := field.value
+			*field.target = &value
+		}
+	}
+
+	return filter, nil
+}
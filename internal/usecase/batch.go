@@ -0,0 +1,146 @@
+package usecase
+
+import (
+	"context"
+	"sync"
+
+	"people/internal/repository/enrichment"
+	"people/internal/types"
+)
+
+// batchEnrichmentResult holds the batch lookups of all distinct first names
+type batchEnrichmentResult struct {
+	ages           map[string]types.AgeData
+	ageErr         error
+	genders        map[string]types.GenderData
+	genderErr      error
+	nationalities  map[string]types.NationalityData
+	nationalityErr error
+}
+
+// enrichBatch runs the three batch lookups concurrently, a failed lookup fails that attribute for every name
+func (s *UseCase) enrichBatch(ctx context.Context, names []string, country string) batchEnrichmentResult {
+	var result batchEnrichmentResult
+	var wg sync.WaitGroup
+	wg.Add(3)
+
+	go func() {
+		defer wg.Done()
+		result.ages, result.ageErr = enrichment.AgeBatch(ctx, s.enrichment, names, country)
+	}()
+
+	go func() {
+		defer wg.Done()
+		result.genders, result.genderErr = enrichment.GenderBatch(ctx, s.enrichment, names, country)
+	}()
+
+	go func() {
+		defer wg.Done()
+		result.nationalities, result.nationalityErr = enrichment.NationalityBatch(ctx, s.enrichment, names)
+	}()
+
+	wg.Wait()
+	return result
+}
+
+// result picks the lookups of one name, names missing from a batch answer are not found
+func (r batchEnrichmentResult) result(name string) enrichmentResult {
+	result := enrichmentResult{
+		ageErr:         r.ageErr,
+		genderErr:      r.genderErr,
+		nationalityErr: r.nationalityErr,
+	}
+
+	var ok bool
+	if result.ageErr == nil {
+		if result.age, ok = r.ages[name]; !ok {
+			result.ageErr = types.ErrNotFound
+		}
+	}
+	if result.genderErr == nil {
+		if result.gender, ok = r.genders[name]; !ok {
+			result.genderErr = types.ErrNotFound
+		}
+	}
+	if result.nationalityErr == nil {
+		if result.nationality, ok = r.nationalities[name]; !ok {
+			result.nationalityErr = types.ErrNotFound
+		}
+	}
+
+	return result
+}
+
+// CreateUsers enriches the distinct first names with upstream batch requests and stores every user,
+// the outcome is reported per row in the order of the request
+func (s *UseCase) CreateUsers(ctx context.Context, names []types.Name) []types.BatchUserResult {
+	if s.mode == types.EnrichmentModeAsync {
+		return s.createUsersPending(ctx, names)
+	}
+
+	// upstream batches take a single country_id, names are grouped by their hint,
+	// the default hint only narrows the lookups and is not stored
+	seen := make(map[types.Name]struct{}, len(names))
+	firstNames := make(map[string][]string)
+	for _, name := range names {
+		key := types.Name{FirstName: name.FirstName, CountryHint: s.countryHintOr(name.CountryHint)}
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		firstNames[key.CountryHint] = append(firstNames[key.CountryHint], name.FirstName)
+	}
+
+	enriched := make(map[string]batchEnrichmentResult, len(firstNames))
+	for country, batch := range firstNames {
+		enriched[country] = s.enrichBatch(ctx, batch, country)
+	}
+
+	results := make([]types.BatchUserResult, len(names))
+	for i, name := range names {
+		results[i].Index = i
+
+		result := enriched[s.countryHintOr(name.CountryHint)].result(name.FirstName)
+
+		user, err := s.applyPolicy(ctx, name, result)
+		if err != nil {
+			results[i].Error = err.Error()
+			results[i].Err = err
+			continue
+		}
+
+		id, err := s.storage.CreateUser(ctx, user, result.details(s.topCountries))
+		if err != nil {
+			s.log.WithError(err).Errorln("Can`t add user")
+			results[i].Error = err.Error()
+			results[i].Err = err
+			continue
+		}
+
+		results[i].UserID = id
+		results[i].Pending = user.EnrichmentStatus().Pending()
+	}
+
+	return results
+}
+
+// createUsersPending stores every user right away and queues its enrichment
+func (s *UseCase) createUsersPending(ctx context.Context, names []types.Name) []types.BatchUserResult {
+	results := make([]types.BatchUserResult, len(names))
+	for i, name := range names {
+		results[i].Index = i
+
+		id, _, err := s.storage.CreateUserPending(ctx, name)
+		if err != nil {
+			s.log.WithError(err).Errorln("Can`t add user")
+			results[i].Error = err.Error()
+			results[i].Err = err
+			continue
+		}
+
+		results[i].UserID = id
+		results[i].Pending = types.User{}.EnrichmentStatus().Pending()
+	}
+
+	return results
+}
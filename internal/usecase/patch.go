@@ -0,0 +1,443 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"people/internal/types"
+)
+
+// PatchUser applies a merge patch or a JSON patch to the user when it matches ifMatch,
+// only the changed columns are written
+func (s *UseCase) PatchUser(ctx context.Context, id uint64, patch types.Patch, ifMatch types.IfMatch) (types.UserInfo, error) {
+	err := s.storage.InTransaction(ctx, func(uow UnitOfWork) error {
+		err := uow.MatchVersion(ctx, id, ifMatch)
+		if err != nil {
+			return err
+		}
+
+		user, err := uow.GetUserForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		document, err := userDocument(user)
+		if err != nil {
+			return err
+		}
+
+		document, err = applyPatch(document, patch)
+		if err != nil {
+			return err
+		}
+
+		patched, err := patchedUser(document)
+		if err != nil {
+			return err
+		}
+
+		return uow.PatchUser(ctx, id, patched, changedColumns(user, patched))
+	})
+	if err != nil {
+		s.log.WithError(err).Errorln("Can`t patch user")
+		return types.UserInfo{}, err
+	}
+
+	return s.GetUserByID(ctx, id)
+}
+
+// userDocument is the JSON document the patch applies to, every member of the user is present
+func userDocument(user types.User) (any, error) {
+	data, err := json.Marshal(user)
+	if err != nil {
+		return nil, err
+	}
+
+	var document map[string]any
+	err = json.Unmarshal(data, &document)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := document["country_hint"]; !ok {
+		document["country_hint"] = nil
+	}
+
+	return document, nil
+}
+
+// patchedUser reads the patched document back into the user schema and validates it
+func patchedUser(document any) (types.User, error) {
+	data, err := json.Marshal(document)
+	if err != nil {
+		return types.User{}, err
+	}
+
+	var user types.User
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(&user)
+	if err != nil {
+		return types.User{}, fmt.Errorf("%w: %s", types.ErrInvalidUser, err)
+	}
+
+	err = validator.New().Struct(user)
+	if err != nil {
+		return types.User{}, fmt.Errorf("%w: %s", types.ErrInvalidUser, err)
+	}
+
+	if user.Nationality != nil {
+		nationality, err := types.NormalizeCountry(*user.Nationality)
+		if err != nil {
+			return types.User{}, fmt.Errorf("%w: %s", types.ErrInvalidUser, err)
+		}
+		user.Nationality = &nationality
+	}
+
+	return user, nil
+}
+
+// changedColumns lists the columns of Users that differ between the stored and the patched user
+func changedColumns(stored, patched types.User) []string {
+	var columns []string
+	if stored.FirstName != patched.FirstName {
+		columns = append(columns, "first_name")
+	}
+	if stored.LastName != patched.LastName {
+		columns = append(columns, "last_name")
+	}
+	if stored.CountryHint != patched.CountryHint {
+		columns = append(columns, "country_hint")
+	}
+	if !reflect.DeepEqual(stored.Gender, patched.Gender) {
+		columns = append(columns, "gender")
+	}
+	if !reflect.DeepEqual(stored.Nationality, patched.Nationality) {
+		columns = append(columns, "nationality")
+	}
+	if !reflect.DeepEqual(stored.Age, patched.Age) {
+		columns = append(columns, "age")
+	}
+	return columns
+}
+
+// applyPatch applies a JSON Merge Patch (RFC 7396) or a JSON Patch (RFC 6902) to a JSON document
+func applyPatch(document any, patch types.Patch) (any, error) {
+	switch patch.MediaType {
+	case types.MergePatchMediaType:
+		var merge any
+		err := json.Unmarshal(patch.Body, &merge)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", types.ErrInvalidPatch, err)
+		}
+		return mergePatch(document, merge), nil
+	case types.JSONPatchMediaType:
+		// members an operation does not define are ignored
+		var operations []patchOperation
+		err := json.Unmarshal(patch.Body, &operations)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", types.ErrInvalidPatch, err)
+		}
+		for i, operation := range operations {
+			document, err = operation.apply(document)
+			if err != nil {
+				return nil, fmt.Errorf("operation %d (%s %s): %w", i, operation.Op, operation.Path, err)
+			}
+		}
+		return document, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", types.ErrUnsupportedPatch, patch.MediaType)
+	}
+}
+
+// mergePatch follows RFC 7396: objects are merged recursively, null removes a member
+// and any other value replaces the target
+func mergePatch(target, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]any)
+	if !ok {
+		targetObject = make(map[string]any)
+	}
+
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = mergePatch(targetObject[key], value)
+	}
+
+	return targetObject
+}
+
+// patchOperation is an operation of RFC 6902, Value is nil when the member is missing and "null" when it is null
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+func (o patchOperation) apply(document any) (any, error) {
+	path, err := parsePointer(o.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch o.Op {
+	case "add", "replace", "test":
+		if o.Value == nil {
+			return nil, fmt.Errorf("%w: %s needs a value", types.ErrInvalidPatch, o.Op)
+		}
+		var value any
+		err = json.Unmarshal(o.Value, &value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", types.ErrInvalidPatch, err)
+		}
+
+		switch o.Op {
+		case "add":
+			return addValue(document, path, value)
+		case "replace":
+			return replaceValue(document, path, value)
+		default:
+			current, err := getValue(document, path)
+			if err != nil {
+				return nil, err
+			}
+			if !reflect.DeepEqual(current, value) {
+				return nil, fmt.Errorf("%w: test failed", types.ErrPatchConflict)
+			}
+			return document, nil
+		}
+	case "remove":
+		document, _, err = removeValue(document, path)
+		return document, err
+	case "move", "copy":
+		from, err := parsePointer(o.From)
+		if err != nil {
+			return nil, err
+		}
+
+		if o.Op == "copy" {
+			value, err := getValue(document, from)
+			if err != nil {
+				return nil, err
+			}
+			return addValue(document, path, deepCopy(value))
+		}
+
+		if isPrefix(from, path) && len(from) < len(path) {
+			return nil, fmt.Errorf("%w: can not move a value into itself", types.ErrInvalidPatch)
+		}
+		document, value, err := removeValue(document, from)
+		if err != nil {
+			return nil, err
+		}
+		return addValue(document, path, value)
+	default:
+		return nil, fmt.Errorf("%w: unknown op %q", types.ErrInvalidPatch, o.Op)
+	}
+}
+
+// parsePointer splits a JSON Pointer (RFC 6901) into its unescaped tokens, "" is the whole document
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("%w: pointer %q must start with /", types.ErrInvalidPatch, pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+	return tokens, nil
+}
+
+func getValue(document any, path []string) (any, error) {
+	node := document
+	for _, token := range path {
+		var err error
+		node, err = childOf(node, token)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return node, nil
+}
+
+func addValue(document any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	return updateParent(document, path, func(parent any, token string) (any, error) {
+		switch container := parent.(type) {
+		case map[string]any:
+			container[token] = value
+			return container, nil
+		case []any:
+			if token == "-" {
+				return append(container, value), nil
+			}
+			index, err := arrayIndex(token, len(container)+1)
+			if err != nil {
+				return nil, err
+			}
+			container = append(container, nil)
+			copy(container[index+1:], container[index:])
+			container[index] = value
+			return container, nil
+		default:
+			return nil, fmt.Errorf("%w: %q is not in an object or an array", types.ErrPatchConflict, token)
+		}
+	})
+}
+
+func replaceValue(document any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	return updateParent(document, path, func(parent any, token string) (any, error) {
+		_, err := childOf(parent, token)
+		if err != nil {
+			return nil, err
+		}
+		return setChild(parent, token, value), nil
+	})
+}
+
+func removeValue(document any, path []string) (any, any, error) {
+	if len(path) == 0 {
+		return nil, nil, fmt.Errorf("%w: can not remove the whole document", types.ErrInvalidPatch)
+	}
+
+	var removed any
+	document, err := updateParent(document, path, func(parent any, token string) (any, error) {
+		var err error
+		removed, err = childOf(parent, token)
+		if err != nil {
+			return nil, err
+		}
+
+		switch container := parent.(type) {
+		case map[string]any:
+			delete(container, token)
+			return container, nil
+		default:
+			index, _ := strconv.Atoi(token)
+			items := container.([]any)
+			return append(items[:index], items[index+1:]...), nil
+		}
+	})
+	return document, removed, err
+}
+
+// updateParent lets change the container of the last token of path and stores the changed container
+// in its own parent on the way back
+func updateParent(node any, path []string, change func(parent any, token string) (any, error)) (any, error) {
+	if len(path) == 1 {
+		return change(node, path[0])
+	}
+
+	child, err := childOf(node, path[0])
+	if err != nil {
+		return nil, err
+	}
+
+	child, err = updateParent(child, path[1:], change)
+	if err != nil {
+		return nil, err
+	}
+
+	return setChild(node, path[0], child), nil
+}
+
+func childOf(node any, token string) (any, error) {
+	switch container := node.(type) {
+	case map[string]any:
+		value, ok := container[token]
+		if !ok {
+			return nil, fmt.Errorf("%w: no member %q", types.ErrPatchConflict, token)
+		}
+		return value, nil
+	case []any:
+		index, err := arrayIndex(token, len(container))
+		if err != nil {
+			return nil, err
+		}
+		return container[index], nil
+	default:
+		return nil, fmt.Errorf("%w: %q is not in an object or an array", types.ErrPatchConflict, token)
+	}
+}
+
+// setChild replaces an existing child, childOf must have found it before
+func setChild(node any, token string, value any) any {
+	switch container := node.(type) {
+	case map[string]any:
+		container[token] = value
+		return container
+	default:
+		index, _ := strconv.Atoi(token)
+		items := container.([]any)
+		items[index] = value
+		return items
+	}
+}
+
+// arrayIndex parses an array index below size, only digits without leading zeros are allowed
+func arrayIndex(token string, size int) (int, error) {
+	index, err := strconv.Atoi(token)
+	if err != nil || strings.Trim(token, "0123456789") != "" || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("%w: invalid array index %q", types.ErrInvalidPatch, token)
+	}
+	if index >= size {
+		return 0, fmt.Errorf("%w: array index %d out of range", types.ErrPatchConflict, index)
+	}
+	return index, nil
+}
+
+func isPrefix(prefix, path []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func deepCopy(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		copied := make(map[string]any, len(v))
+		for key, item := range v {
+			copied[key] = deepCopy(item)
+		}
+		return copied
+	case []any:
+		copied := make([]any, len(v))
+		for i, item := range v {
+			copied[i] = deepCopy(item)
+		}
+		return copied
+	default:
+		return v
+	}
+}
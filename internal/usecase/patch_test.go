@@ -0,0 +1,383 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"people/internal/repository/enrichment/enrichmenttest"
+	"people/internal/types"
+)
+
+func decode(t *testing.T, document string) any {
+	t.Helper()
+
+	var value any
+	if err := json.Unmarshal([]byte(document), &value); err != nil {
+		t.Fatalf("decoding %s: %v", document, err)
+	}
+	return value
+}
+
+// TestJSONPatch runs the examples of RFC 6902 Appendix A and the edge cases of RFC 6901 pointers
+func TestJSONPatch(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		document string
+		patch    string
+		want     string
+		err      error
+	}{
+		{
+			name:     "A.1 adding an object member",
+			document: `{"foo":"bar"}`,
+			patch:    `[{"op":"add","path":"/baz","value":"qux"}]`,
+			want:     `{"baz":"qux","foo":"bar"}`,
+		},
+		{
+			name:     "A.2 adding an array element",
+			document: `{"foo":["bar","baz"]}`,
+			patch:    `[{"op":"add","path":"/foo/1","value":"qux"}]`,
+			want:     `{"foo":["bar","qux","baz"]}`,
+		},
+		{
+			name:     "A.3 removing an object member",
+			document: `{"baz":"qux","foo":"bar"}`,
+			patch:    `[{"op":"remove","path":"/baz"}]`,
+			want:     `{"foo":"bar"}`,
+		},
+		{
+			name:     "A.4 removing an array element",
+			document: `{"foo":["bar","qux","baz"]}`,
+			patch:    `[{"op":"remove","path":"/foo/1"}]`,
+			want:     `{"foo":["bar","baz"]}`,
+		},
+		{
+			name:     "A.5 replacing a value",
+			document: `{"baz":"qux","foo":"bar"}`,
+			patch:    `[{"op":"replace","path":"/baz","value":"boo"}]`,
+			want:     `{"baz":"boo","foo":"bar"}`,
+		},
+		{
+			name:     "A.6 moving a value",
+			document: `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			patch:    `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			want:     `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`,
+		},
+		{
+			name:     "A.7 moving an array element",
+			document: `{"foo":["all","grass","cows","eats"]}`,
+			patch:    `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`,
+			want:     `{"foo":["all","cows","eats","grass"]}`,
+		},
+		{
+			name:     "A.8 testing a value: success",
+			document: `{"baz":"qux","foo":["a",2,"c"]}`,
+			patch:    `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`,
+			want:     `{"baz":"qux","foo":["a",2,"c"]}`,
+		},
+		{
+			name:     "A.9 testing a value: error",
+			document: `{"baz":"qux"}`,
+			patch:    `[{"op":"test","path":"/baz","value":"bar"}]`,
+			err:      types.ErrPatchConflict,
+		},
+		{
+			name:     "A.10 adding a nested member object",
+			document: `{"foo":"bar"}`,
+			patch:    `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`,
+			want:     `{"foo":"bar","child":{"grandchild":{}}}`,
+		},
+		{
+			name:     "A.11 ignoring unrecognized elements",
+			document: `{"foo":"bar"}`,
+			patch:    `[{"op":"add","path":"/baz","value":"qux","xyz":123}]`,
+			want:     `{"foo":"bar","baz":"qux"}`,
+		},
+		{
+			name:     "A.12 adding to a nonexistent target",
+			document: `{"foo":"bar"}`,
+			patch:    `[{"op":"add","path":"/baz/bat","value":"qux"}]`,
+			err:      types.ErrPatchConflict,
+		},
+		{
+			name:     "A.14 ~ escape ordering",
+			document: `{"/":9,"~1":10}`,
+			patch:    `[{"op":"test","path":"/~01","value":10}]`,
+			want:     `{"/":9,"~1":10}`,
+		},
+		{
+			name:     "A.15 comparing strings and numbers",
+			document: `{"/":9,"~1":10}`,
+			patch:    `[{"op":"test","path":"/~01","value":"10"}]`,
+			err:      types.ErrPatchConflict,
+		},
+		{
+			name:     "A.16 adding an array value",
+			document: `{"foo":["bar"]}`,
+			patch:    `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`,
+			want:     `{"foo":["bar",["abc","def"]]}`,
+		},
+		{
+			name:     "~1 is a slash in a member name",
+			document: `{"a/b":1}`,
+			patch:    `[{"op":"replace","path":"/a~1b","value":2}]`,
+			want:     `{"a/b":2}`,
+		},
+		{
+			name:     "- is a member name in an object",
+			document: `{"foo":{}}`,
+			patch:    `[{"op":"add","path":"/foo/-","value":1}]`,
+			want:     `{"foo":{"-":1}}`,
+		},
+		{
+			name:     "- does not point at an element to remove",
+			document: `{"foo":[1]}`,
+			patch:    `[{"op":"remove","path":"/foo/-"}]`,
+			err:      types.ErrInvalidPatch,
+		},
+		{
+			name:     "leading zero array index",
+			document: `{"foo":[1,2]}`,
+			patch:    `[{"op":"replace","path":"/foo/01","value":3}]`,
+			err:      types.ErrInvalidPatch,
+		},
+		{
+			name:     "signed array index",
+			document: `{"foo":[1,2]}`,
+			patch:    `[{"op":"replace","path":"/foo/+1","value":3}]`,
+			err:      types.ErrInvalidPatch,
+		},
+		{
+			name:     "array index out of range",
+			document: `{"foo":[1,2]}`,
+			patch:    `[{"op":"add","path":"/foo/3","value":3}]`,
+			err:      types.ErrPatchConflict,
+		},
+		{
+			name:     "pointer without a leading slash",
+			document: `{"foo":1}`,
+			patch:    `[{"op":"remove","path":"foo"}]`,
+			err:      types.ErrInvalidPatch,
+		},
+		{
+			name:     "moving a value into itself",
+			document: `{"foo":{"bar":1}}`,
+			patch:    `[{"op":"move","from":"/foo","path":"/foo/bar/baz"}]`,
+			err:      types.ErrInvalidPatch,
+		},
+		{
+			name:     "moving a value onto itself",
+			document: `{"foo":{"bar":1}}`,
+			patch:    `[{"op":"move","from":"/foo","path":"/foo"}]`,
+			want:     `{"foo":{"bar":1}}`,
+		},
+		{
+			name:     "copying a value is a deep copy",
+			document: `{"foo":{"bar":1}}`,
+			patch:    `[{"op":"copy","from":"/foo","path":"/baz"},{"op":"replace","path":"/baz/bar","value":2}]`,
+			want:     `{"foo":{"bar":1},"baz":{"bar":2}}`,
+		},
+		{
+			name:     "testing numbers compares their values",
+			document: `{"age":30}`,
+			patch:    `[{"op":"test","path":"/age","value":30.0},{"op":"test","path":"/age","value":3e1}]`,
+			want:     `{"age":30}`,
+		},
+		{
+			name:     "testing a different number",
+			document: `{"age":30}`,
+			patch:    `[{"op":"test","path":"/age","value":31}]`,
+			err:      types.ErrPatchConflict,
+		},
+		{
+			name:     "testing null",
+			document: `{"gender":null}`,
+			patch:    `[{"op":"test","path":"/gender","value":null}]`,
+			want:     `{"gender":null}`,
+		},
+		{
+			name:     "an operation without its value",
+			document: `{"foo":1}`,
+			patch:    `[{"op":"add","path":"/bar"}]`,
+			err:      types.ErrInvalidPatch,
+		},
+		{
+			name:     "an unknown operation",
+			document: `{"foo":1}`,
+			patch:    `[{"op":"increment","path":"/foo"}]`,
+			err:      types.ErrInvalidPatch,
+		},
+		{
+			name:     "removing the whole document",
+			document: `{"foo":1}`,
+			patch:    `[{"op":"remove","path":""}]`,
+			err:      types.ErrInvalidPatch,
+		},
+		{
+			name:     "not a patch document",
+			document: `{"foo":1}`,
+			patch:    `{"op":"remove","path":"/foo"}`,
+			err:      types.ErrInvalidPatch,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			patch := types.Patch{MediaType: types.JSONPatchMediaType, Body: []byte(test.patch)}
+			got, err := applyPatch(decode(t, test.document), patch)
+			if test.err != nil {
+				if !errors.Is(err, test.err) {
+					t.Fatalf("applyPatch() error = %v, want %v", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyPatch() error = %v", err)
+			}
+			if want := decode(t, test.want); !reflect.DeepEqual(got, want) {
+				t.Fatalf("applyPatch() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+// TestMergePatch runs the examples of RFC 7396 Appendix A
+func TestMergePatch(t *testing.T) {
+	for _, test := range []struct {
+		document string
+		patch    string
+		want     string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	} {
+		t.Run(test.patch, func(t *testing.T) {
+			patch := types.Patch{MediaType: types.MergePatchMediaType, Body: []byte(test.patch)}
+			got, err := applyPatch(decode(t, test.document), patch)
+			if err != nil {
+				t.Fatalf("applyPatch() error = %v", err)
+			}
+			if want := decode(t, test.want); !reflect.DeepEqual(got, want) {
+				t.Fatalf("applyPatch(%s, %s) = %v, want %v", test.document, test.patch, got, want)
+			}
+		})
+	}
+}
+
+func TestApplyPatchRejectsOtherMediaTypes(t *testing.T) {
+	_, err := applyPatch(decode(t, `{}`), types.Patch{MediaType: "text/plain", Body: []byte(`{}`)})
+	if !errors.Is(err, types.ErrUnsupportedPatch) {
+		t.Fatalf("applyPatch() error = %v, want ErrUnsupportedPatch", err)
+	}
+}
+
+func TestChangedColumns(t *testing.T) {
+	text := func(value string) *string { return &value }
+	age := func(value uint8) *uint8 { return &value }
+
+	stored := types.User{
+		Name:        types.Name{FirstName: "Ann", LastName: "Lee", CountryHint: "DE"},
+		Gender:      text("female"),
+		Nationality: text("DE"),
+		Age:         age(31),
+	}
+
+	for _, test := range []struct {
+		name    string
+		patched types.User
+		want    []string
+	}{
+		{
+			name:    "nothing changed",
+			patched: types.User{Name: stored.Name, Gender: text("female"), Nationality: text("DE"), Age: age(31)},
+		},
+		{
+			name: "names and hint",
+			patched: types.User{
+				Name:   types.Name{FirstName: "Anna", LastName: "Li", CountryHint: "FR"},
+				Gender: text("female"), Nationality: text("DE"), Age: age(31),
+			},
+			want: []string{"first_name", "last_name", "country_hint"},
+		},
+		{
+			name:    "changed and removed attributes",
+			patched: types.User{Name: stored.Name, Gender: text("male"), Age: age(32)},
+			want:    []string{"gender", "nationality", "age"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if got := changedColumns(stored, test.patched); !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("changedColumns() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPatchUserErrors(t *testing.T) {
+	server := enrichmenttest.NewServer()
+	defer server.Close()
+
+	for _, test := range []struct {
+		name      string
+		mediaType string
+		body      string
+		err       error
+	}{
+		{"unsupported media type", "text/plain", `{}`, types.ErrUnsupportedPatch},
+		{"malformed merge patch", types.MergePatchMediaType, `{`, types.ErrInvalidPatch},
+		{"failed test", types.JSONPatchMediaType, `[{"op":"test","path":"/first_name","value":"Bob"}]`, types.ErrPatchConflict},
+		{"unknown member", types.MergePatchMediaType, `{"nickname":"Annie"}`, types.ErrInvalidUser},
+		{"removed required member", types.MergePatchMediaType, `{"first_name":null}`, types.ErrInvalidUser},
+		{"non-object merge patch", types.MergePatchMediaType, `"Ann"`, types.ErrInvalidUser},
+		{"invalid gender", types.JSONPatchMediaType, `[{"op":"replace","path":"/gender","value":"other"}]`, types.ErrInvalidUser},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			useCase, store := newUseCase(t, server, options{})
+			store.users[1] = types.User{Name: types.Name{FirstName: "Ann"}}
+
+			patch := types.Patch{MediaType: test.mediaType, Body: []byte(test.body)}
+			_, err := useCase.PatchUser(context.Background(), 1, patch, nil)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("PatchUser() error = %v, want %v", err, test.err)
+			}
+			if len(store.patched) != 0 {
+				t.Fatalf("patched columns = %v, want nothing written", store.patched)
+			}
+		})
+	}
+}
+
+func TestPatchUserWritesChangedColumns(t *testing.T) {
+	server := enrichmenttest.NewServer()
+	defer server.Close()
+
+	useCase, store := newUseCase(t, server, options{})
+	store.users[1] = types.User{Name: types.Name{FirstName: "Ann", LastName: "Lee"}}
+
+	patch := types.Patch{MediaType: types.MergePatchMediaType, Body: []byte(`{"last_name":"Li","age":31,"country_hint":null}`)}
+	_, err := useCase.PatchUser(context.Background(), 1, patch, nil)
+	if err != nil {
+		t.Fatalf("PatchUser() error = %v", err)
+	}
+
+	if want := []string{"last_name", "age"}; !reflect.DeepEqual(store.patched, want) {
+		t.Fatalf("patched columns = %v, want %v", store.patched, want)
+	}
+	if user := store.stored()[1]; user.LastName != "Li" || user.Age == nil || *user.Age != 31 {
+		t.Fatalf("stored user = %+v, want Li aged 31", user)
+	}
+}
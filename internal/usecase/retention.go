@@ -0,0 +1,56 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"people/internal/types"
+)
+
+const (
+	defaultRetentionInterval  = time.Hour
+	defaultRetentionBatchSize = 1000
+)
+
+// RunRetention purges the users deleted longer than cfg.DeletedUsers ago until ctx is done
+func (s *UseCase) RunRetention(ctx context.Context, cfg types.RetentionConfig) {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultRetentionInterval
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultRetentionBatchSize
+	}
+
+	s.log.Infof("Purging users deleted more than %s ago every %s", cfg.DeletedUsers, cfg.Interval)
+
+	ctx = types.WithAuditInfo(ctx, types.AuditInfo{Actor: "retention", ActorSource: types.ActorSourceService})
+
+	for {
+		purged, err := s.purgeDeletedUsers(ctx, time.Now().Add(-cfg.DeletedUsers), cfg.BatchSize)
+		if err != nil {
+			s.log.WithError(err).Errorln("Retention failed to purge deleted users")
+		}
+		if purged > 0 {
+			s.log.Infof("Retention purged %d deleted users", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(cfg.Interval):
+		}
+	}
+}
+
+// purgeDeletedUsers removes the users deleted before the given time batch by batch, so that a backlog
+// does not hold the locks of all its rows in one transaction
+func (s *UseCase) purgeDeletedUsers(ctx context.Context, before time.Time, batchSize int) (int64, error) {
+	var total int64
+	for {
+		purged, err := s.storage.PurgeDeletedUsers(ctx, before, batchSize)
+		total += purged
+		if err != nil || purged < int64(batchSize) || ctx.Err() != nil {
+			return total, err
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"people/internal/repository/storage"
+	"people/internal/types"
+)
+
+// Storage is the part of storage.Storage the use cases need, Postgres adapts the real one
+type Storage interface {
+	// InTransaction commits the writes of fn when it returns nil and none of them otherwise
+	InTransaction(ctx context.Context, fn func(uow UnitOfWork) error) error
+
+	GetUserByID(ctx context.Context, id uint64) (types.UserInfo, error)
+	GetUserAsOf(ctx context.Context, id uint64, asOf time.Time) (types.UserSnapshot, error)
+	GetUserName(ctx context.Context, id uint64) (types.Name, error)
+	SearchUsers(ctx context.Context, query string, limit int) ([]types.UserMatch, error)
+	GetUserInfoBySecondName(ctx context.Context, name string) ([]types.UserInfo, error)
+	GetAllUsersInfo(ctx context.Context, filter types.UsersFilter) ([]types.UserInfo, error)
+	GetUserEmails(ctx context.Context, id uint64) ([]types.Email, error)
+	GetUserFriends(ctx context.Context, id uint64) ([]types.Friend, error)
+	GetAuditEvents(ctx context.Context, filter types.AuditFilter) ([]types.AuditEvent, error)
+	Ping(ctx context.Context) error
+
+	CreateUser(ctx context.Context, user types.User, details types.EnrichmentDetails) (uint64, error)
+	CreateUserPending(ctx context.Context, name types.Name) (uint64, uint64, error)
+	UpdateUser(ctx context.Context, user types.User, id uint64, ifMatch types.IfMatch) (uint64, error)
+	DeleteUser(ctx context.Context, id uint64, ifMatch types.IfMatch) error
+	RestoreUser(ctx context.Context, id uint64) (uint64, error)
+	PurgeUser(ctx context.Context, id uint64) error
+	PurgeDeletedUsers(ctx context.Context, before time.Time, limit int) (int64, error)
+	AddUserEmails(ctx context.Context, emails types.EmailRequest, id uint64) ([]types.ItemResult, error)
+	AddUserFriends(ctx context.Context, friends types.Friends, userID uint64) ([]types.ItemResult, error)
+	DeleteEmails(ctx context.Context, emails []uint64) ([]types.ItemResult, error)
+	DeleteUserFriends(ctx context.Context, friendsPairs types.Friendships) ([]types.ItemResult, error)
+
+	ClaimEnrichmentJob(ctx context.Context, lease time.Duration, maxAttempts int) (types.EnrichmentJob, error)
+	BuryEnrichmentJobs(ctx context.Context, lease time.Duration, maxAttempts int) error
+	SaveUserEnrichment(ctx context.Context, id uint64, user types.User, details types.EnrichmentDetails) ([]types.EnrichmentChange, error)
+	FinishEnrichmentJob(ctx context.Context, job types.EnrichmentJob, failed []string) error
+	EnqueueEnrichmentRefresh(ctx context.Context, filter types.RefreshFilter) (int64, error)
+	GetEnrichmentState(ctx context.Context, id uint64) (types.EnrichmentState, error)
+}
+
+// UnitOfWork is the part of storage.UnitOfWork the use cases write through
+type UnitOfWork interface {
+	CreateUser(ctx context.Context, user types.User, enrichedAt *time.Time) (uint64, error)
+	AddEnrichmentDetails(ctx context.Context, id uint64, details types.EnrichmentDetails) error
+	EnqueueEnrichment(ctx context.Context, userID uint64) (uint64, error)
+	AddEmails(ctx context.Context, userID uint64, emails []string) ([]types.ItemResult, error)
+	AddFriends(ctx context.Context, userID uint64, friendIDs []uint64) ([]types.ItemResult, error)
+	MatchVersion(ctx context.Context, id uint64, ifMatch types.IfMatch) error
+	GetUserForUpdate(ctx context.Context, id uint64) (types.User, error)
+	PatchUser(ctx context.Context, id uint64, user types.User, columns []string) error
+}
+
+// Postgres adapts the storage to the use cases
+func Postgres(store *storage.Storage) Storage {
+	return postgres{Storage: store}
+}
+
+type postgres struct {
+	*storage.Storage
+}
+
+func (p postgres) InTransaction(ctx context.Context, fn func(uow UnitOfWork) error) error {
+	return p.Storage.InTransaction(ctx, func(uow *storage.UnitOfWork) error {
+		return fn(uow)
+	})
+}
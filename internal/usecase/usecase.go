@@ -3,25 +3,111 @@ package usecase
 import (
 	"context"
 	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"people/internal/repository/enrichment"
-	"people/internal/repository/storage"
 	"people/internal/types"
 )
 
 type UseCase struct {
-	storage    *storage.Storage
-	enrichment *enrichment.Enrichment
-	log        *logrus.Logger
+	storage      Storage
+	enrichment   enrichment.EnrichmentProvider
+	mode         string
+	policy       string
+	countryHint  string
+	topCountries int
+	// workers counts the running enrichment workers
+	workers atomic.Int32
+	log     *logrus.Logger
 }
 
-func New(storage *storage.Storage, enrichment *enrichment.Enrichment, log *logrus.Logger) *UseCase {
+// New rejects an unknown enrichment mode or policy of config.yml
+func New(storage Storage, enrichment enrichment.EnrichmentProvider, cfg types.EnrichmentUrlsConfig, log *logrus.Logger) (*UseCase, error) {
+	mode := strings.ToLower(cfg.Mode)
+	switch mode {
+	case "":
+		mode = types.EnrichmentModeSync
+	case types.EnrichmentModeSync, types.EnrichmentModeAsync:
+	default:
+		log.Errorf("Unknown enrichment mode %q", cfg.Mode)
+		return nil, fmt.Errorf("unknown enrichment mode %q", cfg.Mode)
+	}
+
+	policy := strings.ToLower(cfg.Policy)
+	switch policy {
+	case "":
+		policy = types.EnrichmentPolicyStrict
+	case types.EnrichmentPolicyStrict, types.EnrichmentPolicyBestEffort:
+	default:
+		log.Errorf("Unknown enrichment policy %q", cfg.Policy)
+		return nil, fmt.Errorf("unknown enrichment policy %q", cfg.Policy)
+	}
+
+	topCountries := cfg.TopCountries
+	if topCountries <= 0 {
+		topCountries = types.DefaultTopCountries
+	}
+
 	return &UseCase{
-		storage:    storage,
-		enrichment: enrichment,
-		log:        log,
+		storage:      storage,
+		enrichment:   enrichment,
+		mode:         mode,
+		policy:       policy,
+		countryHint:  strings.ToUpper(cfg.CountryHint),
+		topCountries: topCountries,
+		log:          log,
+	}, nil
+}
+
+// GetUserByID returns the user with its emails and the number of its friends
+func (s *UseCase) GetUserByID(ctx context.Context, id uint64) (types.UserInfo, error) {
+	user, err := s.storage.GetUserByID(ctx, id)
+	if err != nil {
+		s.log.WithError(err).Errorln("Can`t get user info")
+		return types.UserInfo{}, err
+	}
+
+	return withCountries([]types.UserInfo{user})[0], nil
+}
+
+// GetUserAsOf returns the user with its emails and friends as it was at the given time
+func (s *UseCase) GetUserAsOf(ctx context.Context, id uint64, asOf time.Time) (types.UserSnapshot, error) {
+	snapshot, err := s.storage.GetUserAsOf(ctx, id, asOf)
+	if err != nil {
+		s.log.WithError(err).Errorln("Can`t get user history")
+		return types.UserSnapshot{}, err
+	}
+
+	snapshot.UserInfo = withCountries([]types.UserInfo{snapshot.UserInfo})[0]
+	return snapshot, nil
+}
+
+// SearchUsers finds users by a part of their names regardless of case, diacritics and Cyrillic or Latin spelling
+func (s *UseCase) SearchUsers(ctx context.Context, req types.SearchUsersRequest) ([]types.UserMatch, error) {
+	limit := req.Limit
+	if limit == 0 {
+		limit = types.DefaultSearchLimit
+	}
+
+	matches, err := s.storage.SearchUsers(ctx, strings.TrimSpace(req.Query), limit)
+	if err != nil {
+		s.log.WithError(err).Errorln("Can`t search users")
+		return []types.UserMatch{}, err
+	}
+
+	for i := range matches {
+		if matches[i].Nationality != nil {
+			matches[i].Country = types.CountryOf(*matches[i].Nationality)
+		}
 	}
+
+	return matches, nil
 }
 
 func (s *UseCase) GetUserInfoBySecondName(ctx context.Context, name string) ([]types.UserInfo, error) {
@@ -35,21 +121,119 @@ func (s *UseCase) GetUserInfoBySecondName(ctx context.Context, name string) ([]t
 		return []types.UserInfo{}, err
 	}
 
-	return user, nil
+	return withCountries(user), nil
 }
 
-func (s *UseCase) GetAllUsersInfo(ctx context.Context) ([]types.UserInfo, error) {
-	users, err := s.storage.GetAllUsersInfo(ctx)
+// GetAllUsersInfo returns a page of the users matching the request, region and nationality
+// narrow each other when both are given
+func (s *UseCase) GetAllUsersInfo(ctx context.Context, req types.ListUsersRequest) (types.UsersPage, error) {
+	filter, err := usersFilter(req)
+	if err != nil {
+		s.log.WithError(err).Errorln("Invalid users query")
+		return types.UsersPage{}, err
+	}
+
+	// one more user tells whether there is a next page
+	filter.Limit++
+	users, err := s.storage.GetAllUsersInfo(ctx, filter)
 	if err != nil {
 		if errors.Is(err, types.ErrNotFound) {
 			s.log.WithError(err).Errorln("Not found all users info")
-			return []types.UserInfo{}, types.ErrNotFound
+			return types.UsersPage{}, types.ErrNotFound
 		}
 		s.log.WithError(err).Errorln("Can`t get all users info")
-		return []types.UserInfo{}, err
+		return types.UsersPage{}, err
+	}
+
+	page := types.UsersPage{Users: users}
+	if len(users) > filter.Limit-1 {
+		page.Users = users[:filter.Limit-1]
+		page.NextCursor = types.NewUsersCursor(filter.Sort, page.Users[len(page.Users)-1]).Encode()
+	}
+	if page.Users == nil {
+		page.Users = []types.UserInfo{}
+	}
+	page.Users = withCountries(page.Users)
+
+	return page, nil
+}
+
+// usersFilter applies the defaults of the users list and normalizes its countries
+func usersFilter(req types.ListUsersRequest) (types.UsersFilter, error) {
+	filter := types.UsersFilter{
+		Limit:    req.Limit,
+		Sort:     req.Sort,
+		AgeMin:   req.AgeMin,
+		AgeMax:   req.AgeMax,
+		HasEmail: req.HasEmail,
+	}
+	if filter.Limit == 0 {
+		filter.Limit = types.DefaultUsersLimit
+	}
+	if filter.Sort == "" {
+		filter.Sort = types.DefaultUsersSort
+	}
+	if req.Gender != "" {
+		filter.Gender = &req.Gender
+	}
+	if req.FirstName != "" {
+		filter.FirstNamePrefix = &req.FirstName
+	}
+	if req.LastName != "" {
+		filter.LastNamePrefix = &req.LastName
+	}
+	if req.LastNameExact != "" {
+		filter.LastName = &req.LastNameExact
+	}
+
+	if filter.AgeMin != nil && filter.AgeMax != nil && *filter.AgeMin > *filter.AgeMax {
+		return types.UsersFilter{}, fmt.Errorf("%w: age_min %d is above age_max %d", types.ErrInvalidFilter, *filter.AgeMin, *filter.AgeMax)
 	}
 
-	return users, nil
+	if req.Nationality != "" {
+		for _, country := range strings.Split(req.Nationality, ",") {
+			code, err := types.NormalizeCountry(country)
+			if err != nil {
+				return types.UsersFilter{}, err
+			}
+			filter.Nationalities = append(filter.Nationalities, code)
+		}
+	}
+
+	if req.Region != "" {
+		countries, err := types.RegionCountries(req.Region)
+		if err != nil {
+			return types.UsersFilter{}, err
+		}
+		if filter.Nationalities == nil {
+			filter.Nationalities = countries
+		} else {
+			// an empty, non-nil list matches nobody
+			filter.Nationalities = slices.DeleteFunc(filter.Nationalities, func(code string) bool {
+				return !slices.Contains(countries, code)
+			})
+		}
+	}
+
+	if req.Cursor != "" {
+		cursor, err := types.DecodeUsersCursor(req.Cursor, filter.Sort)
+		if err != nil {
+			return types.UsersFilter{}, err
+		}
+		filter.After = cursor
+	}
+
+	return filter, nil
+}
+
+// withCountries derives the country details from the stored nationality
+func withCountries(users []types.UserInfo) []types.UserInfo {
+	for i := range users {
+		if users[i].Nationality != nil {
+			users[i].Country = types.CountryOf(*users[i].Nationality)
+		}
+	}
+	return users
 }
 
 func (s *UseCase) GetUserEmails(ctx context.Context, id uint64) ([]types.Email, error) {
@@ -80,92 +264,429 @@ func (s *UseCase) GetUserFriends(ctx context.Context, id uint64) ([]types.Friend
 	return friends, nil
 }
 
-func (s *UseCase) CreateUser(ctx context.Context, fullName types.Name) (uint64, error) {
-	age, err := s.enrichment.Age(fullName.FirstName)
+// enrichmentResult collects the outcome of the concurrent lookups for one name
+type enrichmentResult struct {
+	age            types.AgeData
+	ageErr         error
+	gender         types.GenderData
+	genderErr      error
+	nationality    types.NationalityData
+	nationalityErr error
+}
+
+// enrich runs the age, gender and nationality lookups concurrently, country narrows age and gender.
+// With failFast the first failure cancels the lookups that are still in flight
+func (s *UseCase) enrich(ctx context.Context, name, country string, failFast bool) enrichmentResult {
+	ctx, cancelCtx := context.WithCancel(ctx)
+	defer cancelCtx()
+
+	cancel := cancelCtx
+	if !failFast {
+		cancel = func() {}
+	}
+
+	var result enrichmentResult
+	var wg sync.WaitGroup
+	wg.Add(3)
+
+	go func() {
+		defer wg.Done()
+		result.age, result.ageErr = s.enrichment.Age(ctx, name, country)
+		if result.ageErr != nil {
+			cancel()
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		result.gender, result.genderErr = s.enrichment.Gender(ctx, name, country)
+		if result.genderErr != nil {
+			cancel()
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		result.nationality, result.nationalityErr = s.enrichment.Nationality(ctx, name)
+		if result.nationalityErr != nil {
+			cancel()
+		}
+	}()
+
+	wg.Wait()
+	return result
+}
+
+// user builds the user from the resolved attributes, failed lookups are left nil
+func (r enrichmentResult) user(fullName types.Name) types.User {
+	user := types.User{
+		Name: fullName,
+	}
+
+	if r.ageErr == nil {
+		user.Age = &r.age.Age
+	}
+	if r.genderErr == nil {
+		user.Gender = &r.gender.Gender
+	}
+	if r.nationalityErr == nil && len(r.nationality.Country) > 0 {
+		user.Nationality = &r.nationality.Country[0].ID
+	}
+
+	return user
+}
+
+// details keeps the statistics of the resolved attributes and the top nationality candidates
+func (r enrichmentResult) details(topCountries int) types.EnrichmentDetails {
+	var details types.EnrichmentDetails
+
+	if r.ageErr == nil {
+		details.AgeCount = &r.age.Count
+	}
+	if r.genderErr == nil {
+		details.GenderProbability = &r.gender.Probability
+		details.GenderCount = &r.gender.Count
+	}
+	if r.nationalityErr == nil {
+		details.NationalityCount = &r.nationality.Count
+		details.Countries = r.nationality.Country
+		if len(details.Countries) > topCountries {
+			details.Countries = details.Countries[:topCountries]
+		}
+	}
+
+	return details
+}
+
+// applyPolicy builds the user from the lookup results, with the strict policy any failed lookup fails the user
+func (s *UseCase) applyPolicy(ctx context.Context, fullName types.Name, result enrichmentResult) (types.User, error) {
+	if result.ageErr != nil {
+		s.log.WithError(result.ageErr).Errorln("Can`t get user age")
+	}
+	if result.genderErr != nil {
+		s.log.WithError(result.genderErr).Errorln("Can`t get user gender")
+	}
+	if result.nationalityErr != nil {
+		s.log.WithError(result.nationalityErr).Errorln("Can`t get user nationality")
+	}
+
+	if s.policy == types.EnrichmentPolicyStrict {
+		// cancelled siblings report context.Canceled, return the error that caused it
+		err := firstError(result.ageErr, result.genderErr, result.nationalityErr)
+		if err != nil {
+			return types.User{}, err
+		}
+	}
+
+	// the client is gone, there is nobody to report the partial result to
+	if err := ctx.Err(); err != nil {
+		s.log.WithError(err).Errorln("Request cancelled while enriching user")
+		return types.User{}, err
+	}
+
+	return result.user(fullName), nil
+}
+
+// CreateUser enriches and stores the user with its emails and friends in one transaction. In the async mode
+// the user is stored right away with every attribute pending and the response carries the queued job
+func (s *UseCase) CreateUser(ctx context.Context, req types.CreateUserRequest) (types.CreateUserResponse, error) {
+	// the default hint only narrows the lookups, the user keeps the hint of the request
+	fullName := req.Name
+
+	if s.mode == types.EnrichmentModeAsync {
+		var response types.CreateUserResponse
+		err := s.storage.InTransaction(ctx, func(uow UnitOfWork) error {
+			id, err := uow.CreateUser(ctx, types.User{Name: fullName}, nil)
+			if err != nil {
+				return err
+			}
+			err = addContacts(ctx, uow, id, req)
+			if err != nil {
+				return err
+			}
+			jobID, err := uow.EnqueueEnrichment(ctx, id)
+			if err != nil {
+				return err
+			}
+
+			response = types.CreateUserResponse{
+				UserID:  id,
+				Pending: types.User{}.EnrichmentStatus().Pending(),
+				JobID:   jobID,
+			}
+			return nil
+		})
+		if err != nil {
+			s.log.WithError(err).Errorln("Can`t add user")
+			return types.CreateUserResponse{}, err
+		}
+
+		return response, nil
+	}
+
+	result := s.enrich(ctx, fullName.FirstName, s.countryHintOr(fullName.CountryHint), s.policy == types.EnrichmentPolicyStrict)
+
+	user, err := s.applyPolicy(ctx, fullName, result)
 	if err != nil {
-		s.log.WithError(err).Errorln("Can`t get user age")
-		return 0, err
+		return types.CreateUserResponse{}, err
 	}
 
-	gender, err := s.enrichment.Gender(fullName.FirstName)
+	var id uint64
+	err = s.storage.InTransaction(ctx, func(uow UnitOfWork) error {
+		enrichedAt := time.Now()
+		id, err = uow.CreateUser(ctx, user, &enrichedAt)
+		if err != nil {
+			return err
+		}
+		err = uow.AddEnrichmentDetails(ctx, id, result.details(s.topCountries))
+		if err != nil {
+			return err
+		}
+		return addContacts(ctx, uow, id, req)
+	})
 	if err != nil {
-		s.log.WithError(err).Errorln("Can`t get user gender")
-		return 0, err
+		s.log.WithError(err).Errorln("Can`t add user")
+		return types.CreateUserResponse{}, err
+	}
+
+	return types.CreateUserResponse{
+		UserID:  id,
+		Pending: user.EnrichmentStatus().Pending(),
+	}, nil
+}
+
+// addContacts adds the emails and the friends of a new user, one item that can not be stored rejects them all
+func addContacts(ctx context.Context, uow UnitOfWork, id uint64, req types.CreateUserRequest) error {
+	if len(req.Emails) > 0 {
+		results, err := uow.AddEmails(ctx, id, req.Emails)
+		if err != nil {
+			return err
+		}
+		err = types.RejectItems(results)
+		if err != nil {
+			return err
+		}
+	}
+	if len(req.FriendsIDs) > 0 {
+		results, err := uow.AddFriends(ctx, id, req.FriendsIDs)
+		if err != nil {
+			return err
+		}
+		return types.RejectItems(results)
 	}
+	return nil
+}
 
-	nationality, err := s.enrichment.Nationality(fullName.FirstName)
+// GetEnrichmentState returns the enrichment progress of the user
+func (s *UseCase) GetEnrichmentState(ctx context.Context, id uint64) (types.EnrichmentState, error) {
+	state, err := s.storage.GetEnrichmentState(ctx, id)
 	if err != nil {
-		s.log.WithError(err).Errorln("Can`t get user nationality")
-		return 0, err
+		s.log.WithError(err).Errorln("Can`t get enrichment state")
+		return types.EnrichmentState{}, err
 	}
 
-	user := types.User{
-		Name: types.Name{
-			FirstName: fullName.FirstName,
-			LastName:  fullName.LastName,
-		},
-		Gender:      gender,
-		Nationality: nationality,
-		Age:         age,
+	return state, nil
+}
+
+// EnrichUser runs the enrichment of an existing user again and stores the attributes that were resolved,
+// attributes that could not be resolved keep their values
+func (s *UseCase) EnrichUser(ctx context.Context, id uint64) (types.EnrichResponse, error) {
+	name, err := s.storage.GetUserName(ctx, id)
+	if err != nil {
+		s.log.WithError(err).Errorln("Can`t get user")
+		return types.EnrichResponse{}, err
+	}
+
+	result := s.enrich(ctx, name.FirstName, s.countryHintOr(name.CountryHint), false)
+	if result.ageErr != nil && result.genderErr != nil && result.nationalityErr != nil {
+		err = firstError(result.ageErr, result.genderErr, result.nationalityErr)
+		s.log.WithError(err).Errorln("Can`t enrich user")
+		return types.EnrichResponse{}, err
 	}
 
-	id, err := s.storage.CreateUser(ctx, user)
+	user := result.user(name)
+
+	changes, err := s.storage.SaveUserEnrichment(ctx, id, user, result.details(s.topCountries))
 	if err != nil {
-		s.log.WithError(err).Errorln("Can`t add user")
+		s.log.WithError(err).Errorln("Can`t save user enrichment")
+		return types.EnrichResponse{}, err
+	}
+
+	return types.EnrichResponse{
+		UserID:  id,
+		Changes: changes,
+		Failed:  user.EnrichmentStatus().Pending(),
+	}, nil
+}
+
+// countryHintOr returns the hint of the request or the default from config.yml
+func (s *UseCase) countryHintOr(hint string) string {
+	if hint != "" {
+		return hint
+	}
+	return s.countryHint
+}
+
+// ProvidersStatus returns the last known quotas and the circuit breakers of the enrichment upstreams,
+// providers without a quota such as the local table report none
+func (s *UseCase) ProvidersStatus() types.ProvidersStatus {
+	quotas := enrichment.Quotas(s.enrichment)
+	if quotas == nil {
+		quotas = []types.QuotaState{}
+	}
+	return types.ProvidersStatus{
+		Quotas:   quotas,
+		Breakers: s.breakers(),
+	}
+}
+
+// Health reports the database and the circuit breakers, it is degraded while some circuit is not closed
+func (s *UseCase) Health(ctx context.Context) types.HealthResponse {
+	health := types.HealthResponse{
+		Status:   types.HealthOK,
+		Database: types.HealthOK,
+		Breakers: s.breakers(),
+	}
+
+	for _, breaker := range health.Breakers {
+		if breaker.State != types.BreakerClosed {
+			health.Status = types.HealthDegraded
+		}
+	}
+
+	err := s.storage.Ping(ctx)
+	if err != nil {
+		s.log.WithError(err).Errorln("Database is unavailable")
+		health.Status = types.HealthUnavailable
+		health.Database = types.HealthUnavailable
+	}
+
+	return health
+}
+
+func (s *UseCase) breakers() []types.BreakerState {
+	breakers := enrichment.Breakers(s.enrichment)
+	if breakers == nil {
+		breakers = []types.BreakerState{}
+	}
+	return breakers
+}
+
+// RefreshEnrichment queues the re-enrichment of the users matching the filter, the workers process it.
+// It returns types.ErrNoWorkers when queue.workers is 0, the jobs would never run
+func (s *UseCase) RefreshEnrichment(ctx context.Context, filter types.RefreshFilter) (int64, error) {
+	if s.workers.Load() == 0 {
+		s.log.Errorln("Can`t queue enrichment refresh without enrichment workers")
+		return 0, types.ErrNoWorkers
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = types.DefaultRefreshLimit
+	}
+
+	queued, err := s.storage.EnqueueEnrichmentRefresh(ctx, filter)
+	if err != nil {
+		s.log.WithError(err).Errorln("Can`t queue enrichment refresh")
 		return 0, err
 	}
 
-	return id, nil
+	s.log.Infof("Queued enrichment refresh of %d users", queued)
+	return queued, nil
+}
+
+func firstError(errs ...error) error {
+	var canceled error
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, context.Canceled) {
+			return err
+		}
+		canceled = err
+	}
+	return canceled
 }
 
 // AddUserEmails - can add one or more user`s emails
-func (s *UseCase) AddUserEmails(ctx context.Context, emails types.EmailRequest, id uint64) error {
-	err := s.storage.AddUserEmails(ctx, emails, id)
+func (s *UseCase) AddUserEmails(ctx context.Context, emails types.EmailRequest, id uint64) ([]types.ItemResult, error) {
+	results, err := s.storage.AddUserEmails(ctx, emails, id)
 	if err != nil {
 		s.log.WithError(err).Errorln("Can`t add user`s emails")
 	}
-	return err
+	return results, err
 }
 
 // AddUserFriends - can add one or more user`s friends
-func (s *UseCase) AddUserFriends(ctx context.Context, friends types.Friends, userID uint64) error {
-	err := s.storage.AddUserFriends(ctx, friends, userID)
+func (s *UseCase) AddUserFriends(ctx context.Context, friends types.Friends, userID uint64) ([]types.ItemResult, error) {
+	results, err := s.storage.AddUserFriends(ctx, friends, userID)
 	if err != nil {
 		s.log.WithError(err).Errorln("Can`t add user friends")
 	}
-	return err
+	return results, err
 }
 
-func (s *UseCase) UpdateUser(ctx context.Context, user types.User, id uint64) error {
-	err := s.storage.UpdateUser(ctx, user, id)
+// UpdateUser replaces the user and returns its new version, a manually supplied nationality is normalized
+// to ISO 3166-1 alpha-2
+func (s *UseCase) UpdateUser(ctx context.Context, user types.User, id uint64, ifMatch types.IfMatch) (uint64, error) {
+	if user.Nationality != nil {
+		nationality, err := types.NormalizeCountry(*user.Nationality)
+		if err != nil {
+			s.log.WithError(err).Errorln("Invalid nationality")
+			return 0, err
+		}
+		user.Nationality = &nationality
+	}
+
+	version, err := s.storage.UpdateUser(ctx, user, id, ifMatch)
 	if err != nil {
 		s.log.WithError(err).Errorln("Can`t update user")
 	}
-	return err
+	return version, err
 }
 
-func (s *UseCase) DeleteUser(ctx context.Context, id uint64) error {
-	err := s.storage.DeleteUser(ctx, id)
+func (s *UseCase) DeleteUser(ctx context.Context, id uint64, ifMatch types.IfMatch) error {
+	err := s.storage.DeleteUser(ctx, id, ifMatch)
 	if err != nil {
 		s.log.WithError(err).Errorln("Can`t delete user")
 	}
 	return err
 }
 
+// RestoreUser brings back a deleted user
+func (s *UseCase) RestoreUser(ctx context.Context, id uint64) (types.UserInfo, error) {
+	_, err := s.storage.RestoreUser(ctx, id)
+	if err != nil {
+		s.log.WithError(err).Errorln("Can`t restore user")
+		return types.UserInfo{}, err
+	}
+
+	return s.GetUserByID(ctx, id)
+}
+
+// PurgeUser removes a deleted user for good
+func (s *UseCase) PurgeUser(ctx context.Context, id uint64) error {
+	err := s.storage.PurgeUser(ctx, id)
+	if err != nil {
+		s.log.WithError(err).Errorln("Can`t purge user")
+	}
+	return err
+}
+
 // DeleteEmails - can delete one or more emails
-func (s *UseCase) DeleteEmails(ctx context.Context, emails []uint64) error {
-	err := s.storage.DeleteEmails(ctx, emails)
+func (s *UseCase) DeleteEmails(ctx context.Context, emails []uint64) ([]types.ItemResult, error) {
+	results, err := s.storage.DeleteEmails(ctx, emails)
 	if err != nil {
 		s.log.WithError(err).Errorln("Can`t delete emails")
 	}
-	return err
+	return results, err
 }
 
 // DeleteUserFriends - can delete one or more user`s friends
-func (s *UseCase) DeleteUserFriends(ctx context.Context, friendsPairs types.Friendships) error {
-	err := s.storage.DeleteUserFriends(ctx, friendsPairs)
+func (s *UseCase) DeleteUserFriends(ctx context.Context, friendsPairs types.Friendships) ([]types.ItemResult, error) {
+	results, err := s.storage.DeleteUserFriends(ctx, friendsPairs)
 	if err != nil {
 		s.log.WithError(err).Errorln("Can`t delete user friends")
 	}
-	return err
+	return results, err
 }
@@ -0,0 +1,585 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"people/internal/repository/enrichment/enrichmenttest"
+	"people/internal/types"
+)
+
+// fakeStorage keeps the created users in memory, the methods the tests do not reach panic
+type fakeStorage struct {
+	Storage
+
+	mu      sync.Mutex
+	users   map[uint64]types.User
+	details map[uint64]types.EnrichmentDetails
+	emails  map[uint64][]string
+	jobs    []uint64
+	patched []string
+	nextID  uint64
+}
+
+func newFakeStorage() *fakeStorage {
+	return &fakeStorage{
+		users:   make(map[uint64]types.User),
+		details: make(map[uint64]types.EnrichmentDetails),
+		emails:  make(map[uint64][]string),
+	}
+}
+
+// InTransaction keeps the writes of fn only when it succeeds
+func (f *fakeStorage) InTransaction(ctx context.Context, fn func(uow UnitOfWork) error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	uow := &fakeUnitOfWork{storage: f, users: make(map[uint64]types.User)}
+	err := fn(uow)
+	if err != nil {
+		return err
+	}
+
+	for id, user := range uow.users {
+		f.users[id] = user
+	}
+	for id, details := range uow.details {
+		f.details[id] = details
+	}
+	for id, emails := range uow.emails {
+		f.emails[id] = append(f.emails[id], emails...)
+	}
+	f.jobs = append(f.jobs, uow.jobs...)
+	f.patched = append(f.patched, uow.patched...)
+	return nil
+}
+
+func (f *fakeStorage) GetUserByID(_ context.Context, id uint64) (types.UserInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	user, ok := f.users[id]
+	if !ok {
+		return types.UserInfo{}, types.ErrNotFound
+	}
+	return types.UserInfo{ID: id, User: user}, nil
+}
+
+func (f *fakeStorage) CreateUser(ctx context.Context, user types.User, details types.EnrichmentDetails) (uint64, error) {
+	var id uint64
+	err := f.InTransaction(ctx, func(uow UnitOfWork) error {
+		var err error
+		id, err = uow.CreateUser(ctx, user, nil)
+		if err != nil {
+			return err
+		}
+		return uow.AddEnrichmentDetails(ctx, id, details)
+	})
+	return id, err
+}
+
+func (f *fakeStorage) stored() map[uint64]types.User {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	users := make(map[uint64]types.User, len(f.users))
+	for id, user := range f.users {
+		users[id] = user
+	}
+	return users
+}
+
+// fakeUnitOfWork rejects emails already owned by a user
+type fakeUnitOfWork struct {
+	UnitOfWork
+
+	storage *fakeStorage
+	users   map[uint64]types.User
+	details map[uint64]types.EnrichmentDetails
+	emails  map[uint64][]string
+	jobs    []uint64
+	patched []string
+}
+
+func (u *fakeUnitOfWork) CreateUser(_ context.Context, user types.User, _ *time.Time) (uint64, error) {
+	u.storage.nextID++
+	u.users[u.storage.nextID] = user
+	return u.storage.nextID, nil
+}
+
+func (u *fakeUnitOfWork) AddEnrichmentDetails(_ context.Context, id uint64, details types.EnrichmentDetails) error {
+	if u.details == nil {
+		u.details = make(map[uint64]types.EnrichmentDetails)
+	}
+	u.details[id] = details
+	return nil
+}
+
+func (u *fakeUnitOfWork) EnqueueEnrichment(_ context.Context, userID uint64) (uint64, error) {
+	u.jobs = append(u.jobs, userID)
+	return uint64(len(u.storage.jobs) + len(u.jobs)), nil
+}
+
+func (u *fakeUnitOfWork) AddEmails(_ context.Context, userID uint64, emails []string) ([]types.ItemResult, error) {
+	if u.emails == nil {
+		u.emails = make(map[uint64][]string)
+	}
+
+	results := make([]types.ItemResult, len(emails))
+	for i, email := range emails {
+		results[i] = types.ItemResult{Index: i, Item: email, Status: types.ItemCreated}
+		for _, owned := range u.storage.emails {
+			for _, ownedEmail := range owned {
+				if ownedEmail == email {
+					results[i].Status = types.ItemConflict
+				}
+			}
+		}
+		if results[i].OK() {
+			u.emails[userID] = append(u.emails[userID], email)
+		}
+	}
+	return results, nil
+}
+
+func (u *fakeUnitOfWork) MatchVersion(context.Context, uint64, types.IfMatch) error {
+	return nil
+}
+
+func (u *fakeUnitOfWork) GetUserForUpdate(_ context.Context, id uint64) (types.User, error) {
+	user, ok := u.storage.users[id]
+	if !ok {
+		return types.User{}, types.ErrNotFound
+	}
+	return user, nil
+}
+
+func (u *fakeUnitOfWork) PatchUser(_ context.Context, id uint64, user types.User, columns []string) error {
+	u.users[id] = user
+	u.patched = append(u.patched, columns...)
+	return nil
+}
+
+type options struct {
+	mode        string
+	policy      string
+	countryHint string
+}
+
+func newUseCase(t *testing.T, server *enrichmenttest.Server, opts options) (*UseCase, *fakeStorage) {
+	t.Helper()
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	provider, err := server.Provider(logger)
+	if err != nil {
+		t.Fatalf("Provider: %v", err)
+	}
+
+	cfg := server.Config()
+	cfg.Mode = opts.mode
+	cfg.Policy = opts.policy
+	cfg.CountryHint = opts.countryHint
+
+	store := newFakeStorage()
+	useCase, err := New(store, provider, cfg, logger)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return useCase, store
+}
+
+// knownAnn answers every lookup of Ann
+func knownAnn(server *enrichmenttest.Server) {
+	server.SetAge("Ann", "", 31, 120)
+	server.SetGender("Ann", "", "female", 0.98, 300)
+	server.SetNationality("Ann", 500, types.CountryData{ID: "DE", Probability: 0.4}, types.CountryData{ID: "FR", Probability: 0.2})
+}
+
+func TestNewRejectsUnknownModeAndPolicy(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	for _, cfg := range []types.EnrichmentUrlsConfig{
+		{Mode: "later"},
+		{Policy: "lenient"},
+	} {
+		if _, err := New(newFakeStorage(), nil, cfg, logger); err == nil {
+			t.Fatalf("New(%+v) error = nil, want the unknown value rejected", cfg)
+		}
+	}
+
+	if _, err := New(newFakeStorage(), nil, types.EnrichmentUrlsConfig{Mode: "ASYNC", Policy: "best-effort"}, logger); err != nil {
+		t.Fatalf("New() of known values error = %v", err)
+	}
+}
+
+func TestCreateUserEnrichesAndStores(t *testing.T) {
+	server := enrichmenttest.NewServer()
+	defer server.Close()
+	knownAnn(server)
+
+	useCase, store := newUseCase(t, server, options{countryHint: "us"})
+
+	response, err := useCase.CreateUser(context.Background(), types.CreateUserRequest{
+		Name:   types.Name{FirstName: "Ann", LastName: "Lee"},
+		Emails: []string{"ann@example.com"},
+	})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	if len(response.Pending) != 0 {
+		t.Fatalf("CreateUser() pending = %v, want every attribute enriched", response.Pending)
+	}
+
+	user, ok := store.stored()[response.UserID]
+	if !ok {
+		t.Fatalf("user %d was not stored", response.UserID)
+	}
+	if *user.Age != 31 || *user.Gender != "female" || *user.Nationality != "DE" {
+		t.Fatalf("stored user = %+v, want 31, female, DE", user)
+	}
+
+	// the default hint narrows the lookups but it is not the hint of the user
+	if user.CountryHint != "" {
+		t.Fatalf("stored country hint = %q, want the empty hint of the request", user.CountryHint)
+	}
+	request := server.Requests(enrichmenttest.AgePath)[0]
+	if hint := request.URL.Query().Get(types.CountryParam); hint != "US" {
+		t.Fatalf("age lookup country_id = %q, want the default US", hint)
+	}
+
+	if details := store.details[response.UserID]; len(details.Countries) != 2 {
+		t.Fatalf("stored countries = %+v, want both candidates", details.Countries)
+	}
+}
+
+func TestCreateUserStrictPolicyFailsOnUpstreamError(t *testing.T) {
+	server := enrichmenttest.NewServer()
+	defer server.Close()
+	knownAnn(server)
+	server.Fail(enrichmenttest.GenderPath, enrichmenttest.Failure{Status: http.StatusInternalServerError, Body: "boom"})
+
+	useCase, store := newUseCase(t, server, options{})
+
+	_, err := useCase.CreateUser(context.Background(), types.CreateUserRequest{Name: types.Name{FirstName: "Ann"}})
+	if err == nil {
+		t.Fatal("CreateUser() error = nil, want the gender failure")
+	}
+	if len(store.stored()) != 0 {
+		t.Fatal("a user was stored although the strict policy failed")
+	}
+}
+
+func TestCreateUserBestEffortStoresResolvedAttributes(t *testing.T) {
+	server := enrichmenttest.NewServer()
+	defer server.Close()
+	knownAnn(server)
+	server.Fail(enrichmenttest.GenderPath, enrichmenttest.Failure{Status: http.StatusInternalServerError, Body: "boom"})
+
+	useCase, store := newUseCase(t, server, options{policy: types.EnrichmentPolicyBestEffort})
+
+	response, err := useCase.CreateUser(context.Background(), types.CreateUserRequest{Name: types.Name{FirstName: "Ann"}})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	if len(response.Pending) != 1 || response.Pending[0] != types.AttributeGender {
+		t.Fatalf("CreateUser() pending = %v, want gender", response.Pending)
+	}
+
+	user := store.stored()[response.UserID]
+	if user.Gender != nil || user.Age == nil || user.Nationality == nil {
+		t.Fatalf("stored user = %+v, want everything but the gender", user)
+	}
+}
+
+func TestCreateUserRateLimited(t *testing.T) {
+	server := enrichmenttest.NewServer()
+	defer server.Close()
+	knownAnn(server)
+	server.SetRateLimit(100, 0, 30*time.Second)
+
+	useCase, store := newUseCase(t, server, options{})
+
+	_, err := useCase.CreateUser(context.Background(), types.CreateUserRequest{Name: types.Name{FirstName: "Ann"}})
+	var rateLimitErr *types.RateLimitError
+	if !errors.As(err, &rateLimitErr) || rateLimitErr.RetryAfter != 30*time.Second {
+		t.Fatalf("CreateUser() error = %v, want a RateLimitError of 30s", err)
+	}
+	if len(store.stored()) != 0 {
+		t.Fatal("a user was stored although the upstream was rate limited")
+	}
+}
+
+func TestCreateUserRejectsAllContactsWhenOneFails(t *testing.T) {
+	server := enrichmenttest.NewServer()
+	defer server.Close()
+	knownAnn(server)
+
+	useCase, store := newUseCase(t, server, options{})
+	ctx := context.Background()
+
+	_, err := useCase.CreateUser(ctx, types.CreateUserRequest{Name: types.Name{FirstName: "Ann"}, Emails: []string{"ann@example.com"}})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+
+	_, err = useCase.CreateUser(ctx, types.CreateUserRequest{
+		Name:   types.Name{FirstName: "Ann"},
+		Emails: []string{"other@example.com", "ann@example.com"},
+	})
+	var itemsErr *types.ItemsError
+	if !errors.As(err, &itemsErr) || !errors.Is(err, types.ErrBatchRejected) {
+		t.Fatalf("CreateUser() error = %v, want the rejected items", err)
+	}
+	if len(store.stored()) != 1 {
+		t.Fatalf("stored %d users, want the second one rolled back", len(store.stored()))
+	}
+}
+
+func TestCreateUserAsyncQueuesEnrichment(t *testing.T) {
+	server := enrichmenttest.NewServer()
+	defer server.Close()
+
+	useCase, store := newUseCase(t, server, options{mode: types.EnrichmentModeAsync})
+
+	response, err := useCase.CreateUser(context.Background(), types.CreateUserRequest{Name: types.Name{FirstName: "Ann"}})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	if response.JobID == 0 || len(response.Pending) != 3 {
+		t.Fatalf("CreateUser() = %+v, want a job and every attribute pending", response)
+	}
+	if len(server.Requests(enrichmenttest.AgePath)) != 0 {
+		t.Fatal("the async mode called the upstream")
+	}
+	if len(store.jobs) != 1 || store.jobs[0] != response.UserID {
+		t.Fatalf("queued jobs = %v, want one for user %d", store.jobs, response.UserID)
+	}
+}
+
+func TestCreateUsersUsesBatchRequests(t *testing.T) {
+	server := enrichmenttest.NewServer()
+	defer server.Close()
+	knownAnn(server)
+	server.SetAge("Bob", "", 45, 80)
+	server.SetGender("Bob", "", "male", 0.99, 200)
+	server.SetNationality("Bob", 100, types.CountryData{ID: "GB", Probability: 0.5})
+
+	useCase, store := newUseCase(t, server, options{})
+
+	results := useCase.CreateUsers(context.Background(), []types.Name{
+		{FirstName: "Ann"},
+		{FirstName: "Bob"},
+		{FirstName: "Ann", LastName: "Other"},
+	})
+
+	for i, result := range results {
+		if result.Index != i || result.Error != "" || result.UserID == 0 {
+			t.Fatalf("row %d = %+v, want a stored user", i, result)
+		}
+	}
+	if len(store.stored()) != 3 {
+		t.Fatalf("stored %d users, want 3", len(store.stored()))
+	}
+
+	for _, path := range []string{enrichmenttest.AgePath, enrichmenttest.GenderPath, enrichmenttest.NationalityPath} {
+		requests := server.Requests(path)
+		if len(requests) != 1 {
+			t.Fatalf("%s got %d requests, want one batch", path, len(requests))
+		}
+		if names := requests[0].URL.Query()[types.BatchNameParam]; len(names) != 2 {
+			t.Fatalf("%s batch names = %v, want the distinct names", path, names)
+		}
+	}
+}
+
+func TestCreateUsersGroupsByCountryHint(t *testing.T) {
+	server := enrichmenttest.NewServer()
+	defer server.Close()
+	knownAnn(server)
+	server.SetAge("Ann", "DE", 42, 12)
+
+	useCase, store := newUseCase(t, server, options{countryHint: "US"})
+
+	results := useCase.CreateUsers(context.Background(), []types.Name{
+		{FirstName: "Ann"},
+		{FirstName: "Ann", CountryHint: "DE"},
+	})
+
+	if requests := server.Requests(enrichmenttest.AgePath); len(requests) != 2 {
+		t.Fatalf("age upstream got %d requests, want one batch per hint", len(requests))
+	}
+
+	users := store.stored()
+	first, second := users[results[0].UserID], users[results[1].UserID]
+	if *first.Age != 31 || first.CountryHint != "" {
+		t.Fatalf("first user = %+v, want 31 without a stored hint", first)
+	}
+	if *second.Age != 42 || second.CountryHint != "DE" {
+		t.Fatalf("second user = %+v, want 42 with the DE hint", second)
+	}
+}
+
+func TestCreateUsersRateLimitedRows(t *testing.T) {
+	server := enrichmenttest.NewServer()
+	defer server.Close()
+	knownAnn(server)
+	server.SetRateLimit(100, 0, 30*time.Second)
+
+	useCase, store := newUseCase(t, server, options{})
+
+	results := useCase.CreateUsers(context.Background(), []types.Name{{FirstName: "Ann"}, {FirstName: "Bob"}})
+	for i, result := range results {
+		if result.Error == "" || !errors.Is(result.Err, types.ErrRateLimited) {
+			t.Fatalf("row %d = %+v, want a rate limited row", i, result)
+		}
+	}
+	if len(store.stored()) != 0 {
+		t.Fatal("users were stored although the upstream was rate limited")
+	}
+}
+
+func TestCreateUsersFailedUpstreamFailsEveryRow(t *testing.T) {
+	server := enrichmenttest.NewServer()
+	defer server.Close()
+	knownAnn(server)
+	server.Fail(enrichmenttest.NationalityPath, enrichmenttest.Failure{Status: http.StatusBadGateway, Body: "down"})
+
+	useCase, _ := newUseCase(t, server, options{})
+
+	results := useCase.CreateUsers(context.Background(), []types.Name{{FirstName: "Ann"}, {FirstName: "Ann"}})
+	for i, result := range results {
+		if result.Error == "" || result.UserID != 0 {
+			t.Fatalf("row %d = %+v, want the nationality failure", i, result)
+		}
+	}
+
+	useCase, store := newUseCase(t, server, options{policy: types.EnrichmentPolicyBestEffort})
+
+	results = useCase.CreateUsers(context.Background(), []types.Name{{FirstName: "Ann"}})
+	if results[0].Error != "" || len(results[0].Pending) != 1 || results[0].Pending[0] != types.AttributeNationality {
+		t.Fatalf("best-effort row = %+v, want nationality pending", results[0])
+	}
+	if user := store.stored()[results[0].UserID]; user.Nationality != nil || user.Age == nil {
+		t.Fatalf("stored user = %+v, want everything but the nationality", user)
+	}
+}
+
+// jobStorage hands out a single job, saving its user fails with saveErr
+type jobStorage struct {
+	Storage
+
+	job      types.EnrichmentJob
+	saveErr  error
+	claimed  bool
+	finished []types.EnrichmentJob
+}
+
+func (j *jobStorage) BuryEnrichmentJobs(context.Context, time.Duration, int) error {
+	return nil
+}
+
+func (j *jobStorage) ClaimEnrichmentJob(context.Context, time.Duration, int) (types.EnrichmentJob, error) {
+	if j.claimed {
+		return types.EnrichmentJob{}, types.ErrNotFound
+	}
+	j.claimed = true
+	return j.job, nil
+}
+
+func (j *jobStorage) SaveUserEnrichment(context.Context, uint64, types.User, types.EnrichmentDetails) ([]types.EnrichmentChange, error) {
+	return nil, j.saveErr
+}
+
+func (j *jobStorage) FinishEnrichmentJob(_ context.Context, job types.EnrichmentJob, _ []string) error {
+	j.finished = append(j.finished, job)
+	return nil
+}
+
+func TestEnrichmentJobOfDeletedUserWaitsForRestore(t *testing.T) {
+	server := enrichmenttest.NewServer()
+	defer server.Close()
+	knownAnn(server)
+
+	useCase, _ := newUseCase(t, server, options{})
+	store := &jobStorage{job: types.EnrichmentJob{ID: 1, UserID: 7, FirstName: "Ann", Attempts: 3}, saveErr: types.ErrNotFound}
+	useCase.storage = store
+
+	processed, err := useCase.processNextJob(context.Background(), queueConfig)
+	if !processed || err != nil {
+		t.Fatalf("processNextJob() = %v, %v, want the job processed", processed, err)
+	}
+
+	if len(store.finished) != 1 || store.finished[0].Status != types.JobStatusQueued || store.finished[0].Attempts != 2 {
+		t.Fatalf("finished jobs = %+v, want the job queued again with its attempt given back", store.finished)
+	}
+}
+
+var queueConfig = types.QueueConfig{JobTimeout: time.Minute, MaxAttempts: 3, RetryBackoff: time.Second, MaxRetryBackoff: time.Minute}
+
+func TestEnrichmentJobRetries(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		failures map[string]int
+		attempts int
+		status   string
+		wait     time.Duration
+	}{
+		{
+			name:     "rate limited on the last attempt gives it back",
+			failures: map[string]int{enrichmenttest.AgePath: http.StatusTooManyRequests, enrichmenttest.GenderPath: http.StatusTooManyRequests},
+			attempts: 2,
+			status:   types.JobStatusQueued,
+			wait:     time.Minute,
+		},
+		{
+			name:     "rate limited and failed upstream on the last attempt is dead",
+			failures: map[string]int{enrichmenttest.AgePath: http.StatusTooManyRequests, enrichmenttest.GenderPath: http.StatusBadGateway},
+			attempts: 3,
+			status:   types.JobStatusDead,
+		},
+		{
+			name:     "failed upstream on the last attempt is dead",
+			failures: map[string]int{enrichmenttest.GenderPath: http.StatusBadGateway},
+			attempts: 3,
+			status:   types.JobStatusDead,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			server := enrichmenttest.NewServer()
+			defer server.Close()
+			knownAnn(server)
+			for path, status := range test.failures {
+				server.Fail(path, enrichmenttest.Failure{Status: status})
+			}
+
+			useCase, _ := newUseCase(t, server, options{})
+			store := &jobStorage{job: types.EnrichmentJob{ID: 1, UserID: 7, FirstName: "Ann", Attempts: queueConfig.MaxAttempts}}
+			useCase.storage = store
+
+			processed, err := useCase.processNextJob(context.Background(), queueConfig)
+			if !processed || err != nil {
+				t.Fatalf("processNextJob() = %v, %v, want the job processed", processed, err)
+			}
+
+			if len(store.finished) != 1 {
+				t.Fatalf("finished jobs = %+v, want one", store.finished)
+			}
+			job := store.finished[0]
+			if job.Status != test.status || job.Attempts != test.attempts {
+				t.Fatalf("finished job = %s after %d attempts, want %s after %d", job.Status, job.Attempts, test.status, test.attempts)
+			}
+			if test.wait > 0 && time.Until(job.RunAt) < test.wait-time.Second {
+				t.Fatalf("job runs again in %s, want the rate limit of %s waited out", time.Until(job.RunAt), test.wait)
+			}
+		})
+	}
+}
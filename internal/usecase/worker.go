@@ -0,0 +1,207 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"people/internal/types"
+)
+
+const (
+	defaultMaxAttempts     = 5
+	defaultRetryBackoff    = 10 * time.Second
+	defaultMaxRetryBackoff = 10 * time.Minute
+	defaultPollInterval    = time.Second
+	defaultJobTimeout      = time.Minute
+)
+
+// RunEnrichmentWorkers processes the enrichment job queue until ctx is done
+func (s *UseCase) RunEnrichmentWorkers(ctx context.Context, cfg types.QueueConfig) {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaultMaxAttempts
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = defaultRetryBackoff
+	}
+	if cfg.MaxRetryBackoff <= 0 {
+		cfg.MaxRetryBackoff = defaultMaxRetryBackoff
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaultPollInterval
+	}
+	if cfg.JobTimeout <= 0 {
+		cfg.JobTimeout = defaultJobTimeout
+	}
+
+	s.log.Infof("Starting %d enrichment workers", cfg.Workers)
+
+	ctx = types.WithAuditInfo(ctx, types.AuditInfo{Actor: "enrichment", ActorSource: types.ActorSourceService})
+
+	s.workers.Add(int32(cfg.Workers))
+	defer s.workers.Add(-int32(cfg.Workers))
+
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.runWorker(ctx, cfg)
+		}()
+	}
+	wg.Wait()
+}
+
+func (s *UseCase) runWorker(ctx context.Context, cfg types.QueueConfig) {
+	for {
+		processed, err := s.processNextJob(ctx, cfg)
+		if err != nil {
+			s.log.WithError(err).Errorln("Enrichment worker failed to process job")
+		}
+
+		// keep draining while there is work, otherwise wait for the next poll
+		if processed && err == nil {
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(cfg.PollInterval):
+		}
+	}
+}
+
+// processNextJob claims one due job and enriches its user, it reports whether a job was found
+func (s *UseCase) processNextJob(ctx context.Context, cfg types.QueueConfig) (bool, error) {
+	err := s.storage.BuryEnrichmentJobs(ctx, cfg.JobTimeout, cfg.MaxAttempts)
+	if err != nil {
+		return false, err
+	}
+
+	job, err := s.storage.ClaimEnrichmentJob(ctx, cfg.JobTimeout, cfg.MaxAttempts)
+	if err != nil {
+		if errors.Is(err, types.ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	log := s.log.WithField("job_id", job.ID).WithField("user_id", job.UserID)
+
+	// the writes share the deadline of the job, a job outliving it is taken again by another worker
+	jobCtx, cancel := context.WithTimeout(ctx, cfg.JobTimeout)
+	defer cancel()
+
+	result := s.enrich(jobCtx, job.FirstName, s.countryHintOr(job.CountryHint), false)
+	user := result.user(types.Name{FirstName: job.FirstName})
+
+	changes, err := s.storage.SaveUserEnrichment(jobCtx, job.UserID, user, result.details(s.topCountries))
+	if errors.Is(err, types.ErrNotFound) {
+		// the user was deleted while the job ran, the job is not claimed again until a restore
+		log.Infoln("Enrichment job of a deleted user waits for a restore")
+		job.Attempts--
+		job.Status = types.JobStatusQueued
+		job.RunAt = time.Now()
+		return true, s.storage.FinishEnrichmentJob(jobCtx, job, nil)
+	}
+	if err != nil {
+		log.WithError(err).Errorln("Can`t save user enrichment")
+		return true, s.retryJob(jobCtx, job, cfg, err, nil)
+	}
+
+	if len(changes) > 0 {
+		log.Infof("Enrichment job changed %d attributes", len(changes))
+	}
+
+	// a name without data will not get one by retrying
+	var failed []string
+	var retryErr error
+	for attribute, attributeErr := range map[string]error{
+		types.AttributeAge:         result.ageErr,
+		types.AttributeGender:      result.genderErr,
+		types.AttributeNationality: result.nationalityErr,
+	} {
+		switch {
+		case attributeErr == nil:
+		case errors.Is(attributeErr, types.ErrNotFound):
+			failed = append(failed, attribute)
+		default:
+			retryErr = errors.Join(retryErr, attributeErr)
+		}
+	}
+
+	if retryErr != nil {
+		log.WithError(retryErr).Warnln("Enrichment job failed")
+		return true, s.retryJob(jobCtx, job, cfg, retryErr, failed)
+	}
+
+	job.Status = types.JobStatusDone
+	job.LastError = nil
+	return true, s.storage.FinishEnrichmentJob(jobCtx, job, failed)
+}
+
+// retryJob reschedules the job with exponential backoff or moves it to the dead-letter state
+func (s *UseCase) retryJob(ctx context.Context, job types.EnrichmentJob, cfg types.QueueConfig, jobErr error, failed []string) error {
+	message := jobErr.Error()
+	job.LastError = &message
+
+	// a round that only hit rate limits is waited out, it gives back its attempt
+	retryAfter, rateLimited := rateLimitedOnly(jobErr)
+	if rateLimited {
+		job.Attempts--
+	}
+
+	delay := max(backoff(job.Attempts, cfg.RetryBackoff, cfg.MaxRetryBackoff), retryAfter)
+	// there is no point in retrying before the circuit lets probes through
+	var circuitErr *types.CircuitOpenError
+	if errors.As(jobErr, &circuitErr) {
+		delay = max(delay, circuitErr.RetryAfter)
+	}
+
+	if job.Attempts >= cfg.MaxAttempts && !rateLimited {
+		s.log.WithField("job_id", job.ID).Errorf("Enrichment job is dead after %d attempts", job.Attempts)
+		job.Status = types.JobStatusDead
+		failed = types.User{}.EnrichmentStatus().Pending()
+		return s.storage.FinishEnrichmentJob(ctx, job, failed)
+	}
+
+	job.Status = types.JobStatusQueued
+	job.RunAt = time.Now().Add(delay)
+	return s.storage.FinishEnrichmentJob(ctx, job, failed)
+}
+
+// rateLimitedOnly reports whether every error joined or wrapped in err is a types.RateLimitError and the longest
+// wait of them
+func rateLimitedOnly(err error) (time.Duration, bool) {
+	switch e := err.(type) {
+	case *types.RateLimitError:
+		return e.RetryAfter, true
+	case interface{ Unwrap() []error }:
+		var longest time.Duration
+		for _, joined := range e.Unwrap() {
+			retryAfter, rateLimited := rateLimitedOnly(joined)
+			if !rateLimited {
+				return 0, false
+			}
+			longest = max(longest, retryAfter)
+		}
+		return longest, true
+	case interface{ Unwrap() error }:
+		return rateLimitedOnly(e.Unwrap())
+	}
+	return 0, false
+}
+
+// backoff doubles the delay on every attempt, starting from base and capped at limit
+func backoff(attempt int, base, limit time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < attempt && delay < limit; i++ {
+		delay *= 2
+	}
+	return min(delay, limit)
+}
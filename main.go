@@ -1,7 +1,14 @@
 package main
 
-import "people/internal/app"
+import (
+	"log"
+	"os"
+
+	"people/internal/app"
+)
 
 func main() {
-	app.Init()
+	if err := app.Run(os.Args); err != nil {
+		log.Fatal(err)
+	}
 }